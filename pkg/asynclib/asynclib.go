@@ -1,12 +1,15 @@
 // Package asynclib provides utilities for asynchronous operations in Go, including
-// timeouts, intervals, and worker pools. It implements familiar JavaScript-like
-// patterns such as setTimeout and setInterval, along with a generic worker pool
-// for parallel processing.
+// timeouts, intervals, cron scheduling, worker pools and futures. It implements
+// familiar JavaScript-like patterns such as setTimeout, setInterval and the
+// Promise combinators, along with generic worker pools for parallel processing.
 package asynclib
 
 import (
+	"fmt"
 	"sync"
 	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
 )
 
 // Timeout represents a scheduled function execution that can be cancelled.
@@ -37,12 +40,19 @@ func SetTimeout(fn func(), millis int64) Timeout {
 	timer := time.NewTimer(time.Duration(time.Millisecond * time.Duration(millis)))
 	go func() {
 		<-timer.C
-		fn()
+		runRecovered("asynclib.timeout", fn)
 		done <- true
 	}()
 	return Timeout{Done: done, Cancelled: cancel, s: cancel, Timer: timer}
 }
 
+// runRecovered runs the scheduled function, reporting its panic to the
+// service crash handler instead of crashing the process.
+func runRecovered(name string, fn func()) {
+	defer svc.Recover(name)
+	fn()
+}
+
 // Cancel stops the timer and cancels the scheduled function execution.
 // It sends a signal through the Cancelled channel.
 func (t Timeout) Cancel() {
@@ -77,7 +87,7 @@ func SetInterval(fn func(), millis int64) Interval {
 		for {
 			select {
 			case <-ticker.C:
-				fn()
+				runRecovered("asynclib.interval", fn)
 			case <-stopped:
 				ticker.Stop()
 				return
@@ -118,6 +128,8 @@ type Workers[T any, R any] struct {
 	done chan struct{}
 	// stopOnce ensures Stop() is called only once
 	stopOnce sync.Once
+	// metricsName labels the queue depth of the pool, when exposed
+	metricsName string
 }
 
 // StartWorkers creates and starts a pool of worker goroutines.
@@ -141,14 +153,14 @@ func StartWorkers[T any, R any](workersNum int, fn func(T) (R, error)) *Workers[
 	}
 
 	for n := 1; n <= workersNum; n++ {
-		go func(n int) {
+		svc.Go("asynclib.worker", func() {
 			for {
 				select {
 				case val, ok := <-inputs:
 					if !ok {
 						return
 					}
-					res, err := fn(val)
+					res, err := runWorker(fn, val)
 					results <- WorketResult[T, R]{
 						WorkerNum: n,
 						Input:     val,
@@ -159,18 +171,52 @@ func StartWorkers[T any, R any](workersNum int, fn func(T) (R, error)) *Workers[
 					return
 				}
 			}
-		}(n)
+		})
 	}
 	return workers
 }
 
+// runWorker runs fn, reporting its panic to the service crash handler
+// and returning it as the error of the result.
+func runWorker[T any, R any](fn func(T) (R, error), val T) (res R, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("worker panic: %v", r)
+			svc.ReportCrash("asynclib.worker", r)
+		}
+	}()
+	return fn(val)
+}
+
 // Stop gracefully shuts down the worker pool.
 // It closes all channels and terminates all worker goroutines.
 // This method is safe to call multiple times.
 func (j *Workers[T, R]) Stop() {
 	j.stopOnce.Do(func() {
+		if j.metricsName != "" {
+			workersQueueDepth.Delete(j.metricsName)
+		}
 		close(j.done)
 		close(j.Input)
 		close(j.Results)
 	})
 }
+
+// workersQueueDepth holds the input queue depth of the worker pools,
+// labelled by the name passed to Metrics.
+var workersQueueDepth = svc.RegisterMetric(svc.NewGaugeSet(
+	"asynclib_workers_queue_depth",
+	"Number of inputs waiting to be processed by the worker pool.",
+	"workers",
+))
+
+// Metrics exposes the input queue depth of the pool in the
+// asynclib_workers_queue_depth gauge, labelled with the given name,
+// until the pool is stopped.
+func (j *Workers[T, R]) Metrics(name string) *Workers[T, R] {
+	j.metricsName = name
+	workersQueueDepth.Set(name, func() float64 {
+		return float64(len(j.Input))
+	})
+	return j
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -109,3 +110,43 @@ func TestStartWorkers(t *testing.T) {
 	require.Contains(t, results, 2, "Results should contain 2")
 	require.Contains(t, results, 4, "Results should contain 4")
 }
+
+func TestWorkersMetrics(t *testing.T) {
+	release := make(chan struct{})
+	workers := StartWorkers(1, func(v int) (int, error) {
+		<-release
+		return v, nil
+	})
+	workers.Metrics("test")
+
+	workers.Input <- 1
+	workers.Input <- 2
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(workersQueueDepth) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	close(release)
+	<-workers.Results
+	<-workers.Results
+	workers.Stop()
+	require.Equal(t, 0, testutil.CollectAndCount(workersQueueDepth))
+}
+
+func TestStartWorkersPanic(t *testing.T) {
+	workers := StartWorkers(1, func(v int) (int, error) {
+		if v == 1 {
+			panic("worker failure")
+		}
+		return v, nil
+	})
+	defer workers.Stop()
+
+	workers.Input <- 1
+	workers.Input <- 2
+	res := <-workers.Results
+	require.Equal(t, 1, res.Input)
+	require.ErrorContains(t, res.Err, "worker failure")
+	res = <-workers.Results
+	require.NoError(t, res.Err)
+	require.Equal(t, 2, res.Result)
+}
@@ -0,0 +1,260 @@
+package asynclib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Future is the pending result of an asynchronous computation.
+type Future[T any] struct {
+	done   chan struct{}
+	once   sync.Once
+	value  T
+	err    error
+	cancel context.CancelFunc
+}
+
+// Settled is the outcome of a future, as returned by AllSettled.
+type Settled[T any] struct {
+	Value T
+	Err   error
+}
+
+func newFuture[T any](cancel context.CancelFunc) *Future[T] {
+	return &Future[T]{done: make(chan struct{}), cancel: cancel}
+}
+
+// resolve settles the future, releasing the context of its computation.
+func (f *Future[T]) resolve(value T, err error) {
+	f.once.Do(func() {
+		f.value, f.err = value, err
+		close(f.done)
+		if f.cancel != nil {
+			f.cancel()
+		}
+	})
+}
+
+// Async runs fn in a new goroutine, returning the future of its result.
+// The context of fn derives from ctx and is cancelled by Cancel.
+func Async[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) *Future[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	f := newFuture[T](cancel)
+	go func() {
+		value, err := runFuture(ctx, fn)
+		f.resolve(value, err)
+	}()
+	return f
+}
+
+func runFuture[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (value T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("future panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Resolved returns a future already settled with value.
+func Resolved[T any](value T) *Future[T] {
+	f := newFuture[T](nil)
+	f.resolve(value, nil)
+	return f
+}
+
+// Rejected returns a future already settled with err.
+func Rejected[T any](err error) *Future[T] {
+	f := newFuture[T](nil)
+	var zero T
+	f.resolve(zero, err)
+	return f
+}
+
+// Done is closed when the future is settled.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the future to be settled and returns its result, or the
+// error of ctx if done before.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Cancel cancels the context of the computation of the future.
+func (f *Future[T]) Cancel() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+}
+
+// settled sends the indexes of the futures as they are settled,
+// until ctx is done.
+func settled[T any](ctx context.Context, futures []*Future[T]) <-chan int {
+	ch := make(chan int, len(futures))
+	for i, f := range futures {
+		go func() {
+			select {
+			case <-f.done:
+				ch <- i
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ch
+}
+
+func cancelAll[T any](futures []*Future[T]) {
+	for _, f := range futures {
+		f.Cancel()
+	}
+}
+
+// All returns the future of the values of all the futures. It is rejected
+// with the first error, cancelling the other futures.
+func All[T any](ctx context.Context, futures ...*Future[T]) *Future[[]T] {
+	return Async(ctx, func(ctx context.Context) ([]T, error) {
+		ch := settled(ctx, futures)
+		res := make([]T, len(futures))
+		for range futures {
+			select {
+			case i := <-ch:
+				if err := futures[i].err; err != nil {
+					cancelAll(futures)
+					return nil, err
+				}
+				res[i] = futures[i].value
+			case <-ctx.Done():
+				cancelAll(futures)
+				return nil, ctx.Err()
+			}
+		}
+		return res, nil
+	})
+}
+
+// Any returns the future of the first value among the futures, cancelling the
+// others. It is rejected with the joined errors if all the futures fail.
+func Any[T any](ctx context.Context, futures ...*Future[T]) *Future[T] {
+	return Async(ctx, func(ctx context.Context) (T, error) {
+		var zero T
+		if len(futures) == 0 {
+			return zero, errors.New("any without futures")
+		}
+		ch := settled(ctx, futures)
+		errs := make([]error, 0, len(futures))
+		for range futures {
+			select {
+			case i := <-ch:
+				if err := futures[i].err; err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				cancelAll(futures)
+				return futures[i].value, nil
+			case <-ctx.Done():
+				cancelAll(futures)
+				return zero, ctx.Err()
+			}
+		}
+		return zero, errors.Join(errs...)
+	})
+}
+
+// Race returns the future settled as the first settled among the futures,
+// cancelling the others.
+func Race[T any](ctx context.Context, futures ...*Future[T]) *Future[T] {
+	return Async(ctx, func(ctx context.Context) (T, error) {
+		var zero T
+		if len(futures) == 0 {
+			return zero, errors.New("race without futures")
+		}
+		select {
+		case i := <-settled(ctx, futures):
+			cancelAll(futures)
+			return futures[i].value, futures[i].err
+		case <-ctx.Done():
+			cancelAll(futures)
+			return zero, ctx.Err()
+		}
+	})
+}
+
+// AllSettled returns the future of the outcomes of all the futures,
+// rejected only if ctx is done before.
+func AllSettled[T any](ctx context.Context, futures ...*Future[T]) *Future[[]Settled[T]] {
+	return Async(ctx, func(ctx context.Context) ([]Settled[T], error) {
+		ch := settled(ctx, futures)
+		res := make([]Settled[T], len(futures))
+		for range futures {
+			select {
+			case i := <-ch:
+				res[i] = Settled[T]{Value: futures[i].value, Err: futures[i].err}
+			case <-ctx.Done():
+				cancelAll(futures)
+				return nil, ctx.Err()
+			}
+		}
+		return res, nil
+	})
+}
+
+// Map runs fn concurrently on all the items, returning the future of the
+// results in the items order. It is rejected with the first error,
+// cancelling the other calls.
+func Map[T any, R any](ctx context.Context, items []T, fn func(ctx context.Context, item T) (R, error)) *Future[[]R] {
+	return ParallelMap(ctx, items, 0, fn)
+}
+
+// ParallelMap is like Map, running at most limit calls of fn at a time.
+// A limit not positive means no limit.
+func ParallelMap[T any, R any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) (R, error)) *Future[[]R] {
+	return Async(ctx, func(ctx context.Context) ([]R, error) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		if limit <= 0 || limit > len(items) {
+			limit = len(items)
+		}
+		sem := make(chan struct{}, limit)
+		res := make([]R, len(items))
+		var wg sync.WaitGroup
+
+	loop:
+		for i, item := range items {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			}
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem }()
+				defer wg.Done()
+				value, err := runFuture(ctx, func(ctx context.Context) (R, error) {
+					return fn(ctx, item)
+				})
+				if err != nil {
+					cancel(err)
+					return
+				}
+				res[i] = value
+			}()
+		}
+		wg.Wait()
+
+		if err := context.Cause(ctx); err != nil {
+			return nil, err
+		}
+		return res, nil
+	})
+}
@@ -0,0 +1,114 @@
+package asynclib
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func delayed[T any](ctx context.Context, d time.Duration, value T, err error) *Future[T] {
+	return Async(ctx, func(ctx context.Context) (T, error) {
+		select {
+		case <-time.After(d):
+			return value, err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	})
+}
+
+func TestAsync(t *testing.T) {
+	ctx := context.Background()
+	v, err := Async(ctx, func(ctx context.Context) (int, error) { return 1, nil }).Wait(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+
+	_, err = Async(ctx, func(ctx context.Context) (int, error) { panic("boom") }).Wait(ctx)
+	require.EqualError(t, err, "future panic: boom")
+
+	f := delayed(ctx, time.Second, 1, nil)
+	f.Cancel()
+	_, err = f.Wait(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = delayed(ctx, time.Second, 1, nil).Wait(waitCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestAll(t *testing.T) {
+	ctx := context.Background()
+	res, err := All(ctx, delayed(ctx, 20*time.Millisecond, 1, nil), Resolved(2)).Wait(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, res)
+
+	slow := delayed(ctx, time.Second, 1, nil)
+	_, err = All(ctx, slow, Rejected[int](errors.New("failed"))).Wait(ctx)
+	require.EqualError(t, err, "failed")
+	_, err = slow.Wait(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAny(t *testing.T) {
+	ctx := context.Background()
+	res, err := Any(ctx, Rejected[int](errors.New("failed")), delayed(ctx, 10*time.Millisecond, 2, nil)).Wait(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, res)
+
+	_, err = Any(ctx, Rejected[int](errors.New("a")), Rejected[int](errors.New("b"))).Wait(ctx)
+	require.ErrorContains(t, err, "a")
+	require.ErrorContains(t, err, "b")
+}
+
+func TestRace(t *testing.T) {
+	ctx := context.Background()
+	slow := delayed(ctx, time.Second, 1, nil)
+	_, err := Race(ctx, slow, delayed(ctx, 10*time.Millisecond, 0, errors.New("fast"))).Wait(ctx)
+	require.EqualError(t, err, "fast")
+	_, err = slow.Wait(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAllSettled(t *testing.T) {
+	ctx := context.Background()
+	res, err := AllSettled(ctx, Resolved(1), Rejected[int](errors.New("failed"))).Wait(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, res[0].Value)
+	require.NoError(t, res[0].Err)
+	require.EqualError(t, res[1].Err, "failed")
+}
+
+func TestParallelMap(t *testing.T) {
+	ctx := context.Background()
+	var running, maxRunning atomic.Int32
+	res, err := ParallelMap(ctx, []int{1, 2, 3, 4, 5, 6}, 2, func(ctx context.Context, n int) (int, error) {
+		c := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if c <= m || maxRunning.CompareAndSwap(m, c) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return n * n, nil
+	}).Wait(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 4, 9, 16, 25, 36}, res)
+	require.Equal(t, int32(2), maxRunning.Load())
+
+	_, err = Map(ctx, []int{1, 2, 3}, func(ctx context.Context, n int) (int, error) {
+		if n == 2 {
+			return 0, errors.New("failed")
+		}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}).Wait(ctx)
+	require.EqualError(t, err, "failed")
+}
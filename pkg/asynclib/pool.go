@@ -0,0 +1,413 @@
+package asynclib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+var (
+	// ErrPoolFull is returned by Submit with RejectError when the queue is full.
+	ErrPoolFull = errors.New("worker pool queue is full")
+	// ErrPoolClosed is returned by Submit after Drain or Stop.
+	ErrPoolClosed = errors.New("worker pool is closed")
+	// ErrTaskDropped is the error of the tasks dropped by RejectDropOldest.
+	ErrTaskDropped = errors.New("task dropped from the worker pool queue")
+	// ErrPoolStopped is the error of the tasks still queued when the pool is stopped.
+	ErrPoolStopped = errors.New("worker pool stopped")
+)
+
+// RejectPolicy defines the behavior of Submit when the pool queue is full.
+type RejectPolicy int
+
+const (
+	// RejectBlock makes Submit wait for room in the queue, or for its context to be done.
+	RejectBlock RejectPolicy = iota
+	// RejectError makes Submit return ErrPoolFull.
+	RejectError
+	// RejectDropOldest drops the oldest queued task to make room, failing it with ErrTaskDropped.
+	RejectDropOldest
+)
+
+// ResultsMode defines whether and how the pool delivers the task results on its Results channel.
+type ResultsMode int
+
+const (
+	// ResultsNone delivers the results only through the futures.
+	ResultsNone ResultsMode = iota
+	// ResultsUnordered delivers the results in completion order.
+	ResultsUnordered
+	// ResultsOrdered delivers the results in submission order.
+	ResultsOrdered
+)
+
+type PoolOptions struct {
+	// Workers is the initial number of workers, at least 1.
+	Workers int
+	// QueueSize is the number of submitted tasks waiting for a worker, at least 1.
+	QueueSize int
+	Reject    RejectPolicy
+	// TaskTimeout cancels the context of each task attempt lasting longer.
+	TaskTimeout time.Duration
+	// Retries is the number of times a failed task is run again.
+	Retries    int
+	RetryDelay time.Duration
+	// Results enables the Results channel. Its consumer must keep up with the
+	// pool, as a full channel blocks the workers.
+	Results ResultsMode
+}
+
+// PoolFunc processes an input of the pool.
+type PoolFunc[T any, R any] func(ctx context.Context, input T) (R, error)
+
+// TaskFuture is the pending result of a task submitted to the pool.
+type TaskFuture[T any, R any] struct {
+	*Future[R]
+	res WorketResult[T, R]
+}
+
+// Result waits for the task to complete and returns its result record.
+func (f *TaskFuture[T, R]) Result() WorketResult[T, R] {
+	<-f.done
+	return f.res
+}
+
+type poolTask[T any, R any] struct {
+	seq    uint64
+	ctx    context.Context
+	input  T
+	future *TaskFuture[T, R]
+}
+
+// Pool is a bounded worker pool processing submitted tasks, resizable at runtime.
+type Pool[T any, R any] struct {
+	opts PoolOptions
+	fn   PoolFunc[T, R]
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []*poolTask[T, R]
+	size     int
+	workers  int
+	workerID int
+	seq      uint64
+	closed   bool
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	resolving sync.WaitGroup
+	closeOnce sync.Once
+	finished  chan struct{}
+
+	resolved    chan *poolTask[T, R]
+	results     chan WorketResult[T, R]
+	metricsName string
+}
+
+// NewPool creates and starts a worker pool running fn on the submitted inputs.
+func NewPool[T any, R any](opts PoolOptions, fn PoolFunc[T, R]) *Pool[T, R] {
+	opts.Workers = max(opts.Workers, 1)
+	opts.QueueSize = max(opts.QueueSize, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &Pool[T, R]{
+		opts:     opts,
+		fn:       fn,
+		ctx:      ctx,
+		cancel:   cancel,
+		finished: make(chan struct{}),
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	if opts.Results != ResultsNone {
+		p.resolved = make(chan *poolTask[T, R])
+		p.results = make(chan WorketResult[T, R], opts.QueueSize)
+		go p.deliver()
+	}
+
+	p.Resize(opts.Workers)
+	return p
+}
+
+// Results returns the channel of the task results, closed when the pool is
+// drained or stopped. It is nil with ResultsNone.
+func (p *Pool[T, R]) Results() <-chan WorketResult[T, R] {
+	return p.results
+}
+
+// Submit queues the input, returning the future of its result.
+// The task context derives from ctx and is cancelled when the pool is stopped.
+func (p *Pool[T, R]) Submit(ctx context.Context, input T) (*TaskFuture[T, R], error) {
+	stopWaiting := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	})
+	defer stopWaiting()
+
+	var dropped []*poolTask[T, R]
+	defer func() {
+		for _, t := range dropped {
+			p.resolve(t, WorketResult[T, R]{Input: t.input, Err: ErrTaskDropped})
+		}
+	}()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !p.closed && len(p.queue) >= p.opts.QueueSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		switch p.opts.Reject {
+		case RejectError:
+			return nil, ErrPoolFull
+		case RejectDropOldest:
+			dropped = append(dropped, p.queue[0])
+			p.resolving.Add(1)
+			p.queue = p.queue[1:]
+		default:
+			p.cond.Wait()
+		}
+	}
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	// The task context is released when the task is resolved
+	taskCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(p.ctx, cancel)
+	p.seq++
+	t := &poolTask[T, R]{
+		seq:   p.seq,
+		ctx:   taskCtx,
+		input: input,
+		future: &TaskFuture[T, R]{Future: newFuture[R](func() {
+			stop()
+			cancel()
+		})},
+	}
+	p.queue = append(p.queue, t)
+	p.cond.Broadcast()
+	return t.future, nil
+}
+
+// Size returns the number of workers the pool is sized to.
+func (p *Pool[T, R]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// Resize changes the number of workers. When shrinking, the exceeding
+// workers exit after completing their current task.
+func (p *Pool[T, R]) Resize(workers int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.size = max(workers, 1)
+	for p.workers < p.size {
+		p.workers++
+		p.workerID++
+		p.wg.Add(1)
+		go p.work(p.workerID)
+	}
+	p.cond.Broadcast()
+}
+
+// Drain stops accepting tasks and waits for the queued ones to complete.
+// If ctx is done before, the pool is stopped as by Stop, without waiting
+// for the running tasks to return.
+func (p *Pool[T, R]) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	go p.finish()
+	select {
+	case <-p.finished:
+		return nil
+	case <-ctx.Done():
+		go p.fail(p.abort())
+		return ctx.Err()
+	}
+}
+
+// Stop stops accepting tasks, fails the queued ones with ErrPoolStopped,
+// cancels the running ones and waits for them to return.
+func (p *Pool[T, R]) Stop() {
+	p.fail(p.abort())
+	<-p.finished
+}
+
+// abort stops accepting tasks, cancels the running ones and returns the
+// queued ones, to be failed.
+func (p *Pool[T, R]) abort() []*poolTask[T, R] {
+	p.mu.Lock()
+	p.closed = true
+	queued := p.queue
+	p.queue = nil
+	p.resolving.Add(len(queued))
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.cancel()
+	return queued
+}
+
+// fail fails the queued tasks with ErrPoolStopped and finishes the pool.
+func (p *Pool[T, R]) fail(queued []*poolTask[T, R]) {
+	for _, t := range queued {
+		p.resolve(t, WorketResult[T, R]{Input: t.input, Err: ErrPoolStopped})
+	}
+	go p.finish()
+}
+
+// Component returns the svc lifecycle component draining the pool on stop.
+func (p *Pool[T, R]) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Stop:      p.Drain,
+	}
+}
+
+// poolQueueDepth holds the queue depth of the pools, labelled by the
+// name passed to Metrics.
+var poolQueueDepth = svc.RegisterMetric(svc.NewGaugeSet(
+	"asynclib_pool_queue_depth",
+	"Number of tasks waiting for a worker of the pool.",
+	"pool",
+))
+
+// Metrics exposes the queue depth of the pool in the
+// asynclib_pool_queue_depth gauge, labelled with the given name, until
+// the pool is drained or stopped.
+func (p *Pool[T, R]) Metrics(name string) *Pool[T, R] {
+	p.metricsName = name
+	poolQueueDepth.Set(name, func() float64 {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return float64(len(p.queue))
+	})
+	return p
+}
+
+// finish waits for the workers to exit, then closes the results.
+func (p *Pool[T, R]) finish() {
+	p.closeOnce.Do(func() {
+		p.wg.Wait()
+		p.resolving.Wait()
+		p.cancel()
+		if p.metricsName != "" {
+			poolQueueDepth.Delete(p.metricsName)
+		}
+		if p.resolved != nil {
+			close(p.resolved)
+		} else {
+			close(p.finished)
+		}
+	})
+}
+
+func (p *Pool[T, R]) work(id int) {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for len(p.queue) == 0 && !p.closed && p.workers <= p.size {
+			p.cond.Wait()
+		}
+		if p.workers > p.size || len(p.queue) == 0 {
+			p.workers--
+			p.mu.Unlock()
+			return
+		}
+		t := p.queue[0]
+		p.queue = p.queue[1:]
+		p.resolving.Add(1)
+		p.cond.Broadcast()
+		p.mu.Unlock()
+
+		res, err := p.run(t)
+		p.resolve(t, WorketResult[T, R]{WorkerNum: id, Input: t.input, Result: res, Err: err})
+	}
+}
+
+// run runs the task, retrying it on failure.
+func (p *Pool[T, R]) run(t *poolTask[T, R]) (res R, err error) {
+	for attempt := 0; attempt <= p.opts.Retries; attempt++ {
+		if attempt > 0 && p.opts.RetryDelay > 0 {
+			select {
+			case <-time.After(p.opts.RetryDelay):
+			case <-t.ctx.Done():
+				return res, t.ctx.Err()
+			}
+		}
+		res, err = p.attempt(t)
+		if err == nil || t.ctx.Err() != nil {
+			return
+		}
+	}
+	return
+}
+
+func (p *Pool[T, R]) attempt(t *poolTask[T, R]) (res R, err error) {
+	ctx := t.ctx
+	if p.opts.TaskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.TaskTimeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panic: %v", r)
+		}
+	}()
+	return p.fn(ctx, t.input)
+}
+
+func (p *Pool[T, R]) resolve(t *poolTask[T, R], res WorketResult[T, R]) {
+	defer p.resolving.Done()
+	t.future.res = res
+	t.future.resolve(res.Result, res.Err)
+	if p.resolved != nil {
+		p.resolved <- t
+	}
+}
+
+// deliver sends the resolved tasks on the results channel, in submission
+// order with ResultsOrdered.
+func (p *Pool[T, R]) deliver() {
+	defer close(p.finished)
+	defer close(p.results)
+
+	if p.opts.Results != ResultsOrdered {
+		for t := range p.resolved {
+			p.results <- t.future.res
+		}
+		return
+	}
+
+	next := uint64(1)
+	pending := make(map[uint64]*poolTask[T, R])
+	for t := range p.resolved {
+		pending[t.seq] = t
+		for {
+			t, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			p.results <- t.future.res
+		}
+	}
+}
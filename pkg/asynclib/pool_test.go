@@ -0,0 +1,205 @@
+package asynclib
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func double(ctx context.Context, n int) (int, error) {
+	return n * 2, nil
+}
+
+func queueLen[T any, R any](p *Pool[T, R]) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+func TestPoolSubmit(t *testing.T) {
+	p := NewPool(PoolOptions{Workers: 2}, double)
+	defer p.Stop()
+
+	f, err := p.Submit(context.Background(), 21)
+	require.NoError(t, err)
+	res, err := f.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 42, res)
+	require.Equal(t, 21, f.Result().Input)
+	require.NotZero(t, f.Result().WorkerNum)
+}
+
+func TestPoolRejectPolicies(t *testing.T) {
+	release := make(chan struct{})
+	blocking := func(ctx context.Context, n int) (int, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return n, nil
+	}
+
+	t.Run("error", func(t *testing.T) {
+		p := NewPool(PoolOptions{Workers: 1, QueueSize: 1, Reject: RejectError}, blocking)
+		defer p.Stop()
+		_, err := p.Submit(context.Background(), 1) // running
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return queueLen(p) == 0 }, time.Second, time.Millisecond)
+		_, err = p.Submit(context.Background(), 2) // queued
+		require.NoError(t, err)
+		_, err = p.Submit(context.Background(), 3)
+		require.ErrorIs(t, err, ErrPoolFull)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		p := NewPool(PoolOptions{Workers: 1, QueueSize: 1}, blocking)
+		defer p.Stop()
+		_, _ = p.Submit(context.Background(), 1)
+		require.Eventually(t, func() bool { return queueLen(p) == 0 }, time.Second, time.Millisecond)
+		_, _ = p.Submit(context.Background(), 2)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := p.Submit(ctx, 3)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		p := NewPool(PoolOptions{Workers: 1, QueueSize: 1, Reject: RejectDropOldest}, blocking)
+		defer p.Stop()
+		_, _ = p.Submit(context.Background(), 1)
+		require.Eventually(t, func() bool { return queueLen(p) == 0 }, time.Second, time.Millisecond)
+		oldest, _ := p.Submit(context.Background(), 2)
+		_, err := p.Submit(context.Background(), 3)
+		require.NoError(t, err)
+		_, err = oldest.Wait(context.Background())
+		require.ErrorIs(t, err, ErrTaskDropped)
+	})
+
+	close(release)
+}
+
+func TestPoolRetriesAndTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	p := NewPool(PoolOptions{Workers: 1, Retries: 2, TaskTimeout: 20 * time.Millisecond}, func(ctx context.Context, n int) (int, error) {
+		if attempts.Add(1) < 3 {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return n, nil
+	})
+	defer p.Stop()
+
+	f, err := p.Submit(context.Background(), 7)
+	require.NoError(t, err)
+	res, err := f.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 7, res)
+	require.Equal(t, int32(3), attempts.Load())
+
+	f, _ = p.Submit(context.Background(), 0)
+	_, err = f.Wait(context.Background())
+	require.NoError(t, err)
+
+	panicking := NewPool(PoolOptions{}, func(ctx context.Context, n int) (int, error) { panic("boom") })
+	defer panicking.Stop()
+	f2, _ := panicking.Submit(context.Background(), 1)
+	_, err = f2.Wait(context.Background())
+	require.EqualError(t, err, "task panic: boom")
+}
+
+func TestPoolOrderedResults(t *testing.T) {
+	p := NewPool(PoolOptions{Workers: 4, QueueSize: 10, Results: ResultsOrdered}, func(ctx context.Context, n int) (int, error) {
+		time.Sleep(time.Duration(10-n) * time.Millisecond)
+		return n, nil
+	})
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			_, _ = p.Submit(context.Background(), i)
+		}
+		_ = p.Drain(context.Background())
+	}()
+
+	expected := 0
+	for res := range p.Results() {
+		require.Equal(t, expected, res.Result)
+		expected++
+	}
+	require.Equal(t, 10, expected)
+}
+
+func TestPoolResize(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	p := NewPool(PoolOptions{Workers: 1, QueueSize: 20}, func(ctx context.Context, n int) (int, error) {
+		c := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if c <= m || maxRunning.CompareAndSwap(m, c) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		return n, nil
+	})
+
+	p.Resize(4)
+	require.Equal(t, 4, p.Size())
+	for i := 0; i < 12; i++ {
+		_, err := p.Submit(context.Background(), i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, p.Drain(context.Background()))
+	require.Equal(t, int32(4), maxRunning.Load())
+
+	_, err := p.Submit(context.Background(), 1)
+	require.ErrorIs(t, err, ErrPoolClosed)
+}
+
+func TestPoolStop(t *testing.T) {
+	p := NewPool(PoolOptions{Workers: 1, QueueSize: 5}, func(ctx context.Context, n int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	running, _ := p.Submit(context.Background(), 1)
+	require.Eventually(t, func() bool { return queueLen(p) == 0 }, time.Second, time.Millisecond)
+	queued, _ := p.Submit(context.Background(), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, p.Drain(ctx), context.DeadlineExceeded)
+
+	_, err := running.Wait(context.Background())
+	require.True(t, errors.Is(err, context.Canceled))
+	_, err = queued.Wait(context.Background())
+	require.ErrorIs(t, err, ErrPoolStopped)
+}
+
+func TestPoolDrainDeadline(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan context.Context, 1)
+	p := NewPool(PoolOptions{Workers: 1}, func(ctx context.Context, n int) (int, error) {
+		started <- ctx
+		// Ignoring the context cancellation
+		<-release
+		return n, nil
+	})
+	defer close(release)
+
+	_, err := p.Submit(context.Background(), 1)
+	require.NoError(t, err)
+	taskCtx := <-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.ErrorIs(t, p.Drain(ctx), context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Eventually(t, func() bool { return taskCtx.Err() != nil }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,397 @@
+package asynclib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+// DefaultJobHistorySize is the number of runs kept in the history of each job.
+const DefaultJobHistorySize = 20
+
+var (
+	ErrJobExists   = errors.New("job already scheduled")
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// cronParser accepts the standard 5 fields expressions, an optional leading
+// seconds field, the descriptors like "@daily" and "@every 1m", and the
+// "CRON_TZ=" prefix.
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// MissedRunPolicy defines what to do with the runs whose time passed without
+// the job starting, because the previous run was still going or the
+// scheduler was late.
+type MissedRunPolicy int
+
+const (
+	// MissedRunSkip drops the missed runs.
+	MissedRunSkip MissedRunPolicy = iota
+	// MissedRunOnce runs the job once as soon as possible for all the missed runs.
+	MissedRunOnce
+)
+
+// JobFunc is the function run by a scheduled job.
+// Its context is cancelled on timeout, when the job is removed or the scheduler stopped.
+type JobFunc func(ctx context.Context) error
+
+// JobLock guards the runs of a job across the replicas of a service.
+type JobLock interface {
+	// TryLock takes the lock of the job run scheduled at the given time,
+	// reporting whether this replica has to run it.
+	TryLock(ctx context.Context, job string, scheduled time.Time) (bool, error)
+}
+
+type JobOptions struct {
+	Name string
+	// Schedule is a cron expression, e.g. "*/5 * * * *", "0 30 2 * * *" or "@every 10s".
+	Schedule string
+	// Location is the time zone of the schedule, time.Local by default.
+	Location *time.Location
+	// Jitter delays each run by a random duration up to its value.
+	Jitter time.Duration
+	// Timeout cancels the context of the runs lasting longer.
+	Timeout time.Duration
+	// AllowOverlap lets a run start while the previous one is still going.
+	AllowOverlap bool
+	MissedRuns   MissedRunPolicy
+	// Lock, if set, lets only one replica run each scheduled run.
+	Lock JobLock
+	// OnError is called with the error returned by, or the panic of, a run.
+	OnError func(name string, err error)
+}
+
+// JobRun is a record of the job history.
+type JobRun struct {
+	Job       string    `json:"job"`
+	Scheduled time.Time `json:"scheduled"`
+	Started   time.Time `json:"started,omitzero"`
+	Finished  time.Time `json:"finished,omitzero"`
+	Error     string    `json:"error,omitempty"`
+	// Skipped reports the runs not started because of the overlap, the missed run
+	// policy or the lock held by another replica.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// JobInfo describes the state of a scheduled job.
+type JobInfo struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Next     time.Time `json:"next"`
+	Running  bool      `json:"running"`
+}
+
+// Scheduler runs jobs on cron schedules.
+type Scheduler struct {
+	mu          sync.Mutex
+	jobs        map[string]*job
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	historySize int
+}
+
+type job struct {
+	opts     JobOptions
+	fn       JobFunc
+	schedule cron.Schedule
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	next     time.Time
+	running  int
+	pending  *time.Time
+	history  []JobRun
+}
+
+// NewScheduler creates a scheduler keeping historySize runs per job,
+// or DefaultJobHistorySize if not positive.
+func NewScheduler(historySize int) *Scheduler {
+	if historySize <= 0 {
+		historySize = DefaultJobHistorySize
+	}
+	return &Scheduler{jobs: make(map[string]*job), historySize: historySize}
+}
+
+// Add schedules the job. If the scheduler is started, the job starts immediately.
+func (s *Scheduler) Add(opts JobOptions, fn JobFunc) error {
+	if opts.Name == "" {
+		return errors.New("job name is required")
+	}
+	schedule, err := cronParser.Parse(opts.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule of job %s: %w", opts.Name, err)
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[opts.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, opts.Name)
+	}
+	j := &job{opts: opts, fn: fn, schedule: schedule}
+	s.jobs[opts.Name] = j
+	if s.ctx != nil {
+		s.startJob(j)
+	}
+	return nil
+}
+
+// Remove unschedules the job, cancelling its running context.
+func (s *Scheduler) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if j.cancel != nil {
+		j.cancel()
+	}
+	delete(s.jobs, name)
+	return nil
+}
+
+// Start starts scheduling the jobs.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, j := range s.jobs {
+		s.startJob(j)
+	}
+}
+
+// Stop stops scheduling the jobs, cancels the running ones and waits for them
+// to return, until ctx is done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.cancel()
+	s.ctx = nil
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Component returns the svc lifecycle component running the scheduler.
+func (s *Scheduler) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			s.Start()
+			return nil
+		},
+		Stop: s.Stop,
+	}
+}
+
+// Jobs returns the state of the scheduled jobs, sorted by name.
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		res = append(res, JobInfo{
+			Name:     j.opts.Name,
+			Schedule: j.opts.Schedule,
+			Next:     j.next,
+			Running:  j.running > 0,
+		})
+		j.mu.Unlock()
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].Name < res[b].Name })
+	return res
+}
+
+// History returns the last runs of the job, the oldest first.
+func (s *Scheduler) History(name string) ([]JobRun, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	res := make([]JobRun, len(j.history))
+	copy(res, j.history)
+	return res, nil
+}
+
+func (s *Scheduler) startJob(j *job) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	j.cancel = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		svc.Run("asynclib.scheduler", func() { s.loop(ctx, j) })
+	}()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	next := j.schedule.Next(time.Now().In(j.opts.Location))
+	for {
+		j.mu.Lock()
+		j.next = next
+		j.mu.Unlock()
+
+		if next.IsZero() {
+			// the schedule has no more runs
+			<-ctx.Done()
+			return
+		}
+
+		delay := time.Until(next)
+		if j.opts.Jitter > 0 {
+			delay += rand.N(j.opts.Jitter)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.trigger(ctx, j, next)
+
+		// the runs due while the scheduler was late
+		now := time.Now().In(j.opts.Location)
+		missed := next
+		for t := j.schedule.Next(next); !t.IsZero() && !t.After(now); t = j.schedule.Next(t) {
+			missed = t
+		}
+		if missed != next {
+			if j.opts.MissedRuns == MissedRunOnce {
+				s.trigger(ctx, j, missed)
+			} else {
+				s.record(j, JobRun{Job: j.opts.Name, Scheduled: missed, Skipped: true})
+			}
+		}
+		next = j.schedule.Next(now)
+	}
+}
+
+// trigger starts the run scheduled at the given time, unless prevented
+// by a running one.
+func (s *Scheduler) trigger(ctx context.Context, j *job, scheduled time.Time) {
+	j.mu.Lock()
+	if j.running > 0 && !j.opts.AllowOverlap {
+		if j.opts.MissedRuns == MissedRunOnce {
+			j.pending = &scheduled
+			j.mu.Unlock()
+			return
+		}
+		j.mu.Unlock()
+		s.record(j, JobRun{Job: j.opts.Name, Scheduled: scheduled, Skipped: true})
+		return
+	}
+	j.running++
+	j.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			s.run(ctx, j, scheduled)
+
+			j.mu.Lock()
+			pending := j.pending
+			j.pending = nil
+			if pending == nil || ctx.Err() != nil {
+				j.running--
+				j.mu.Unlock()
+				return
+			}
+			j.mu.Unlock()
+			scheduled = *pending
+		}
+	}()
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job, scheduled time.Time) {
+	name := j.opts.Name
+	if j.opts.Lock != nil {
+		locked, err := j.opts.Lock.TryLock(ctx, name, scheduled)
+		if err != nil {
+			s.fail(j, JobRun{Job: name, Scheduled: scheduled, Skipped: true}, fmt.Errorf("cannot lock job: %w", err))
+			return
+		}
+		if !locked {
+			s.record(j, JobRun{Job: name, Scheduled: scheduled, Skipped: true})
+			return
+		}
+	}
+
+	if j.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.opts.Timeout)
+		defer cancel()
+	}
+
+	res := JobRun{Job: name, Scheduled: scheduled, Started: time.Now()}
+	err := runJob(ctx, j.fn)
+	res.Finished = time.Now()
+	if err != nil {
+		s.fail(j, res, err)
+		return
+	}
+	s.record(j, res)
+}
+
+func runJob(ctx context.Context, fn JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func (s *Scheduler) fail(j *job, res JobRun, err error) {
+	res.Error = err.Error()
+	s.record(j, res)
+	if j.opts.OnError != nil {
+		j.opts.OnError(j.opts.Name, err)
+		return
+	}
+	_ = svc.Error("Scheduled job failed", err, "job", j.opts.Name)
+}
+
+func (s *Scheduler) record(j *job, res JobRun) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.history = append(j.history, res)
+	if len(j.history) > s.historySize {
+		j.history = j.history[len(j.history)-s.historySize:]
+	}
+}
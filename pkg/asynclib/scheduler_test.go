@@ -0,0 +1,130 @@
+package asynclib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryJobLock struct {
+	mu    sync.Mutex
+	taken map[string]bool
+}
+
+func (l *memoryJobLock) TryLock(ctx context.Context, job string, scheduled time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := job + scheduled.String()
+	if l.taken[key] {
+		return false, nil
+	}
+	l.taken[key] = true
+	return true, nil
+}
+
+func TestSchedulerRunsJob(t *testing.T) {
+	s := NewScheduler(0)
+	var runs atomic.Int32
+	var errs atomic.Int32
+	require.NoError(t, s.Add(JobOptions{
+		Name:     "job",
+		Schedule: "@every 1s",
+		OnError:  func(name string, err error) { errs.Add(1) },
+	}, func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+
+	s.Start()
+	time.Sleep(2500 * time.Millisecond)
+	require.NoError(t, s.Stop(context.Background()))
+
+	require.GreaterOrEqual(t, runs.Load(), int32(2))
+	require.Equal(t, int32(1), errs.Load())
+
+	history, err := s.History("job")
+	require.NoError(t, err)
+	require.Len(t, history, int(runs.Load()))
+	require.Equal(t, "failed", history[0].Error)
+	require.Empty(t, history[1].Error)
+
+	jobs := s.Jobs()
+	require.Len(t, jobs, 1)
+	require.Equal(t, "job", jobs[0].Name)
+	require.False(t, jobs[0].Running)
+}
+
+func TestSchedulerPreventsOverlap(t *testing.T) {
+	s := NewScheduler(0)
+	var running, maxRunning atomic.Int32
+	require.NoError(t, s.Add(JobOptions{Name: "slow", Schedule: "@every 1s"}, func(ctx context.Context) error {
+		n := running.Add(1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		defer running.Add(-1)
+		select {
+		case <-time.After(1500 * time.Millisecond):
+		case <-ctx.Done():
+		}
+		return nil
+	}))
+
+	s.Start()
+	time.Sleep(2200 * time.Millisecond)
+	require.NoError(t, s.Stop(context.Background()))
+
+	require.Equal(t, int32(1), maxRunning.Load())
+	history, err := s.History("slow")
+	require.NoError(t, err)
+	require.True(t, history[0].Skipped, "the overlapping run should be skipped")
+}
+
+func TestSchedulerLock(t *testing.T) {
+	lock := &memoryJobLock{taken: map[string]bool{}}
+	fn := func(ctx context.Context) error { return nil }
+
+	a, b := NewScheduler(0), NewScheduler(0)
+	require.NoError(t, a.Add(JobOptions{Name: "job", Schedule: "@every 1s", Lock: lock}, fn))
+	require.NoError(t, b.Add(JobOptions{Name: "job", Schedule: "@every 1s", Lock: lock}, fn))
+
+	a.Start()
+	b.Start()
+	time.Sleep(1500 * time.Millisecond)
+	require.NoError(t, a.Stop(context.Background()))
+	require.NoError(t, b.Stop(context.Background()))
+
+	ha, err := a.History("job")
+	require.NoError(t, err)
+	hb, err := b.History("job")
+	require.NoError(t, err)
+
+	runs := map[time.Time]int{}
+	for _, run := range append(ha, hb...) {
+		if !run.Skipped {
+			runs[run.Scheduled]++
+		}
+	}
+	require.NotEmpty(t, runs)
+	for scheduled, n := range runs {
+		require.Equal(t, 1, n, "run at %s executed by both schedulers", scheduled)
+	}
+}
+
+func TestSchedulerAddErrors(t *testing.T) {
+	s := NewScheduler(0)
+	fn := func(ctx context.Context) error { return nil }
+
+	require.Error(t, s.Add(JobOptions{Name: "bad", Schedule: "not a cron"}, fn))
+	require.NoError(t, s.Add(JobOptions{Name: "job", Schedule: "CRON_TZ=Europe/Rome 0 3 * * *"}, fn))
+	require.ErrorIs(t, s.Add(JobOptions{Name: "job", Schedule: "@daily"}, fn), ErrJobExists)
+	require.NoError(t, s.Remove("job"))
+	require.ErrorIs(t, s.Remove("job"), ErrJobNotFound)
+}
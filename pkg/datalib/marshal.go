@@ -2,10 +2,14 @@ package datalib
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"reflect"
 	"strings"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -13,6 +17,9 @@ const (
 	TypeMsgpack  = "application/msgpack"
 	TypeXMsgpack = "application/x-msgpack"
 	TypeProtobuf = "application/protobuf"
+	TypeXML      = "application/xml"
+	TypeTextXML  = "text/xml"
+	TypeCBOR     = "application/cbor"
 )
 
 func MarshalBody[T any](typ string, data *T) (reqBytes []byte, err error) {
@@ -21,6 +28,18 @@ func MarshalBody[T any](typ string, data *T) (reqBytes []byte, err error) {
 		reqBytes, err = json.Marshal(*data)
 	case TypeMsgpack, TypeXMsgpack:
 		reqBytes, err = msgpack.Marshal(*data)
+	case TypeXML, TypeTextXML:
+		reqBytes, err = xml.Marshal(*data)
+	case TypeCBOR:
+		reqBytes, err = cbor.Marshal(*data)
+	case TypeProtobuf:
+		if m, ok := any(data).(proto.Message); ok {
+			reqBytes, err = proto.Marshal(m)
+		} else if m, ok := any(*data).(proto.Message); ok {
+			reqBytes, err = proto.Marshal(m)
+		} else {
+			err = fmt.Errorf("not a protobuf Message")
+		}
 	default:
 		err = fmt.Errorf("unknown type: %s", typ)
 	}
@@ -34,6 +53,24 @@ func UnmarshalBody[R any](typ string, resBody []byte) (data R, err error) {
 		err = json.Unmarshal(resBody, &data)
 	case TypeMsgpack, TypeXMsgpack:
 		err = msgpack.Unmarshal(resBody, &data)
+	case TypeXML, TypeTextXML:
+		err = xml.Unmarshal(resBody, &data)
+	case TypeCBOR:
+		err = cbor.Unmarshal(resBody, &data)
+	case TypeProtobuf:
+		var m proto.Message
+		if t := reflect.TypeFor[R](); t.Kind() == reflect.Pointer {
+			// R is the message pointer: allocate the message to fill
+			data = reflect.New(t.Elem()).Interface().(R)
+			m, _ = any(data).(proto.Message)
+		} else {
+			m, _ = any(&data).(proto.Message)
+		}
+		if m != nil {
+			err = proto.Unmarshal(resBody, m)
+		} else {
+			err = fmt.Errorf("not a protobuf Message")
+		}
 	default:
 		err = fmt.Errorf("unknown type: %s", typ)
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func TestMarshalBody(t *testing.T) {
@@ -36,6 +37,16 @@ func TestMarshalBody(t *testing.T) {
 			typ:     TypeXMsgpack,
 			wantErr: false,
 		},
+		{
+			name:    "cbor",
+			typ:     TypeCBOR,
+			wantErr: false,
+		},
+		{
+			name:    "protobuf",
+			typ:     TypeProtobuf,
+			wantErr: true,
+		},
 		{
 			name:    "unknown type",
 			typ:     "unknown",
@@ -155,3 +166,30 @@ func TestUnmarshalMsgpackBody(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshalBodyRoundTrip(t *testing.T) {
+	for _, typ := range []string{TypeJson, TypeMsgpack, TypeXML, TypeTextXML, TypeCBOR} {
+		t.Run(typ, func(t *testing.T) {
+			data, err := MarshalBody(typ, &foo{Foo: "foo", Bar: 123})
+			require.NoError(t, err)
+
+			dst, err := UnmarshalBody[foo](typ+"; charset=utf-8", data)
+			require.NoError(t, err)
+			require.Equal(t, foo{Foo: "foo", Bar: 123}, dst)
+		})
+	}
+}
+
+func TestMarshalBodyProtobuf(t *testing.T) {
+	msg := wrapperspb.String("hello")
+	data, err := MarshalBody(TypeProtobuf, &msg)
+	require.NoError(t, err)
+
+	dst, err := UnmarshalBody[*wrapperspb.StringValue](TypeProtobuf, data)
+	require.NoError(t, err)
+	require.NotNil(t, dst)
+	require.Equal(t, "hello", dst.GetValue())
+
+	_, err = UnmarshalBody[*foo](TypeProtobuf, data)
+	require.Error(t, err)
+}
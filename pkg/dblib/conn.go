@@ -1,9 +1,11 @@
 package dblib
 
 import (
+	"context"
 	"fmt"
 	"time"
 
+	"github.com/sandrolain/gomsvc/pkg/svc"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -29,6 +31,9 @@ type Config struct {
 	TimeZone      string `validate:"required_with_all=Username Password Host Port Database SSLMode,required_without=DSN"`
 	SSLMode       string `validate:"required_with_all=Username Password Host Port Database TimeZone,required_without=DSN"`
 	SlowThreshold time.Duration
+	// HealthCheckName registers a svc health check of the database when set,
+	// and must be unique among the connections of the service
+	HealthCheckName string
 }
 
 func FromEnvConfig(cfg EnvConfig) Config {
@@ -53,15 +58,50 @@ func FormatPostgresDSN(cfg Config) string {
 }
 
 func GormOpenPostgres(cfg Config) (db *gorm.DB, err error) {
-	return GormOpen(
+	db, err = GormOpen(
 		postgres.Open(FormatPostgresDSN(cfg)),
 		cfg.SlowThreshold,
 	)
+	if err != nil {
+		return
+	}
+	if cfg.HealthCheckName != "" {
+		svc.AddHealthCheck(cfg.HealthCheckName, HealthCheck(db))
+	}
+	return
 }
 
+// GormOpen opens the connection without health checks, that the caller
+// can register with svc.AddHealthCheck and HealthCheck
 func GormOpen(dialector gorm.Dialector, slowThreshold time.Duration) (db *gorm.DB, err error) {
 	db, err = gorm.Open(dialector, &gorm.Config{
 		Logger: NewGormSlog(slowThreshold),
 	})
 	return
 }
+
+// HealthCheck returns the health check pinging the database
+func HealthCheck(db *gorm.DB) svc.HealthCheckFunc {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// Component returns the svc lifecycle component closing the database pool on stop.
+func Component(name string, db *gorm.DB, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Stop: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Close()
+		},
+	}
+}
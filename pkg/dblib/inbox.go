@@ -0,0 +1,96 @@
+package dblib
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/inboxlib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const DefaultInboxTable = "inbox"
+
+// InboxRecord is the processing record of a message in the inbox table.
+type InboxRecord struct {
+	Consumer  string `gorm:"primaryKey"`
+	MessageID string `gorm:"primaryKey"`
+	Status    string `gorm:"not null"`
+	Error     string
+	UpdatedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// InboxStore stores the inbox records in a Postgres table. The expired
+// records are deleted by Cleanup.
+type InboxStore struct {
+	db    *gorm.DB
+	table string
+}
+
+var _ inboxlib.Store = (*InboxStore)(nil)
+
+// NewInboxStore returns the inbox store on the table, DefaultInboxTable if empty.
+func NewInboxStore(db *gorm.DB, table string) *InboxStore {
+	if table == "" {
+		table = DefaultInboxTable
+	}
+	return &InboxStore{db: db, table: table}
+}
+
+// Migrate creates or updates the inbox table.
+func (s *InboxStore) Migrate() error {
+	return s.db.Table(s.table).AutoMigrate(&InboxRecord{})
+}
+
+func (s *InboxStore) Claim(ctx context.Context, consumer string, id string, timeout time.Duration) (inboxlib.Status, error) {
+	now := time.Now()
+	table := clause.Table{Name: s.table}
+	res := s.db.WithContext(ctx).Exec(`INSERT INTO ? (consumer, message_id, status, error, updated_at, expires_at)
+		VALUES (?, ?, ?, '', ?, ?)
+		ON CONFLICT (consumer, message_id) DO UPDATE
+		SET status = EXCLUDED.status, error = '', updated_at = EXCLUDED.updated_at, expires_at = EXCLUDED.expires_at
+		WHERE ?.status = ? OR ?.expires_at < ?`,
+		table, consumer, id, string(inboxlib.StatusProcessing), now, now.Add(timeout),
+		table, string(inboxlib.StatusFailed), table, now,
+	)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 1 {
+		return "", nil
+	}
+
+	// A record changed after the insert attempt is reported as processing
+	var rec InboxRecord
+	err := s.db.WithContext(ctx).Table(s.table).
+		Where("consumer = ? AND message_id = ?", consumer, id).
+		Take(&rec).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if inboxlib.Status(rec.Status) == inboxlib.StatusDone {
+		return inboxlib.StatusDone, nil
+	}
+	return inboxlib.StatusProcessing, nil
+}
+
+func (s *InboxStore) Record(ctx context.Context, consumer string, rec inboxlib.Record, retention time.Duration) error {
+	return s.db.WithContext(ctx).Table(s.table).
+		Where("consumer = ? AND message_id = ?", consumer, rec.ID).
+		Updates(map[string]any{
+			"status":     string(rec.Status),
+			"error":      rec.Error,
+			"updated_at": rec.UpdatedAt,
+			"expires_at": rec.UpdatedAt.Add(retention),
+		}).Error
+}
+
+// Cleanup deletes the expired records.
+func (s *InboxStore) Cleanup(ctx context.Context) (int64, error) {
+	res := s.db.WithContext(ctx).Table(s.table).
+		Where("expires_at < ?", time.Now()).
+		Delete(&InboxRecord{})
+	return res.RowsAffected, res.Error
+}
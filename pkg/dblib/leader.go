@@ -0,0 +1,91 @@
+package dblib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"gorm.io/gorm"
+)
+
+// AdvisoryLock is a leader lock held through a Postgres session-level
+// advisory lock. The lock lives as long as its dedicated connection, so it
+// is released by the database also when the replica dies.
+type AdvisoryLock struct {
+	db   *gorm.DB
+	key  int64
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewAdvisoryLock returns the leader lock on the advisory lock key derived from name.
+func NewAdvisoryLock(db *gorm.DB, name string) *AdvisoryLock {
+	return &AdvisoryLock{db: db, key: advisoryLockKey(name)}
+}
+
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+func (l *AdvisoryLock) Acquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cannot get connection: %w", err)
+	}
+
+	var held bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&held); err != nil || !held {
+		_ = conn.Close()
+		return false, err
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Renew checks that the connection holding the lock is still alive.
+func (l *AdvisoryLock) Renew(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return false, nil
+	}
+	if err := l.conn.PingContext(ctx); err != nil {
+		_ = l.conn.Close()
+		l.conn = nil
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *AdvisoryLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	if cerr := l.conn.Close(); err == nil {
+		err = cerr
+	}
+	l.conn = nil
+	return err
+}
+
+// NewLeader returns the svc leader elected through the advisory lock of name.
+func NewLeader(db *gorm.DB, name string, opts svc.LeaderOptions) *svc.Leader {
+	return svc.NewLeader(name, NewAdvisoryLock(db, name), opts)
+}
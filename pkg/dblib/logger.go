@@ -44,16 +44,16 @@ func (l *GormSlog) Error(ctx context.Context, msg string, args ...interface{}) {
 func (l *GormSlog) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
 
 	elapsed := time.Since(begin)
+	sql, rows := fc()
+	observeQuery(sql, elapsed, err)
+
 	switch {
 	case err != nil && err != gorm.ErrRecordNotFound:
-		sql, rows := fc()
 		l.log(slog.LevelError, ctx, err.Error(), []interface{}{"rows", rows, "sql", sql, "elapsed", float64(elapsed.Nanoseconds()) / 1e6})
 	case elapsed > l.SlowThreshold && l.SlowThreshold != 0:
-		sql, rows := fc()
 		slowLog := fmt.Sprintf("SLOW SQL >= %v", l.SlowThreshold)
 		l.log(slog.LevelWarn, ctx, slowLog, []interface{}{"rows", rows, "sql", sql, "elapsed", float64(elapsed.Nanoseconds()) / 1e6})
 	default:
-		sql, rows := fc()
 		l.log(slog.LevelDebug, ctx, "sql trace", []interface{}{"rows", rows, "sql", sql, "elapsed", float64(elapsed.Nanoseconds()) / 1e6})
 	}
 
@@ -0,0 +1,34 @@
+package dblib
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"gorm.io/gorm"
+)
+
+var queryDuration = svc.RegisterMetric(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "db_query_duration_seconds",
+	Help:    "Duration of the SQL queries by operation and status.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"operation", "status"}))
+
+func observeQuery(sql string, elapsed time.Duration, err error) {
+	status := "ok"
+	if err != nil && err != gorm.ErrRecordNotFound {
+		status = "error"
+	}
+	queryDuration.WithLabelValues(queryOperation(sql), status).Observe(elapsed.Seconds())
+}
+
+// queryOperation returns the SQL statement keyword, such as SELECT or INSERT.
+func queryOperation(sql string) string {
+	op, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	switch op = strings.ToUpper(op); op {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "WITH", "CREATE", "ALTER", "DROP", "BEGIN", "COMMIT", "ROLLBACK", "SAVEPOINT":
+		return op
+	}
+	return "OTHER"
+}
@@ -0,0 +1,163 @@
+package dblib
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/outboxlib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const DefaultOutboxTable = "outbox"
+
+// OutboxRecord is a message stored in the outbox table.
+type OutboxRecord struct {
+	Seq         uint64            `gorm:"primaryKey;autoIncrement"`
+	Topic       string            `gorm:"not null"`
+	Key         string            `gorm:"index"`
+	Payload     []byte            `gorm:"not null"`
+	Headers     map[string]string `gorm:"serializer:json"`
+	Attempts    int               `gorm:"not null;default:0"`
+	LastError   string
+	FailedAt    *time.Time
+	RetryAt     *time.Time `gorm:"index"`
+	CreatedAt   time.Time
+	DeliveredAt *time.Time `gorm:"index"`
+	DeadAt      *time.Time `gorm:"index"`
+}
+
+var _ outboxlib.Store = (*Outbox)(nil)
+
+// Outbox is the outbox store on a gorm table.
+type Outbox struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewOutbox returns the outbox store on the table, DefaultOutboxTable if empty.
+func NewOutbox(db *gorm.DB, table string) *Outbox {
+	if table == "" {
+		table = DefaultOutboxTable
+	}
+	return &Outbox{db: db, table: table}
+}
+
+// Migrate creates or updates the outbox table.
+func (o *Outbox) Migrate() error {
+	return o.db.Table(o.table).AutoMigrate(&OutboxRecord{})
+}
+
+// Add writes the messages in the outbox within the transaction tx.
+func (o *Outbox) Add(tx *gorm.DB, msgs ...outboxlib.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	records := make([]OutboxRecord, len(msgs))
+	for i, msg := range msgs {
+		records[i] = OutboxRecord{
+			Topic:     msg.Topic,
+			Key:       msg.Key,
+			Payload:   msg.Payload,
+			Headers:   msg.Headers,
+			CreatedAt: msg.CreatedAt,
+		}
+	}
+	return tx.Table(o.table).Create(&records).Error
+}
+
+func (o *Outbox) Pending(ctx context.Context, limit int) ([]outboxlib.Message, error) {
+	now := time.Now()
+	key := clause.Column{Name: "key"}
+	// The keys of the messages waiting for their retry are held back
+	blocked := o.db.Table(o.table).
+		Select("?", key).
+		Where("delivered_at IS NULL AND dead_at IS NULL AND retry_at > ? AND ? <> ''", now, key)
+
+	var records []OutboxRecord
+	err := o.db.WithContext(ctx).Table(o.table).
+		Where("delivered_at IS NULL AND dead_at IS NULL AND (retry_at IS NULL OR retry_at <= ?)", now).
+		Where("? = '' OR ? NOT IN (?)", key, key, blocked).
+		Order("seq").
+		Limit(limit).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]outboxlib.Message, len(records))
+	for i, r := range records {
+		msgs[i] = outboxlib.Message{
+			ID:        strconv.FormatUint(r.Seq, 10),
+			Topic:     r.Topic,
+			Key:       r.Key,
+			Payload:   r.Payload,
+			Headers:   r.Headers,
+			Attempts:  r.Attempts,
+			LastError: r.LastError,
+			CreatedAt: r.CreatedAt,
+		}
+		if r.FailedAt != nil {
+			msgs[i].FailedAt = *r.FailedAt
+		}
+		if r.RetryAt != nil {
+			msgs[i].RetryAt = *r.RetryAt
+		}
+	}
+	return msgs, nil
+}
+
+func (o *Outbox) MarkDelivered(ctx context.Context, ids []string) error {
+	seqs := make([]uint64, len(ids))
+	for i, id := range ids {
+		seq, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return err
+		}
+		seqs[i] = seq
+	}
+	return o.db.WithContext(ctx).Table(o.table).
+		Where("seq IN ?", seqs).
+		Update("delivered_at", time.Now()).Error
+}
+
+func (o *Outbox) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	return o.markFailed(ctx, id, cause, retryAt, false)
+}
+
+func (o *Outbox) MarkDead(ctx context.Context, id string, cause error) error {
+	return o.markFailed(ctx, id, cause, time.Time{}, true)
+}
+
+func (o *Outbox) markFailed(ctx context.Context, id string, cause error, retryAt time.Time, dead bool) error {
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return err
+	}
+	if cause == nil {
+		cause = errors.New("unknown error")
+	}
+	now := time.Now()
+	updates := map[string]any{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": cause.Error(),
+		"failed_at":  now,
+	}
+	if dead {
+		updates["dead_at"] = now
+	} else {
+		updates["retry_at"] = retryAt
+	}
+	return o.db.WithContext(ctx).Table(o.table).
+		Where("seq = ?", seq).
+		Updates(updates).Error
+}
+
+func (o *Outbox) Cleanup(ctx context.Context, before time.Time) (int64, error) {
+	res := o.db.WithContext(ctx).Table(o.table).
+		Where("delivered_at < ?", before).
+		Delete(&OutboxRecord{})
+	return res.RowsAffected, res.Error
+}
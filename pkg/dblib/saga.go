@@ -0,0 +1,174 @@
+package dblib
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/sagalib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const DefaultSagaTable = "sagas"
+
+// SagaRecord is the state of a saga in the saga table.
+type SagaRecord struct {
+	ID        string `gorm:"primaryKey"`
+	Saga      string `gorm:"not null;index:idx_saga_deadline,priority:1"`
+	Status    string `gorm:"not null;index"`
+	Step      int    `gorm:"not null"`
+	StepName  string
+	Data      []byte
+	Error     string
+	Attempts  int        `gorm:"not null;default:0"`
+	Deadline  *time.Time `gorm:"index:idx_saga_deadline,priority:2"`
+	Version   int        `gorm:"not null"`
+	CreatedAt time.Time  `gorm:"index"`
+	UpdatedAt time.Time
+}
+
+var _ sagalib.Store = (*SagaStore)(nil)
+
+// SagaStore stores the state of the sagas in a Postgres table.
+type SagaStore struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewSagaStore returns the saga store on the table, DefaultSagaTable if empty.
+func NewSagaStore(db *gorm.DB, table string) *SagaStore {
+	if table == "" {
+		table = DefaultSagaTable
+	}
+	return &SagaStore{db: db, table: table}
+}
+
+// Migrate creates or updates the saga table.
+func (s *SagaStore) Migrate() error {
+	return s.db.Table(s.table).AutoMigrate(&SagaRecord{})
+}
+
+func (s *SagaStore) Create(ctx context.Context, state *sagalib.State) error {
+	rec := sagaRecord(state)
+	res := s.db.WithContext(ctx).Table(s.table).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&rec)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return sagalib.ErrExists
+	}
+	return nil
+}
+
+func (s *SagaStore) Get(ctx context.Context, id string) (*sagalib.State, error) {
+	var rec SagaRecord
+	err := s.db.WithContext(ctx).Table(s.table).Where("id = ?", id).Take(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, sagalib.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sagaState(rec), nil
+}
+
+func (s *SagaStore) Update(ctx context.Context, state *sagalib.State) error {
+	res := s.db.WithContext(ctx).Table(s.table).
+		Where("id = ? AND version = ?", state.ID, state.Version).
+		Updates(map[string]any{
+			"status":     string(state.Status),
+			"step":       state.Step,
+			"step_name":  state.StepName,
+			"data":       []byte(state.Data),
+			"error":      state.Error,
+			"attempts":   state.Attempts,
+			"deadline":   state.Deadline,
+			"version":    state.Version + 1,
+			"updated_at": state.UpdatedAt,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return sagalib.ErrConflict
+	}
+	state.Version++
+	return nil
+}
+
+func (s *SagaStore) Expired(ctx context.Context, saga string, before time.Time, limit int) ([]*sagalib.State, error) {
+	var records []SagaRecord
+	err := s.db.WithContext(ctx).Table(s.table).
+		Where("saga = ? AND deadline < ? AND status IN ?", saga, before,
+			[]string{string(sagalib.StatusRunning), string(sagalib.StatusCompensating)}).
+		Order("deadline").
+		Limit(limit).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return sagaStates(records), nil
+}
+
+func (s *SagaStore) Find(ctx context.Context, filter sagalib.Filter) ([]*sagalib.State, error) {
+	q := s.db.WithContext(ctx).Table(s.table)
+	if filter.Saga != "" {
+		q = q.Where("saga = ?", filter.Saga)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", string(filter.Status))
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	var records []SagaRecord
+	if err := q.Order("created_at DESC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return sagaStates(records), nil
+}
+
+func sagaRecord(st *sagalib.State) SagaRecord {
+	return SagaRecord{
+		ID:        st.ID,
+		Saga:      st.Saga,
+		Status:    string(st.Status),
+		Step:      st.Step,
+		StepName:  st.StepName,
+		Data:      st.Data,
+		Error:     st.Error,
+		Attempts:  st.Attempts,
+		Deadline:  st.Deadline,
+		Version:   st.Version,
+		CreatedAt: st.CreatedAt,
+		UpdatedAt: st.UpdatedAt,
+	}
+}
+
+func sagaState(r SagaRecord) *sagalib.State {
+	return &sagalib.State{
+		ID:        r.ID,
+		Saga:      r.Saga,
+		Status:    sagalib.Status(r.Status),
+		Step:      r.Step,
+		StepName:  r.StepName,
+		Data:      r.Data,
+		Error:     r.Error,
+		Attempts:  r.Attempts,
+		Deadline:  r.Deadline,
+		Version:   r.Version,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
+
+func sagaStates(records []SagaRecord) []*sagalib.State {
+	states := make([]*sagalib.State, len(records))
+	for i, r := range records {
+		states[i] = sagaState(r)
+	}
+	return states
+}
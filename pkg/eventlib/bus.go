@@ -0,0 +1,382 @@
+package eventlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+var (
+	// ErrBusClosed is returned when publishing or subscribing to a closed bus.
+	ErrBusClosed = errors.New("event bus is closed")
+	// ErrInvalidTopic is returned for empty topics or segments, and for
+	// wildcards in published topics or not allowed positions.
+	ErrInvalidTopic = errors.New("invalid event topic")
+	// ErrNoResponder is returned by Request when no responder handles the topic and the payload type.
+	ErrNoResponder = errors.New("no responder for the request")
+	// ErrReplyType is returned by Request when the reply is not of the requested type.
+	ErrReplyType = errors.New("unexpected reply type")
+)
+
+const (
+	// topicSeparator separates the segments of the topics
+	topicSeparator = "."
+	// wildcardOne matches exactly one segment of the topic
+	wildcardOne = "*"
+	// wildcardRest matches one or more trailing segments of the topic
+	wildcardRest = ">"
+)
+
+// Handler handles the events published on a topic matching a subscription.
+type Handler[T any] func(ctx context.Context, topic string, payload T) error
+
+// ReplyHandler handles the requests sent on a topic, returning the reply.
+type ReplyHandler[Req any, Res any] func(ctx context.Context, topic string, req Req) (Res, error)
+
+type SubscribeOptions struct {
+	// Concurrency is the number of events handled at the same time, 1 by
+	// default to handle them in the publish order.
+	Concurrency int
+	// BufferSize is the number of events queued waiting for a handler.
+	BufferSize int
+	// DropWhenFull makes Publish drop the event instead of waiting when the buffer is full.
+	DropWhenFull bool
+	// OnError receives the errors and the panics of the handler, which are logged if not set.
+	OnError OnErrorFn
+}
+
+type busEvent struct {
+	ctx     context.Context
+	topic   string
+	payload any
+}
+
+// Subscription is a handler subscribed to a bus.
+type Subscription struct {
+	bus     *Bus
+	id      uint64
+	pattern []string
+	opts    SubscribeOptions
+	accepts func(payload any) bool
+	handle  func(ctx context.Context, topic string, payload any) error
+	reply   func(ctx context.Context, topic string, payload any) (any, error)
+
+	ch        chan busEvent
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Bus is an in-process event bus routing the events on dot-separated topics.
+// Subscriptions use patterns where "*" matches one segment and ">", as last
+// segment, matches all the remaining ones: "orders.*.created", "orders.>".
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[uint64]*Subscription
+	nextID uint64
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewBus creates an event bus. It must be closed to drain the pending events.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[uint64]*Subscription)}
+}
+
+// Subscribe subscribes handler to the events published on the topics
+// matching pattern whose payload is of type T.
+func Subscribe[T any](b *Bus, pattern string, handler Handler[T], opts SubscribeOptions) (*Subscription, error) {
+	s := &Subscription{
+		opts:    opts,
+		accepts: accepts[T],
+		handle: func(ctx context.Context, topic string, payload any) error {
+			return handler(ctx, topic, payload.(T))
+		},
+	}
+	s.ch = make(chan busEvent, max(opts.BufferSize, 0))
+	s.done = make(chan struct{})
+	for i := 0; i < max(opts.Concurrency, 1); i++ {
+		s.wg.Add(1)
+		svc.Go("eventlib.bus", s.work)
+	}
+	if err := b.add(pattern, s); err != nil {
+		close(s.done)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reply registers handler as responder to the requests sent on the topics
+// matching pattern whose payload is of type Req.
+func Reply[Req any, Res any](b *Bus, pattern string, handler ReplyHandler[Req, Res]) (*Subscription, error) {
+	s := &Subscription{
+		accepts: accepts[Req],
+		reply: func(ctx context.Context, topic string, payload any) (any, error) {
+			return handler(ctx, topic, payload.(Req))
+		},
+	}
+	if err := b.add(pattern, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Publish sends the event to the subscriptions matching the topic, waiting
+// for room in their buffers unless they drop the events when full.
+// The handlers receive ctx without its cancellation.
+func Publish[T any](ctx context.Context, b *Bus, topic string, payload T) error {
+	segments, err := parseTopic(topic, false)
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBusClosed
+	}
+	subs := make([]*Subscription, 0)
+	for _, s := range b.subs {
+		if s.reply == nil && s.accepts(payload) && matchTopic(s.pattern, segments) {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	ev := busEvent{ctx: context.WithoutCancel(ctx), topic: topic, payload: payload}
+	for _, s := range subs {
+		if err := s.enqueue(ctx, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Request sends the request to the first registered responder matching the
+// topic and the payload type, and returns its reply. The responder runs in
+// the calling goroutine.
+func Request[Req any, Res any](ctx context.Context, b *Bus, topic string, req Req) (res Res, err error) {
+	segments, err := parseTopic(topic, false)
+	if err != nil {
+		return
+	}
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return res, ErrBusClosed
+	}
+	var responder *Subscription
+	for _, s := range b.subs {
+		if s.reply != nil && s.accepts(req) && matchTopic(s.pattern, segments) && (responder == nil || s.id < responder.id) {
+			responder = s
+		}
+	}
+	b.mu.RUnlock()
+
+	if responder == nil {
+		return res, fmt.Errorf("%w: %s", ErrNoResponder, topic)
+	}
+
+	reply, err := callReply(ctx, responder, topic, req)
+	if err != nil {
+		return
+	}
+	res, ok := reply.(Res)
+	if !ok && reply != nil {
+		return res, fmt.Errorf("%w: %T", ErrReplyType, reply)
+	}
+	return res, nil
+}
+
+func callReply(ctx context.Context, s *Subscription, topic string, req any) (reply any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in reply handler: %v", r)
+		}
+	}()
+	return s.reply(ctx, topic, req)
+}
+
+// Close stops accepting events and waits for the subscriptions to handle
+// the queued ones, or for ctx to be done.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = make(map[uint64]*Subscription)
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Component returns the svc lifecycle component closing the bus on stop.
+func (b *Bus) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Stop:      b.Close,
+	}
+}
+
+func (b *Bus) add(pattern string, s *Subscription) error {
+	segments, err := parseTopic(pattern, true)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrBusClosed
+	}
+	b.nextID++
+	s.bus = b
+	s.id = b.nextID
+	s.pattern = segments
+	b.subs[s.id] = s
+	b.wg.Add(1)
+	return nil
+}
+
+// Unsubscribe stops the delivery of new events to the subscription.
+// The events already queued are still handled.
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	_, ok := s.bus.subs[s.id]
+	delete(s.bus.subs, s.id)
+	s.bus.mu.Unlock()
+	if ok {
+		s.close()
+	}
+}
+
+func (s *Subscription) close() {
+	if s.reply != nil {
+		s.bus.wg.Done()
+		return
+	}
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+
+	go func() {
+		s.wg.Wait()
+		s.bus.wg.Done()
+	}()
+}
+
+// enqueue queues the event, unless the subscription is closed. The channel
+// is never closed, the closure is signalled by done.
+func (s *Subscription) enqueue(ctx context.Context, ev busEvent) error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	if s.opts.DropWhenFull {
+		select {
+		case s.ch <- ev:
+		default:
+		}
+		return nil
+	}
+	select {
+	case s.ch <- ev:
+		return nil
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work handles the events until the subscription is closed, then the
+// ones still queued.
+func (s *Subscription) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case ev := <-s.ch:
+			s.process(ev)
+		case <-s.done:
+			for {
+				select {
+				case ev := <-s.ch:
+					s.process(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Subscription) process(ev busEvent) {
+	if err := s.handleEvent(ev); err != nil {
+		if s.opts.OnError != nil {
+			s.opts.OnError(err)
+		} else {
+			svc.Logger().Error("Event handler failed", "topic", ev.topic, "error", err)
+		}
+	}
+}
+
+func (s *Subscription) handleEvent(ev busEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in event handler: %v", r)
+		}
+	}()
+	return s.handle(ev.ctx, ev.topic, ev.payload)
+}
+
+func accepts[T any](payload any) bool {
+	_, ok := payload.(T)
+	return ok
+}
+
+// parseTopic splits the topic in its segments, allowing the wildcards in patterns.
+func parseTopic(topic string, pattern bool) ([]string, error) {
+	segments := strings.Split(topic, topicSeparator)
+	for i, seg := range segments {
+		switch {
+		case seg == "":
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTopic, topic)
+		case seg == wildcardOne || seg == wildcardRest:
+			if !pattern || (seg == wildcardRest && i != len(segments)-1) {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidTopic, topic)
+			}
+		}
+	}
+	return segments, nil
+}
+
+func matchTopic(pattern []string, topic []string) bool {
+	for i, seg := range pattern {
+		if seg == wildcardRest {
+			return len(topic) > i
+		}
+		if i >= len(topic) || (seg != wildcardOne && seg != topic[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
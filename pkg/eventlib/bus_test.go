@@ -0,0 +1,307 @@
+package eventlib
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type orderCreated struct {
+	ID string
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{"orders.created", "orders.created", true},
+		{"orders.created", "orders.deleted", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.eu.created", false},
+		{"orders.*.created", "orders.eu.created", true},
+		{"orders.>", "orders.eu.created", true},
+		{"orders.>", "orders", false},
+		{">", "orders", true},
+	}
+	for _, tt := range tests {
+		pattern, err := parseTopic(tt.pattern, true)
+		if err != nil {
+			t.Fatalf("parseTopic(%q) error = %v", tt.pattern, err)
+		}
+		topic, _ := parseTopic(tt.topic, false)
+		if got := matchTopic(pattern, topic); got != tt.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", tt.pattern, tt.topic, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"", "orders..created", "orders.>.created"} {
+		if _, err := parseTopic(invalid, true); !errors.Is(err, ErrInvalidTopic) {
+			t.Errorf("parseTopic(%q) error = %v, want ErrInvalidTopic", invalid, err)
+		}
+	}
+	if _, err := parseTopic("orders.*", false); !errors.Is(err, ErrInvalidTopic) {
+		t.Errorf("wildcards must not be allowed in published topics")
+	}
+}
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var received []string
+	record := func(ctx context.Context, topic string, payload orderCreated) error {
+		mu.Lock()
+		received = append(received, topic+":"+payload.ID)
+		mu.Unlock()
+		return nil
+	}
+
+	if _, err := Subscribe(bus, "orders.*.created", record, SubscribeOptions{BufferSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	var texts atomic.Int32
+	if _, err := Subscribe(bus, "orders.>", func(ctx context.Context, topic string, payload string) error {
+		texts.Add(1)
+		return nil
+	}, SubscribeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = Publish(ctx, bus, "orders.eu.created", orderCreated{ID: "1"})
+	_ = Publish(ctx, bus, "orders.us.created", orderCreated{ID: "2"})
+	_ = Publish(ctx, bus, "orders.eu.deleted", orderCreated{ID: "3"})
+	_ = Publish(ctx, bus, "orders.eu.created", "not an order")
+
+	if err := bus.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"orders.eu.created:1", "orders.us.created:2"}
+	if len(received) != len(want) || received[0] != want[0] || received[1] != want[1] {
+		t.Errorf("received = %v, want %v", received, want)
+	}
+	if texts.Load() != 1 {
+		t.Errorf("string events = %d, want 1", texts.Load())
+	}
+	if err := Publish(ctx, bus, "orders.eu.created", orderCreated{}); !errors.Is(err, ErrBusClosed) {
+		t.Errorf("Publish() after Close error = %v, want ErrBusClosed", err)
+	}
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	ctx := context.Background()
+
+	var count atomic.Int32
+	sub, err := Subscribe(bus, "ticks", func(ctx context.Context, topic string, payload int) error {
+		count.Add(1)
+		return nil
+	}, SubscribeOptions{BufferSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = Publish(ctx, bus, "ticks", 1)
+	sub.Unsubscribe()
+	_ = Publish(ctx, bus, "ticks", 2)
+	sub.Unsubscribe()
+
+	if err := bus.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if count.Load() != 1 {
+		t.Errorf("handled events = %d, want 1", count.Load())
+	}
+}
+
+func TestBusUnsubscribeFromHandler(t *testing.T) {
+	bus := NewBus()
+	ctx := context.Background()
+
+	handled := make(chan int, 3)
+	var sub *Subscription
+	var err error
+	sub, err = Subscribe(bus, "ticks", func(ctx context.Context, topic string, payload int) error {
+		sub.Unsubscribe()
+		handled <- payload
+		return nil
+	}, SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = Publish(ctx, bus, "ticks", 1)
+		_ = Publish(ctx, bus, "ticks", 2)
+		_ = bus.Close(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("unsubscribing from the handler deadlocked")
+	}
+	if n := len(handled); n != 1 {
+		t.Errorf("handled events = %d, want 1", n)
+	}
+}
+
+func TestBusCloseDeadline(t *testing.T) {
+	bus := NewBus()
+
+	release := make(chan struct{})
+	defer close(release)
+	_, err := Subscribe(bus, "events", func(ctx context.Context, topic string, n int) error {
+		<-release
+		return nil
+	}, SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second publish blocks, the handler being busy with the first event
+	published := make(chan error, 1)
+	go func() {
+		_ = Publish(context.Background(), bus, "events", 1)
+		published <- Publish(context.Background(), bus, "events", 2)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bus.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() = %v, want deadline exceeded", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Close() took %v after the deadline", d)
+	}
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Errorf("Publish() = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Publish() still blocked after Close()")
+	}
+}
+
+func TestBusConcurrencyAndErrors(t *testing.T) {
+	bus := NewBus()
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	var running atomic.Int32
+	var errs []error
+	var mu sync.Mutex
+	_, err := Subscribe(bus, "jobs", func(ctx context.Context, topic string, n int) error {
+		running.Add(1)
+		<-release
+		if n == 0 {
+			panic("boom")
+		}
+		return errors.New("failed")
+	}, SubscribeOptions{
+		Concurrency: 2,
+		OnError: func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = Publish(ctx, bus, "jobs", 0)
+	_ = Publish(ctx, bus, "jobs", 1)
+	deadline := time.Now().Add(time.Second)
+	for running.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if running.Load() != 2 {
+		t.Fatalf("running handlers = %d, want 2", running.Load())
+	}
+
+	closeCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(closeCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want DeadlineExceeded while handlers are running", err)
+	}
+	close(release)
+	if err := bus.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []string{errs[0].Error(), errs[1].Error()}
+	sort.Strings(msgs)
+	if msgs[0] != "failed" || msgs[1] != "panic in event handler: boom" {
+		t.Errorf("errors = %v", msgs)
+	}
+}
+
+func TestBusDropWhenFull(t *testing.T) {
+	bus := NewBus()
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	var count atomic.Int32
+	_, err := Subscribe(bus, "events", func(ctx context.Context, topic string, n int) error {
+		<-release
+		count.Add(1)
+		return nil
+	}, SubscribeOptions{BufferSize: 1, DropWhenFull: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := Publish(ctx, bus, "events", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	_ = bus.Close(ctx)
+	if n := count.Load(); n < 1 || n > 2 {
+		t.Errorf("handled events = %d, want at most 2", n)
+	}
+}
+
+func TestBusRequest(t *testing.T) {
+	bus := NewBus()
+	ctx := context.Background()
+
+	if _, err := Reply(bus, "math.double", func(ctx context.Context, topic string, n int) (int, error) {
+		return n * 2, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reply(bus, "math.*", func(ctx context.Context, topic string, n int) (int, error) {
+		return 0, errors.New("shadowed")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Request[int, int](ctx, bus, "math.double", 21)
+	if err != nil || res != 42 {
+		t.Errorf("Request() = %v, %v, want 42", res, err)
+	}
+	if _, err := Request[int, int](ctx, bus, "math.square", 2); err == nil || err.Error() != "shadowed" {
+		t.Errorf("Request() error = %v, want shadowed", err)
+	}
+	if _, err := Request[string, int](ctx, bus, "math.double", "21"); !errors.Is(err, ErrNoResponder) {
+		t.Errorf("Request() error = %v, want ErrNoResponder", err)
+	}
+	if _, err := Request[int, string](ctx, bus, "math.double", 21); !errors.Is(err, ErrReplyType) {
+		t.Errorf("Request() error = %v, want ErrReplyType", err)
+	}
+	_ = bus.Close(ctx)
+}
@@ -27,6 +27,26 @@
 //	// Clean up when done
 //	defer emitter.End()
 //
+// For modules communicating on named topics, Bus routes events of any type
+// to the subscriptions matching the topic, with "*" and ">" wildcards:
+//
+//	bus := eventlib.NewBus()
+//	sub, err := eventlib.Subscribe(bus, "orders.*.created",
+//	    func(ctx context.Context, topic string, order Order) error {
+//	        return nil
+//	    },
+//	    eventlib.SubscribeOptions{Concurrency: 4, BufferSize: 100},
+//	)
+//	err = eventlib.Publish(ctx, bus, "orders.eu.created", order)
+//	sub.Unsubscribe()
+//
+//	// Synchronous request/reply
+//	_, err = eventlib.Reply(bus, "prices.get", getPrice)
+//	price, err := eventlib.Request[string, float64](ctx, bus, "prices.get", "sku-1")
+//
+//	// Drain the queued events
+//	err = bus.Close(ctx)
+//
 // Features:
 //   - Generic type support for type-safe event handling
 //   - Buffered or unbuffered event channels
@@ -34,4 +54,5 @@
 //   - Context-based cancellation
 //   - Error handling support
 //   - Panic recovery in event handlers
+//   - Topic routing with wildcards, unsubscribe and drain on close (Bus)
 package eventlib
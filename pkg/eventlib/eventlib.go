@@ -3,8 +3,9 @@ package eventlib
 import (
 	"context"
 	"fmt"
-	"log/slog"
 	"sync"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
 )
 
 // NewEmitter creates a new event emitter with the specified context and channel buffer size.
@@ -27,7 +28,7 @@ func NewEmitter[T any](ctx context.Context, size int) *Emitter[T] {
 		mu:     &sync.RWMutex{},
 	}
 
-	go emitter.listen()
+	svc.Go("eventlib.emitter", emitter.listen)
 	return &emitter
 }
 
@@ -51,6 +52,10 @@ type Emitter[T any] struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	mu     *sync.RWMutex
+	// metricsName labels the buffer usage of the emitter, when exposed
+	metricsName string
+	// after is called once the handlers of an event returned
+	after func(T)
 }
 
 // Subscribe adds a new event handler to the emitter.
@@ -70,6 +75,14 @@ func (e *Emitter[T]) Subscribe(onEvent OnEventFn[T], onError OnErrorFn) {
 	e.mu.Unlock()
 }
 
+// AfterEvent sets the function called with each event once all the
+// handlers returned, as to end the processing of the event.
+func (e *Emitter[T]) AfterEvent(fn func(T)) {
+	e.mu.Lock()
+	e.after = fn
+	e.mu.Unlock()
+}
+
 // Emit sends a new event to all subscribed handlers.
 // If the emitter's context is cancelled or the channel is full,
 // the event will be dropped.
@@ -91,12 +104,6 @@ func (e *Emitter[T]) GetEmitter() func(T) {
 }
 
 func (e *Emitter[T]) listen() {
-	defer func() {
-		if r := recover(); r != nil {
-			slog.Default().Error("panic recovered", "panic", r)
-		}
-	}()
-
 	for {
 		select {
 		case <-e.ctx.Done():
@@ -114,15 +121,21 @@ func (e *Emitter[T]) handleEvent(data T) {
 	e.mu.RLock()
 	handlers := make([]emitterFns[T], len(e.fns))
 	copy(handlers, e.fns)
+	after := e.after
 	e.mu.RUnlock()
+	if after != nil {
+		defer after(data)
+	}
 
 	for _, v := range handlers {
 		func(handler emitterFns[T]) {
 			defer func() {
 				if r := recover(); r != nil {
-					if handler.onError != nil {
-						handler.onError(fmt.Errorf("panic in event handler: %v", r))
+					if handler.onError == nil {
+						svc.Logger().Error("panic recovered in event handler", "panic", r)
+						return
 					}
+					handler.onError(fmt.Errorf("panic in event handler: %v", r))
 				}
 			}()
 
@@ -138,8 +151,35 @@ func (e *Emitter[T]) handleEvent(data T) {
 func (e *Emitter[T]) End() {
 	e.cancel() // Cancel context first
 
+	if e.metricsName != "" {
+		emitterBufferUsage.Delete(e.metricsName)
+	}
+
 	// Clear handlers under lock to prevent new emissions
 	e.mu.Lock()
 	e.fns = nil
 	e.mu.Unlock()
 }
+
+// emitterBufferUsage holds the buffer usage of the emitters, labelled by
+// the name passed to Metrics.
+var emitterBufferUsage = svc.RegisterMetric(svc.NewGaugeSet(
+	"eventlib_emitter_buffer_usage_ratio",
+	"Ratio of the emitter buffer filled with events waiting to be handled.",
+	"emitter",
+))
+
+// Metrics exposes the buffer usage of the emitter in the
+// eventlib_emitter_buffer_usage_ratio gauge, labelled with the given
+// name, until the emitter is ended.
+// Unbuffered emitters always report zero.
+func (e *Emitter[T]) Metrics(name string) *Emitter[T] {
+	e.metricsName = name
+	emitterBufferUsage.Set(name, func() float64 {
+		if cap(e.ch) == 0 {
+			return 0
+		}
+		return float64(len(e.ch)) / float64(cap(e.ch))
+	})
+	return e
+}
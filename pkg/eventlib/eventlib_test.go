@@ -6,6 +6,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNewEmitter(t *testing.T) {
@@ -112,6 +114,31 @@ func TestEmitter_EmitWithError(t *testing.T) {
 	}
 }
 
+func TestEmitter_AfterEvent(t *testing.T) {
+	emitter := NewEmitter[string](context.Background(), 1)
+
+	var calls []string
+	done := make(chan struct{})
+	emitter.Subscribe(func(data string) error {
+		calls = append(calls, "handler "+data)
+		return errors.New("handler error")
+	}, nil)
+	emitter.AfterEvent(func(data string) {
+		calls = append(calls, "after "+data)
+		close(done)
+	})
+
+	emitter.Emit("a")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the after event function")
+	}
+	if len(calls) != 2 || calls[0] != "handler a" || calls[1] != "after a" {
+		t.Errorf("AfterEvent() calls = %v, want [handler a after a]", calls)
+	}
+}
+
 func TestEmitter_End(t *testing.T) {
 	ctx := context.Background()
 	emitter := NewEmitter[string](ctx, 1)
@@ -223,3 +250,58 @@ func TestEmitter_PanicRecovery(t *testing.T) {
 		t.Errorf("PanicRecovery: got unexpected error message: %v", receivedErr)
 	}
 }
+
+func TestEmitter_Metrics(t *testing.T) {
+	emitter := NewEmitter[string](context.Background(), 4).Metrics("test")
+
+	release := make(chan struct{})
+	emitter.Subscribe(func(data string) error {
+		<-release
+		return nil
+	}, nil)
+
+	for i := 0; i < 3; i++ {
+		emitter.Emit("event")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(emitterBufferUsage) != 0.5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected buffer usage 0.5, got %v", testutil.ToFloat64(emitterBufferUsage))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	emitter.End()
+	if n := testutil.CollectAndCount(emitterBufferUsage); n != 0 {
+		t.Errorf("End() didn't remove the metrics, got %v", n)
+	}
+}
+
+func TestEmitter_PanicRecoveryWithoutOnError(t *testing.T) {
+	emitter := NewEmitter[string](context.Background(), 1)
+	defer emitter.End()
+
+	received := make(chan string, 1)
+	emitter.Subscribe(func(data string) error {
+		panic("test panic")
+	}, nil)
+	emitter.Subscribe(func(data string) error {
+		received <- data
+		return nil
+	}, nil)
+
+	emitter.Emit("first")
+	emitter.Emit("second")
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case data := <-received:
+			if data != expected {
+				t.Errorf("PanicRecoveryWithoutOnError got %v, want %v", data, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("PanicRecoveryWithoutOnError: event %v not handled", expected)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package gcplib
+
+import (
+	"context"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sandrolain/gomsvc/pkg/inboxlib"
+)
+
+// InboxCallback wraps the Receive handler to skip the messages already
+// processed, identified by their OutboxIDAttribute, or by their Pub/Sub
+// message ID when not published by OutboxPublisher. The messages still in
+// progress elsewhere fail with inboxlib.ErrInProgress, and are redelivered.
+func InboxCallback(in *inboxlib.Inbox, callback func(context.Context, *pubsub.Message) error) func(context.Context, *pubsub.Message) error {
+	return func(ctx context.Context, msg *pubsub.Message) error {
+		id := msg.Attributes[OutboxIDAttribute]
+		if id == "" {
+			id = msg.ID
+		}
+		return in.Do(ctx, id, func(ctx context.Context) error {
+			return callback(ctx, msg)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package gcplib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sandrolain/gomsvc/pkg/outboxlib"
+)
+
+// OutboxIDAttribute is the attribute holding the ID of the outbox message,
+// identifying it in the inbox, as Pub/Sub assigns a new message ID to each
+// publication.
+const OutboxIDAttribute = "outbox-id"
+
+// OutboxPublisher returns the outbox publisher sending each message to the
+// topic named as its topic, with its key as ordering key and its headers
+// and its ID, as OutboxIDAttribute, as attributes.
+func (p *PubSub) OutboxPublisher() outboxlib.Publisher {
+	var mu sync.Mutex
+	topics := make(map[string]*pubsub.Topic)
+
+	return outboxlib.PublisherFunc(func(ctx context.Context, msg outboxlib.Message) error {
+		mu.Lock()
+		topic, ok := topics[msg.Topic]
+		if !ok {
+			t, err := p.Topic(ctx, msg.Topic)
+			if err != nil {
+				mu.Unlock()
+				return fmt.Errorf("error getting topic: %w", err)
+			}
+			t.EnableMessageOrdering = true
+			topics[msg.Topic] = t
+			topic = t
+		}
+		mu.Unlock()
+
+		attrs := make(map[string]string, len(msg.Headers)+1)
+		for k, v := range msg.Headers {
+			attrs[k] = v
+		}
+		attrs[OutboxIDAttribute] = msg.ID
+
+		result := topic.Publish(ctx, &pubsub.Message{
+			Data:        msg.Payload,
+			Attributes:  attrs,
+			OrderingKey: msg.Key,
+		})
+		if _, err := result.Get(ctx); err != nil {
+			if msg.Key != "" {
+				// The failed ordering key is paused until resumed
+				topic.ResumePublish(msg.Key)
+			}
+			return fmt.Errorf("error publishing message: %w", err)
+		}
+		return nil
+	})
+}
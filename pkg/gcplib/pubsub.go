@@ -84,6 +84,17 @@ func (p *PubSub) Publish(ctx context.Context, topicID string, data []byte) (stri
 // Pull pulls messages from a subscription until the context is cancelled
 // It returns a cancel function that can be called to stop pulling messages
 func (p *PubSub) Pull(ctx context.Context, subscriptionID string, callback func(*pubsub.Message)) (func(), error) {
+	return p.Receive(ctx, subscriptionID, func(ctx context.Context, msg *pubsub.Message) error {
+		callback(msg)
+		return nil
+	})
+}
+
+// Receive pulls messages from a subscription until the context is cancelled,
+// acknowledging the messages handled without error and negatively
+// acknowledging the others, so that they are redelivered.
+// It returns a cancel function that can be called to stop pulling messages
+func (p *PubSub) Receive(ctx context.Context, subscriptionID string, handler func(context.Context, *pubsub.Message) error) (func(), error) {
 	subscription, err := p.Subscription(ctx, "", subscriptionID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting subscription: %v", err)
@@ -91,8 +102,12 @@ func (p *PubSub) Pull(ctx context.Context, subscriptionID string, callback func(
 
 	cancelCtx, cancel := context.WithCancel(ctx)
 	go func() {
-		err = subscription.Receive(cancelCtx, func(ctx context.Context, msg *pubsub.Message) {
-			callback(msg)
+		err := subscription.Receive(cancelCtx, func(ctx context.Context, msg *pubsub.Message) {
+			if err := handler(ctx, msg); err != nil {
+				slog.Error("error handling message", "error", err, "id", msg.ID)
+				msg.Nack()
+				return
+			}
 			msg.Ack()
 		})
 		if err != nil && err != context.Canceled {
@@ -6,7 +6,9 @@ import (
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/sandrolain/gomsvc/pkg/certlib"
+	"github.com/sandrolain/gomsvc/pkg/resiliencelib"
 	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
@@ -16,6 +18,13 @@ type ClientOptions struct {
 	Logger      *slog.Logger
 	Credentials *certlib.ClientTLSConfigFiles
 	ServerName  string // Added for TLS verification
+	// HealthCheckName registers a svc health check of the remote server when set
+	HealthCheckName string
+	// Resilience is the policy applied to the unary calls
+	Resilience *resiliencelib.Policy
+	// IdempotentMethods are the full names of the unary methods, as
+	// "/package.Service/Method", retried by Resilience. By default no call is retried.
+	IdempotentMethods []string
 }
 
 func CreateClient[T any](new func(grpc.ClientConnInterface) T, opts ClientOptions) (res T, err error) {
@@ -48,11 +57,19 @@ func CreateClient[T any](new func(grpc.ClientConnInterface) T, opts ClientOption
 		logging.WithLogOnEvents(logging.StartCall, logging.FinishCall),
 	}
 
+	unaryInterceptors := []grpc.UnaryClientInterceptor{
+		logContextUnaryClientInterceptor(),
+		logging.UnaryClientInterceptor(interceptorLogger(logger), loggerOpts...),
+	}
+	if opts.Resilience != nil {
+		unaryInterceptors = append(unaryInterceptors, resilienceUnaryClientInterceptor(opts.Resilience, opts.IdempotentMethods))
+	}
+
 	dialOptions = append(dialOptions,
-		grpc.WithChainUnaryInterceptor(
-			logging.UnaryClientInterceptor(interceptorLogger(logger), loggerOpts...),
-		),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(unaryInterceptors...),
 		grpc.WithChainStreamInterceptor(
+			logContextStreamClientInterceptor(),
 			logging.StreamClientInterceptor(interceptorLogger(logger), loggerOpts...),
 		),
 	)
@@ -63,6 +80,10 @@ func CreateClient[T any](new func(grpc.ClientConnInterface) T, opts ClientOption
 		return
 	}
 
+	if opts.HealthCheckName != "" {
+		svc.AddHealthCheck(opts.HealthCheckName, HealthCheck(conn, ""))
+	}
+
 	res = new(conn)
 
 	return
@@ -35,15 +35,37 @@ func InvalidArgument(msg string, args ...interface{}) error {
 	return e
 }
 
+// InvalidArgumentContext is like InvalidArgument, logging with the attributes carried by ctx.
+func InvalidArgumentContext(ctx context.Context, msg string, args ...interface{}) error {
+	m, args, e := getArgs(codes.InvalidArgument, "Invalid Argument", msg, args)
+	log(ctx, slog.LevelWarn, m, args...)
+	return e
+}
+
 func NotFound(msg string, args ...interface{}) error {
 	m, args, e := getArgs(codes.NotFound, "Not Found", msg, args)
 	log(context.Background(), slog.LevelWarn, m, args...)
 	return e
 }
 
+// NotFoundContext is like NotFound, logging with the attributes carried by ctx.
+func NotFoundContext(ctx context.Context, msg string, args ...interface{}) error {
+	m, args, e := getArgs(codes.NotFound, "Not Found", msg, args)
+	log(ctx, slog.LevelWarn, m, args...)
+	return e
+}
+
 func InternalError(msg string, err error, args ...interface{}) error {
 	m, args, e := getArgs(codes.Internal, "Internal Error", msg, args)
 	args = append(args, "err", err)
 	log(context.Background(), slog.LevelError, m, args...)
 	return e
 }
+
+// InternalErrorContext is like InternalError, logging with the attributes carried by ctx.
+func InternalErrorContext(ctx context.Context, msg string, err error, args ...interface{}) error {
+	m, args, e := getArgs(codes.Internal, "Internal Error", msg, args)
+	args = append(args, "err", err)
+	log(ctx, slog.LevelError, m, args...)
+	return e
+}
@@ -13,8 +13,10 @@ import (
 	protovalidate_middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/protovalidate"
 	"github.com/sandrolain/gomsvc/pkg/certlib"
 	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -31,6 +33,8 @@ type ServerOptions struct {
 	Handler     interface{}       `validate:"required"`
 	Logger      *slog.Logger
 	TLSConfig   *certlib.ServerTLSConfigFiles `validate:"omitempty"`
+	// Container, when set, serves each call within one of its request scopes
+	Container *svc.Container
 }
 
 func ServerOptionsFromEnvConfig(cfg EnvServerConfig) ServerOptions {
@@ -95,19 +99,34 @@ func NewGrpcServer(opts ServerOptions) (*GrpcServer, error) {
 		logging.WithLogOnEvents(logging.StartCall, logging.FinishCall),
 	}
 
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		metricsUnaryServerInterceptor(),
+		logContextUnaryServerInterceptor(),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		metricsStreamServerInterceptor(),
+		logContextStreamServerInterceptor(),
+	}
+	if opts.Container != nil {
+		unaryInterceptors = append(unaryInterceptors, scopeUnaryServerInterceptor(opts.Container))
+		streamInterceptors = append(streamInterceptors, scopeStreamServerInterceptor(opts.Container))
+	}
+
 	serverOptions = append(serverOptions,
-		grpc.ChainUnaryInterceptor(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(append(unaryInterceptors,
 			protovalidate_middleware.UnaryServerInterceptor(protovalidator),
 			logging.UnaryServerInterceptor(interceptorLogger(logger), loggerOpts...),
-		),
-		grpc.ChainStreamInterceptor(
+		)...),
+		grpc.ChainStreamInterceptor(append(streamInterceptors,
 			protovalidate_middleware.StreamServerInterceptor(protovalidator),
 			logging.StreamServerInterceptor(interceptorLogger(logger), loggerOpts...),
-		),
+		)...),
 	)
 
 	s := grpc.NewServer(serverOptions...)
 	s.RegisterService(opts.ServiceDesc, opts.Handler)
+	healthpb.RegisterHealthServer(s, newHealthServer(opts.ServiceDesc.ServiceName))
 	reflection.Register(s)
 
 	return &GrpcServer{server: s, lis: lis, logger: logger}, nil
@@ -125,3 +144,35 @@ func (gs *GrpcServer) Stop() {
 	gs.server.GracefulStop()
 	gs.logger.Info("gRPC server stopped")
 }
+
+// Component returns the svc lifecycle component serving the gRPC server.
+// On stop the server is gracefully stopped, and forcibly stopped when the
+// stop deadline expires.
+func (gs *GrpcServer) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			go func() {
+				if err := gs.Start(); err != nil {
+					_ = svc.Error("gRPC server stopped", err)
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			done := make(chan struct{})
+			go func() {
+				gs.Stop()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				gs.server.Stop()
+				return ctx.Err()
+			}
+		},
+	}
+}
@@ -11,8 +11,12 @@ import (
 	"github.com/sandrolain/gomsvc/pkg/certlib"
 	g "github.com/sandrolain/gomsvc/pkg/grpclib/test"
 	"github.com/sandrolain/gomsvc/pkg/netlib"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 type testServer struct {
@@ -108,3 +112,132 @@ func TestGrpcServer_Start(t *testing.T) {
 
 	srv.Stop()
 }
+
+func TestGrpcServer_Health(t *testing.T) {
+	port, err := netlib.GetFreePort()
+	if err != nil {
+		t.Fatalf("GetFreePort returned error: %v", err.Error())
+	}
+
+	srv, err := NewGrpcServer(ServerOptions{
+		Port:        port,
+		ServiceDesc: &g.UnitTestService_ServiceDesc,
+		Handler:     &testServer{},
+		Logger:      slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{AddSource: true})),
+	})
+	if err != nil {
+		t.Fatalf("NewGrpcServer returned error: %v", err)
+	}
+
+	go func() {
+		_ = srv.Start()
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(fmt.Sprintf(":%v", port), grpc.WithTransportCredentials(
+		insecure.NewCredentials(),
+	))
+	if err != nil {
+		t.Fatalf("grpc.NewClient returned error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	check := HealthCheck(conn, g.UnitTestService_ServiceDesc.ServiceName)
+	if err := check(ctx); err != nil {
+		t.Fatalf("health check returned error: %v", err)
+	}
+
+	if err := HealthCheck(conn, "unknown")(ctx); err == nil {
+		t.Fatalf("health check of unknown service did not return error")
+	}
+}
+
+func TestGrpcServer_Telemetry(t *testing.T) {
+	tt := svc.InitTestTelemetry()
+	defer tt.Shutdown()
+
+	port, err := netlib.GetFreePort()
+	if err != nil {
+		t.Fatalf("GetFreePort returned error: %v", err.Error())
+	}
+
+	srv, err := NewGrpcServer(ServerOptions{
+		Port:        port,
+		ServiceDesc: &g.UnitTestService_ServiceDesc,
+		Handler:     &testServer{},
+		Logger:      slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{AddSource: true})),
+	})
+	if err != nil {
+		t.Fatalf("NewGrpcServer returned error: %v", err)
+	}
+	go func() {
+		_ = srv.Start()
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(fmt.Sprintf(":%v", port),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient returned error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if _, err := g.NewUnitTestServiceClient(conn).RunTest(context.Background(), &g.UnitTestRequest{}); err != nil {
+		t.Fatalf("RunTest returned error: %v", err)
+	}
+
+	// The server span ends after the response is sent
+	deadline := time.Now().Add(5 * time.Second)
+	for len(tt.Spans.GetSpans()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	var client, server trace.SpanContext
+	var serverParent trace.SpanContext
+	for _, span := range tt.Spans.GetSpans() {
+		switch span.SpanKind {
+		case trace.SpanKindClient:
+			client = span.SpanContext
+		case trace.SpanKindServer:
+			server, serverParent = span.SpanContext, span.Parent
+		}
+	}
+	if !client.IsValid() || !server.IsValid() {
+		t.Fatalf("expected a client and a server span, got %v", tt.Spans.GetSpans())
+	}
+	if server.TraceID() != client.TraceID() || serverParent.SpanID() != client.SpanID() {
+		t.Fatalf("expected the server span to continue the client trace")
+	}
+}
+
+func TestLogContext(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "req-1"))
+	ctx, err := logContext(ctx)
+	if err != nil {
+		t.Fatalf("logContext returned error: %v", err)
+	}
+	if id := svc.RequestID(ctx); id != "req-1" {
+		t.Fatalf("expected request ID req-1, got %q", id)
+	}
+
+	md, _ := metadata.FromOutgoingContext(outgoingLogContext(ctx))
+	if values := md.Get(RequestIDMetadataKey); len(values) != 1 || values[0] != "req-1" {
+		t.Fatalf("expected outgoing request ID req-1, got %v", values)
+	}
+
+	ctx, err = logContext(context.Background())
+	if err != nil {
+		t.Fatalf("logContext returned error: %v", err)
+	}
+	if svc.RequestID(ctx) == "" {
+		t.Fatalf("expected a generated request ID")
+	}
+}
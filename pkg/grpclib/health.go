@@ -0,0 +1,92 @@
+package grpclib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// HealthWatchInterval is the interval between the checks sent to the health Watch streams
+var HealthWatchInterval = 5 * time.Second
+
+// healthServer implements the standard grpc.health.v1 service on top of the svc health checks.
+// The empty service name and the registered service name report the readiness,
+// while the "liveness" service name reports the liveness.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	serviceName string
+}
+
+func newHealthServer(serviceName string) *healthServer {
+	return &healthServer{serviceName: serviceName}
+}
+
+func (h *healthServer) status(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	var report svc.HealthReport
+	switch service {
+	case "", h.serviceName:
+		report = svc.CheckReadiness(ctx)
+	case "liveness":
+		report = svc.CheckLiveness(ctx)
+	default:
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, status.Errorf(codes.NotFound, "unknown service %s", service)
+	}
+	if report.IsUp() {
+		return healthpb.HealthCheckResponse_SERVING, nil
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING, nil
+}
+
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, err := h.status(ctx, req.GetService())
+	if err != nil {
+		return nil, err
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	ticker := time.NewTicker(HealthWatchInterval)
+	defer ticker.Stop()
+	for {
+		st, err := h.status(ctx, req.GetService())
+		if err != nil {
+			// The watch protocol requires to report unknown services instead of failing
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
+
+// HealthCheck returns a svc health check calling the grpc.health.v1 service of a remote server
+func HealthCheck(conn grpc.ClientConnInterface, service string) svc.HealthCheckFunc {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("service status %s", res.GetStatus())
+		}
+		return nil
+	}
+}
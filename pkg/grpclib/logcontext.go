@@ -0,0 +1,95 @@
+package grpclib
+
+import (
+	"context"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.jetpack.io/typeid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const RequestIDMetadataKey = "x-request-id"
+
+// logContext seeds the call context with the request ID, taken from the
+// incoming metadata or generated, and sends it back in the response header.
+func logContext(ctx context.Context) (context.Context, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		t, err := typeid.From("req", "")
+		if err != nil {
+			return ctx, err
+		}
+		id = t.String()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+	return svc.WithRequestID(ctx, id), nil
+}
+
+func logContextUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := logContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func logContextStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := logContext(ss.Context())
+		if err != nil {
+			return err
+		}
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+// scopeUnaryServerInterceptor serves the call within a request scope of
+// the container, closing its instances when the call returns.
+func scopeUnaryServerInterceptor(container *svc.Container) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, end := container.BeginScope(ctx)
+		defer end()
+		return handler(ctx, req)
+	}
+}
+
+func scopeStreamServerInterceptor(container *svc.Container) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, end := container.BeginScope(ss.Context())
+		defer end()
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+// outgoingLogContext forwards the request ID of ctx to the called service.
+func outgoingLogContext(ctx context.Context) context.Context {
+	if id := svc.RequestID(ctx); id != "" {
+		return metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, id)
+	}
+	return ctx
+}
+
+func logContextUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingLogContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+func logContextStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingLogContext(ctx), desc, cc, method, opts...)
+	}
+}
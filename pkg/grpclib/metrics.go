@@ -0,0 +1,57 @@
+package grpclib
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcHandled = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of RPCs completed on the server by method and code.",
+	}, []string{"grpc_service", "grpc_method", "grpc_code"}))
+	grpcHandlingDuration = svc.RegisterMetric(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Duration of RPCs handled on the server by method and code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_service", "grpc_method", "grpc_code"}))
+)
+
+func observeRPC(fullMethod string, start time.Time, err error) {
+	service, method := splitFullMethod(fullMethod)
+	code := status.Code(err).String()
+	grpcHandled.WithLabelValues(service, method, code).Inc()
+	grpcHandlingDuration.WithLabelValues(service, method, code).Observe(time.Since(start).Seconds())
+}
+
+func splitFullMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
+func metricsUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		observeRPC(info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+func metricsStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeRPC(info.FullMethod, start, err)
+		return err
+	}
+}
@@ -0,0 +1,43 @@
+package grpclib
+
+import (
+	"context"
+
+	"github.com/sandrolain/gomsvc/pkg/resiliencelib"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dependencyFailure reports whether the status code of a call is a failure
+// of the called service, rather than of the request.
+func dependencyFailure(c codes.Code) bool {
+	switch c {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+// resilienceUnaryClientInterceptor runs the unary calls through the policy,
+// retrying only the idempotent methods. The streams are not covered, as
+// they cannot be retried transparently.
+func resilienceUnaryClientInterceptor(p *resiliencelib.Policy, idempotent []string) grpc.UnaryClientInterceptor {
+	retried := make(map[string]bool, len(idempotent))
+	for _, method := range idempotent {
+		retried[method] = true
+	}
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		call := func(ctx context.Context) error {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err != nil && !dependencyFailure(status.Code(err)) {
+				return resiliencelib.Permanent(err)
+			}
+			return err
+		}
+		if retried[method] {
+			return p.Do(ctx, call)
+		}
+		return p.DoOnce(ctx, call)
+	}
+}
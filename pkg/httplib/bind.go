@@ -0,0 +1,488 @@
+package httplib
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BindError reports a request value that cannot be bound to its field.
+type BindError struct {
+	Source string
+	Key    string
+	Err    error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("invalid %s %s: %v", e.Source, e.Key, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	fileHeaderType      = reflect.TypeFor[*multipart.FileHeader]()
+)
+
+// fiberTagSources maps the fiber parsers tags to the req tag sources.
+var fiberTagSources = [][2]string{{"params", "param"}, {"query", "query"}, {"reqHeader", "header"}}
+
+// bindPlans caches the binding plans by request type.
+var bindPlans sync.Map
+
+// bindPlan is the list of the bound fields of a request type.
+type bindPlan struct {
+	fields []bindField
+}
+
+// bindField binds a field from its source. The fields are bound by the
+// params, query and reqHeader tags of the fiber parsers, and by the req tag
+// of the form "source:key:options", the options being a comma separated
+// list of the encoding (csv or json) and of the layout, maxSize and types
+// settings. The default tag holds the value used when the request has none.
+// As with the fiber parsers, the untagged fields are bound from the
+// headers, the query and the params named as the field, ignoring the case.
+type bindField struct {
+	index      []int
+	source     string
+	key        string
+	fold       bool
+	def        string
+	hasDefault bool
+	maxSize    int64
+	types      []string
+	decode     decodeFunc
+}
+
+// decodeFunc sets the field v from the request values.
+type decodeFunc func(v reflect.Value, values []string) error
+
+func getBindPlan(t reflect.Type) (*bindPlan, error) {
+	if p, ok := bindPlans.Load(t); ok {
+		return p.(*bindPlan), nil
+	}
+	p := &bindPlan{}
+	if err := p.addFields(t, nil); err != nil {
+		return nil, err
+	}
+	res, _ := bindPlans.LoadOrStore(t, p)
+	return res.(*bindPlan), nil
+}
+
+// addFields adds the fields of the struct type to the plan, flattening the
+// fields of the untagged embedded structs as the fiber parsers do.
+func (p *bindPlan) addFields(t reflect.Type, parent []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(parent[:len(parent):len(parent)], i)
+		var tags []string
+		if tag := f.Tag.Get("req"); tag != "" {
+			tags = append(tags, tag)
+		}
+		untagged := len(tags) == 0
+		for _, src := range fiberTagSources {
+			key, _, _ := strings.Cut(f.Tag.Get(src[0]), ",")
+			if key != "" && key != "-" {
+				tags = append(tags, src[1]+":"+key)
+			}
+			untagged = untagged && key == ""
+		}
+		if untagged && isEmbeddedStruct(f) {
+			if err := p.addFields(indirectType(f.Type), index); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if untagged {
+			fields, err := untaggedBindFields(index, f)
+			if err != nil {
+				return fmt.Errorf("field %s of %s: %w", f.Name, t, err)
+			}
+			p.fields = append(p.fields, fields...)
+			continue
+		}
+		for _, tag := range tags {
+			bf, err := newBindField(index, f, tag)
+			if err != nil {
+				return fmt.Errorf("field %s of %s: %w", f.Name, t, err)
+			}
+			p.fields = append(p.fields, bf)
+		}
+	}
+	return nil
+}
+
+// isEmbeddedStruct reports whether the field is an embedded struct whose
+// fields are promoted, and not a value decodable from a string. The fields
+// of the unexported embedded pointers cannot be set, and are ignored.
+func isEmbeddedStruct(f reflect.StructField) bool {
+	if !f.Anonymous {
+		return false
+	}
+	t := indirectType(f.Type)
+	if t.Kind() != reflect.Struct || t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+	return f.IsExported() || f.Type.Kind() != reflect.Pointer
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+// untaggedBindFields binds the field by its name from the headers, the
+// query and the params, in the order of the fiber parsers. The fields of
+// the types not decodable from a string must be tagged, or excluded with
+// a "-" tag.
+func untaggedBindFields(index []int, f reflect.StructField) ([]bindField, error) {
+	decode, err := newDecoder(f.Type, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("untagged field: %w", err)
+	}
+	var res []bindField
+	for _, src := range []string{"header", "query", "param"} {
+		res = append(res, bindField{index: index, source: src, key: f.Name, fold: true, decode: decode})
+	}
+	return res, nil
+}
+
+func newBindField(index []int, f reflect.StructField, tag string) (bf bindField, err error) {
+	src, key, opts := getTagParts(tag)
+	bf = bindField{index: index, source: src, key: key}
+	bf.def, bf.hasDefault = f.Tag.Lookup("default")
+
+	var enc, layout string
+	for _, opt := range strings.Split(opts, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch name {
+		case "":
+		case "csv", "json":
+			enc = name
+		case "layout":
+			layout = value
+		case "maxSize":
+			if bf.maxSize, err = parseSize(value); err != nil {
+				return
+			}
+		case "types":
+			bf.types = strings.Fields(value)
+		default:
+			err = fmt.Errorf("unknown option %s", name)
+			return
+		}
+	}
+
+	switch src {
+	case "body":
+		return
+	case "file":
+		if f.Type != fileHeaderType && f.Type != reflect.SliceOf(fileHeaderType) {
+			err = fmt.Errorf("file fields must be %s or []%s", fileHeaderType, fileHeaderType)
+		}
+		return
+	case "query", "header", "cookie", "form", "param":
+	default:
+		err = fmt.Errorf("unknown source %s", src)
+		return
+	}
+	if key == "" {
+		err = fmt.Errorf("missing key of source %s", src)
+		return
+	}
+	bf.decode, err = newDecoder(f.Type, enc, layout)
+	return
+}
+
+// newDecoder returns the decoder of the type. The slices are bound from the
+// repeated values, split by comma with the csv encoding.
+func newDecoder(t reflect.Type, enc string, layout string) (decodeFunc, error) {
+	if enc == "json" {
+		return func(v reflect.Value, values []string) error {
+			return json.Unmarshal([]byte(values[0]), v.Addr().Interface())
+		}, nil
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		elem, err := newScalarDecoder(t.Elem(), layout)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value, values []string) error {
+			if enc == "csv" {
+				var parts []string
+				for _, value := range values {
+					parts = append(parts, strings.Split(value, ",")...)
+				}
+				values = parts
+			}
+			s := reflect.MakeSlice(t, len(values), len(values))
+			for i, value := range values {
+				if err := elem(s.Index(i), strings.TrimSpace(value)); err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		}, nil
+	}
+	scalar, err := newScalarDecoder(t, layout)
+	if err != nil {
+		return nil, err
+	}
+	return func(v reflect.Value, values []string) error {
+		return scalar(v, values[0])
+	}, nil
+}
+
+func newScalarDecoder(t reflect.Type, layout string) (func(v reflect.Value, s string) error, error) {
+	if t.Kind() == reflect.Pointer {
+		elem, err := newScalarDecoder(t.Elem(), layout)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value, s string) error {
+			p := reflect.New(t.Elem())
+			if err := elem(p.Elem(), s); err != nil {
+				return err
+			}
+			v.Set(p)
+			return nil
+		}, nil
+	}
+	if t == timeType {
+		return func(v reflect.Value, s string) error {
+			tm, err := parseTime(s, layout)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(tm))
+			return nil
+		}, nil
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return func(v reflect.Value, s string) error {
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		}, nil
+	}
+	if t == durationType {
+		return func(v reflect.Value, s string) error {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value, s string) error {
+			v.SetString(s)
+			return nil
+		}, nil
+	case reflect.Bool:
+		return func(v reflect.Value, s string) error {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value, s string) error {
+			n, err := strconv.ParseInt(s, 10, t.Bits())
+			if err != nil {
+				return err
+			}
+			v.SetInt(n)
+			return nil
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value, s string) error {
+			n, err := strconv.ParseUint(s, 10, t.Bits())
+			if err != nil {
+				return err
+			}
+			v.SetUint(n)
+			return nil
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value, s string) error {
+			n, err := strconv.ParseFloat(s, t.Bits())
+			if err != nil {
+				return err
+			}
+			v.SetFloat(n)
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// parseTime parses the time with the layout, defaulting to RFC 3339 and to
+// the date only layout.
+func parseTime(s string, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.Parse(layout, s)
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// parseSize parses a size in bytes, optionally with the KB, MB or GB suffix.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(s, suffix) {
+			s, mult = strings.TrimSuffix(s, suffix), m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %s: %w", s, err)
+	}
+	return n * mult, nil
+}
+
+// bind sets the fields of the plan from the request.
+func (p *bindPlan) bind(ctx *fiber.Ctx, sv reflect.Value) error {
+	for _, bf := range p.fields {
+		fv := fieldByIndex(sv, bf.index)
+		var err error
+		switch bf.source {
+		case "body":
+			err = extractBody(fv, ctx)
+		case "file":
+			err = bf.bindFiles(ctx, fv)
+		default:
+			values := requestValues(ctx, bf.source, bf.key, bf.fold)
+			if len(values) == 0 && bf.hasDefault {
+				values = []string{bf.def}
+			}
+			if len(values) > 0 {
+				if err = bf.decode(fv, values); err != nil {
+					err = BadRequestError(&BindError{Source: bf.source, Key: bf.key, Err: err})
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the nested field of the struct, allocating the nil
+// embedded pointers on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// requestValues returns the non empty values of the key in the source,
+// matching the query keys ignoring the case with fold.
+func requestValues(ctx *fiber.Ctx, source string, key string, fold bool) (res []string) {
+	var raw [][]byte
+	switch source {
+	case "query":
+		if !fold {
+			raw = ctx.Context().QueryArgs().PeekMulti(key)
+			break
+		}
+		ctx.Context().QueryArgs().VisitAll(func(k, v []byte) {
+			if strings.EqualFold(string(k), key) {
+				raw = append(raw, v)
+			}
+		})
+	case "header":
+		raw = ctx.Request().Header.PeekAll(key)
+	case "cookie":
+		raw = [][]byte{ctx.Request().Header.Cookie(key)}
+	case "param":
+		raw = [][]byte{[]byte(ctx.Params(key))}
+	case "form":
+		if form, err := ctx.MultipartForm(); err == nil {
+			return form.Value[key]
+		}
+		raw = ctx.Request().PostArgs().PeekMulti(key)
+	}
+	for _, b := range raw {
+		if len(b) > 0 {
+			res = append(res, string(b))
+		}
+	}
+	return
+}
+
+func (bf bindField) bindFiles(ctx *fiber.Ctx, fv reflect.Value) error {
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		return nil
+	}
+	files := form.File[bf.key]
+	for _, fh := range files {
+		if bf.maxSize > 0 && fh.Size > bf.maxSize {
+			return Error(fiber.StatusRequestEntityTooLarge, &BindError{Source: bf.source, Key: bf.key,
+				Err: fmt.Errorf("file %s exceeds %d bytes", fh.Filename, bf.maxSize)})
+		}
+		if len(bf.types) > 0 && !matchMediaType(fh.Header.Get(fiber.HeaderContentType), bf.types) {
+			return Error(fiber.StatusUnsupportedMediaType, &BindError{Source: bf.source, Key: bf.key,
+				Err: fmt.Errorf("file %s type is not one of %s", fh.Filename, strings.Join(bf.types, " "))})
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	if fv.Type() == fileHeaderType {
+		fv.Set(reflect.ValueOf(files[0]))
+	} else {
+		fv.Set(reflect.ValueOf(files))
+	}
+	return nil
+}
+
+// matchMediaType reports whether the content type matches one of the
+// patterns, as image/png or image/*.
+func matchMediaType(contentType string, patterns []string) bool {
+	typ, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, typ); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package httplib
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type bindLevel int
+
+type bindSearch struct {
+	ID      uuid.UUID      `req:"param:id"`
+	Tags    []string       `req:"query:tag"`
+	Sizes   []int          `req:"query:sizes:csv"`
+	Level   bindLevel      `req:"query:level"`
+	Page    int            `req:"query:page" default:"1"`
+	Since   time.Time      `req:"query:since"`
+	Day     *time.Time     `req:"query:day:layout=02/01/2006"`
+	Timeout time.Duration  `req:"query:timeout" default:"5s"`
+	Exact   *bool          `req:"query:exact"`
+	Filter  map[string]int `req:"query:filter:json"`
+	Tenant  string         `req:"header:X-Tenant"`
+	Session string         `req:"cookie:session"`
+}
+
+type bindPaging struct {
+	Page int `query:"page"`
+	Size int
+}
+
+type bindUntagged struct {
+	bindPaging
+	Name  string
+	Limit int
+	Token string          `query:"-"`
+	Extra struct{ A int } `query:"-"`
+}
+
+type bindUpload struct {
+	Title       string                  `req:"form:title" validate:"required"`
+	Public      bool                    `req:"form:public"`
+	Avatar      *multipart.FileHeader   `req:"file:avatar:maxSize=1KB,types=image/*"`
+	Attachments []*multipart.FileHeader `req:"file:attachments"`
+}
+
+func TestBind(t *testing.T) {
+	server, err := NewServer(ServerOptions{
+		DisableHealthRoutes: true,
+	})
+	require.NoError(t, err)
+
+	var search bindSearch
+	Get[bindSearch](server, "/search/:id", func(req DataRequest[bindSearch]) error {
+		search = *req.Data
+		return nil
+	})
+	var upload bindUpload
+	Post[bindUpload](server, "/upload", func(req DataRequest[bindUpload]) error {
+		upload = *req.Data
+		return nil
+	})
+	var untagged bindUntagged
+	Get[bindUntagged](server, "/untagged/:limit", func(req DataRequest[bindUntagged]) error {
+		untagged = *req.Data
+		return nil
+	})
+
+	t.Run("values", func(t *testing.T) {
+		id := uuid.New()
+		req := httptest.NewRequest(http.MethodGet, "/search/"+id.String()+
+			"?tag=a&tag=b&sizes=1,2,3&level=4&since=2024-05-01T10:00:00Z&day=02/05/2024&exact=true&filter=%7B%22x%22%3A1%7D", nil)
+		req.Header.Set("X-Tenant", "acme")
+		req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+		res, err := server.app.Test(req)
+		require.NoError(t, err)
+		b, _ := io.ReadAll(res.Body)
+		require.Equal(t, http.StatusOK, res.StatusCode, string(b))
+
+		require.Equal(t, id, search.ID)
+		require.Equal(t, []string{"a", "b"}, search.Tags)
+		require.Equal(t, []int{1, 2, 3}, search.Sizes)
+		require.Equal(t, bindLevel(4), search.Level)
+		require.Equal(t, 1, search.Page)
+		require.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), search.Since)
+		require.Equal(t, time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), *search.Day)
+		require.Equal(t, 5*time.Second, search.Timeout)
+		require.True(t, *search.Exact)
+		require.Equal(t, map[string]int{"x": 1}, search.Filter)
+		require.Equal(t, "acme", search.Tenant)
+		require.Equal(t, "s1", search.Session)
+	})
+
+	t.Run("untagged", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/untagged/10?NAME=bob&token=secret&page=3&size=20", nil)
+		res, err := server.app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, bindUntagged{bindPaging: bindPaging{Page: 3, Size: 20}, Name: "bob", Limit: 10}, untagged)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		res, err := server.app.Test(httptest.NewRequest(http.MethodGet, "/search/"+uuid.NewString()+"?sizes=1,x", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	multipartRequest := func(t *testing.T, avatarType string, avatar []byte) *http.Request {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		require.NoError(t, w.WriteField("title", "holidays"))
+		require.NoError(t, w.WriteField("public", "true"))
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="avatar"; filename="avatar"`)
+		h.Set("Content-Type", avatarType)
+		part, err := w.CreatePart(h)
+		require.NoError(t, err)
+		_, _ = part.Write(avatar)
+		for _, name := range []string{"a.txt", "b.txt"} {
+			part, err := w.CreateFormFile("attachments", name)
+			require.NoError(t, err)
+			_, _ = part.Write([]byte(name))
+		}
+		require.NoError(t, w.Close())
+		req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+		req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+		return req
+	}
+
+	t.Run("multipart", func(t *testing.T) {
+		res, err := server.app.Test(multipartRequest(t, "image/png", []byte("png")))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "holidays", upload.Title)
+		require.True(t, upload.Public)
+		require.Equal(t, int64(3), upload.Avatar.Size)
+		require.Len(t, upload.Attachments, 2)
+		require.Equal(t, "b.txt", upload.Attachments[1].Filename)
+	})
+
+	t.Run("file limits", func(t *testing.T) {
+		res, err := server.app.Test(multipartRequest(t, "image/png", make([]byte, 2048)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+
+		res, err = server.app.Test(multipartRequest(t, "text/plain", []byte("txt")))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+	})
+
+	t.Run("openapi", func(t *testing.T) {
+		doc := server.OpenAPI()
+		form := doc.Paths["/upload"]["post"].RequestBody.Content["multipart/form-data"].Schema
+		require.Equal(t, []string{"title"}, form.Required)
+		require.Equal(t, "image/*", form.Properties["avatar"].ContentMediaType)
+		require.Equal(t, "array", form.Properties["attachments"].Type)
+
+		var params []string
+		for _, p := range doc.Paths["/search/{id}"]["get"].Parameters {
+			params = append(params, p.In+":"+p.Name)
+		}
+		require.Contains(t, params, "cookie:session")
+		require.Contains(t, params, "header:X-Tenant")
+		require.Contains(t, params, "path:id")
+
+		params = nil
+		for _, p := range doc.Paths["/untagged/{limit}"]["get"].Parameters {
+			params = append(params, p.In+":"+p.Name)
+		}
+		require.Contains(t, params, "query:page", "the embedded fields are promoted")
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		p1, err := getBindPlan(reflect.TypeFor[bindSearch]())
+		require.NoError(t, err)
+		p2, err := getBindPlan(reflect.TypeFor[bindSearch]())
+		require.NoError(t, err)
+		require.Same(t, p1, p2)
+
+		_, err = getBindPlan(reflect.TypeFor[struct {
+			File string `req:"file:f"`
+		}]())
+		require.Error(t, err)
+		_, err = getBindPlan(reflect.TypeFor[struct {
+			C chan int `req:"query:c"`
+		}]())
+		require.Error(t, err)
+		_, err = getBindPlan(reflect.TypeFor[struct {
+			Extra struct{ A int }
+		}]())
+		require.Error(t, err, "the untagged fields must be decodable")
+
+		require.Panics(t, func() {
+			Get[struct {
+				M map[string]int `req:"query:m"`
+			}](server, "/invalid", func(req DataRequest[struct {
+				M map[string]int `req:"query:m"`
+			}]) error {
+				return nil
+			})
+		}, "the request type is checked at registration")
+	})
+}
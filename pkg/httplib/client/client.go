@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/sandrolain/gomsvc/pkg/resiliencelib"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // Common errors
@@ -22,6 +25,9 @@ var (
 	ErrInvalidRetryWait  = errors.New("retry wait cannot be negative")
 )
 
+// requestIDHeader forwards the request ID of the context to the called service
+const requestIDHeader = "X-Request-ID"
+
 type Init struct {
 	Params     map[string]string
 	Query      map[string]string
@@ -33,6 +39,11 @@ type Init struct {
 	RetryCount int
 	RetryWait  time.Duration
 	BaseURL    string
+	// Resilience is the policy of the called service. The connection errors
+	// and the 5xx responses are its failures. Only the requests with an
+	// idempotent method are retried, and the GET requests are hedged.
+	// RetryCount is ignored with a policy.
+	Resilience *resiliencelib.Policy
 }
 
 type Response[T any] struct {
@@ -68,20 +79,25 @@ func validateRequest(ctx context.Context, url string) error {
 	return nil
 }
 
-func applyInit(ctx context.Context, init *Init) (*resty.Request, error) {
+func validate(ctx context.Context, init *Init) error {
 	if err := validateRequest(ctx, init.BaseURL); err != nil {
-		return nil, err
+		return err
 	}
-	if err := validateInit(init); err != nil {
+	return validateInit(init)
+}
+
+func applyInit(ctx context.Context, init *Init) (*resty.Request, error) {
+	if err := validate(ctx, init); err != nil {
 		return nil, err
 	}
 
 	client := resty.New()
+	client.SetTransport(otelhttp.NewTransport(http.DefaultTransport))
 	if init != nil {
 		if init.Timeout > 0 {
 			client.SetTimeout(init.Timeout)
 		}
-		if init.RetryCount > 0 {
+		if init.RetryCount > 0 && init.Resilience == nil {
 			client.SetRetryCount(init.RetryCount)
 			if init.RetryWait > 0 {
 				client.SetRetryWaitTime(init.RetryWait)
@@ -93,6 +109,9 @@ func applyInit(ctx context.Context, init *Init) (*resty.Request, error) {
 	}
 
 	r := client.R().SetContext(ctx)
+	if id := svc.RequestID(ctx); id != "" {
+		r.SetHeader(requestIDHeader, id)
+	}
 
 	if init == nil {
 		return r, nil
@@ -120,6 +139,40 @@ func applyInit(ctx context.Context, init *Init) (*resty.Request, error) {
 	return r, nil
 }
 
+// errServerStatus marks the 5xx responses as failures for the resilience policy
+var errServerStatus = errors.New("server error status")
+
+// send executes the request through the resilience policy of init.
+func send(ctx context.Context, init *Init, method string, url string) (*resty.Response, error) {
+	call := func(ctx context.Context) (*resty.Response, error) {
+		req, err := applyInit(ctx, init)
+		if err != nil {
+			return nil, resiliencelib.Permanent(err)
+		}
+		resp, err := req.Execute(method, init.BaseURL+url)
+		if err == nil && resp.StatusCode() >= 500 {
+			err = errServerStatus
+		}
+		return resp, err
+	}
+
+	var resp *resty.Response
+	var err error
+	switch method {
+	case resty.MethodGet:
+		resp, err = resiliencelib.Hedged(ctx, init.Resilience, call)
+	case resty.MethodHead, resty.MethodPut, resty.MethodDelete, resty.MethodOptions:
+		resp, err = resiliencelib.Execute(ctx, init.Resilience, call)
+	default:
+		resp, err = resiliencelib.ExecuteOnce(ctx, init.Resilience, call)
+	}
+	if errors.Is(err, errServerStatus) {
+		// Reported by processResponse with the response body
+		err = nil
+	}
+	return resp, err
+}
+
 func processResponse[T any](resp *resty.Response, err error) (Response[T], error) {
 	var result Response[T]
 	if err != nil {
@@ -133,6 +186,12 @@ func processResponse[T any](resp *resty.Response, err error) (Response[T], error
 	result.Headers = resp.Header()
 	result.Resty = resp
 
+	if resp.StatusCode() >= 400 {
+		if p := decodeProblem(resp); p != nil {
+			return result, p
+		}
+	}
+
 	// Check for server errors (5xx)
 	if resp.StatusCode() >= 500 {
 		return result, fmt.Errorf("%w: server error %d: %s", ErrRequestFailed, resp.StatusCode(), resp.String())
@@ -147,12 +206,11 @@ func processResponse[T any](resp *resty.Response, err error) (Response[T], error
 }
 
 func GetJSON[R any](ctx context.Context, url string, init Init) (Response[*R], error) {
-	req, err := applyInit(ctx, &init)
-	if err != nil {
+	if err := validate(ctx, &init); err != nil {
 		return Response[*R]{}, err
 	}
 
-	resp, err := req.Get(init.BaseURL + url)
+	resp, err := send(ctx, &init, resty.MethodGet, url)
 	result, err := processResponse[*R](resp, err)
 	if err != nil {
 		return result, err
@@ -171,12 +229,11 @@ func GetJSON[R any](ctx context.Context, url string, init Init) (Response[*R], e
 }
 
 func GetBytes(ctx context.Context, url string, init Init) (Response[[]byte], error) {
-	req, err := applyInit(ctx, &init)
-	if err != nil {
+	if err := validate(ctx, &init); err != nil {
 		return Response[[]byte]{}, err
 	}
 
-	resp, err := req.Get(init.BaseURL + url)
+	resp, err := send(ctx, &init, resty.MethodGet, url)
 	result, err := processResponse[[]byte](resp, err)
 	if err != nil {
 		return result, err
@@ -191,12 +248,11 @@ func GetBytes(ctx context.Context, url string, init Init) (Response[[]byte], err
 }
 
 func PostJSON[R any](ctx context.Context, url string, init Init) (Response[*R], error) {
-	req, err := applyInit(ctx, &init)
-	if err != nil {
+	if err := validate(ctx, &init); err != nil {
 		return Response[*R]{}, err
 	}
 
-	resp, err := req.Post(init.BaseURL + url)
+	resp, err := send(ctx, &init, resty.MethodPost, url)
 	result, err := processResponse[*R](resp, err)
 	if err != nil {
 		return result, err
@@ -215,12 +271,11 @@ func PostJSON[R any](ctx context.Context, url string, init Init) (Response[*R],
 }
 
 func PostBytes(ctx context.Context, url string, init Init) (Response[[]byte], error) {
-	req, err := applyInit(ctx, &init)
-	if err != nil {
+	if err := validate(ctx, &init); err != nil {
 		return Response[[]byte]{}, err
 	}
 
-	resp, err := req.Post(init.BaseURL + url)
+	resp, err := send(ctx, &init, resty.MethodPost, url)
 	result, err := processResponse[[]byte](resp, err)
 	if err != nil {
 		return result, err
@@ -235,12 +290,11 @@ func PostBytes(ctx context.Context, url string, init Init) (Response[[]byte], er
 }
 
 func PutJSON[R any](ctx context.Context, url string, init Init) (Response[*R], error) {
-	req, err := applyInit(ctx, &init)
-	if err != nil {
+	if err := validate(ctx, &init); err != nil {
 		return Response[*R]{}, err
 	}
 
-	resp, err := req.Put(init.BaseURL + url)
+	resp, err := send(ctx, &init, resty.MethodPut, url)
 	result, err := processResponse[*R](resp, err)
 	if err != nil {
 		return result, err
@@ -259,12 +313,11 @@ func PutJSON[R any](ctx context.Context, url string, init Init) (Response[*R], e
 }
 
 func DeleteJSON[R any](ctx context.Context, url string, init Init) (Response[*R], error) {
-	req, err := applyInit(ctx, &init)
-	if err != nil {
+	if err := validate(ctx, &init); err != nil {
 		return Response[*R]{}, err
 	}
 
-	resp, err := req.Delete(init.BaseURL + url)
+	resp, err := send(ctx, &init, resty.MethodDelete, url)
 	result, err := processResponse[*R](resp, err)
 	if err != nil {
 		return result, err
@@ -283,12 +336,11 @@ func DeleteJSON[R any](ctx context.Context, url string, init Init) (Response[*R]
 }
 
 func PatchJSON[R any](ctx context.Context, url string, init Init) (Response[*R], error) {
-	req, err := applyInit(ctx, &init)
-	if err != nil {
+	if err := validate(ctx, &init); err != nil {
 		return Response[*R]{}, err
 	}
 
-	resp, err := req.Patch(init.BaseURL + url)
+	resp, err := send(ctx, &init, resty.MethodPatch, url)
 	result, err := processResponse[*R](resp, err)
 	if err != nil {
 		return result, err
@@ -7,8 +7,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/resiliencelib"
 )
 
 type TestResponse struct {
@@ -329,3 +332,77 @@ func TestValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestResilience(t *testing.T) {
+	var calls atomic.Int32
+	_, init := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/missing":
+			calls.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		case calls.Add(1) < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	})
+	init.Resilience = resiliencelib.New("test", resiliencelib.Config{Retries: 3, RetryBackoff: time.Millisecond})
+
+	init.RetryCount = 3
+	resp, err := GetBytes(context.Background(), "/test", init)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Body) != "ok" || calls.Load() != 3 {
+		t.Errorf("expected ok after 3 calls, got %q after %d", resp.Body, calls.Load())
+	}
+
+	calls.Store(0)
+	_, err = PostBytes(context.Background(), "/test", init)
+	if !errors.Is(err, ErrRequestFailed) || calls.Load() != 1 {
+		t.Errorf("POST requests must not be retried, got %v after %d calls", err, calls.Load())
+	}
+
+	calls.Store(0)
+	resp, err = GetBytes(context.Background(), "/missing", init)
+	if !errors.Is(err, ErrRequestFailed) || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected client error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("client errors must not be retried, got %d calls", calls.Load())
+	}
+}
+
+func TestProblemError(t *testing.T) {
+	_, init := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plain" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("bad request"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"The request contains invalid fields",` +
+			`"errors":[{"field":"body.name","rule":"required","message":"body.name is required"}]}`))
+	})
+
+	_, err := PostJSON[TestResponse](context.Background(), "/accounts", init)
+	var problem *ProblemError
+	if !errors.As(err, &problem) {
+		t.Fatalf("expected problem error, got %v", err)
+	}
+	if !errors.Is(err, ErrRequestFailed) {
+		t.Errorf("expected the problem error to wrap ErrRequestFailed")
+	}
+	if problem.Status != http.StatusBadRequest || problem.Title != "Bad Request" {
+		t.Errorf("unexpected problem %+v", problem)
+	}
+	if fields := problem.Field("body.name"); len(fields) != 1 || fields[0].Rule != "required" {
+		t.Errorf("unexpected field errors %+v", problem.Errors)
+	}
+
+	_, err = GetBytes(context.Background(), "/plain", init)
+	if errors.As(err, &problem) || !errors.Is(err, ErrRequestFailed) {
+		t.Errorf("expected plain client error, got %v", err)
+	}
+}
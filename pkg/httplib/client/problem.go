@@ -0,0 +1,73 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// problemContentType is the content type of the RFC 9457 problem details
+const problemContentType = "application/problem+json"
+
+// ProblemFieldError describes a field failing the validation of the called service.
+type ProblemFieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ProblemError is the error of the responses with RFC 9457 problem details.
+// It wraps ErrRequestFailed.
+type ProblemError struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Code     string              `json:"code,omitempty"`
+	Errors   []ProblemFieldError `json:"errors,omitempty"`
+}
+
+func (e *ProblemError) Error() string {
+	msg := fmt.Sprintf("%v: %d %s", ErrRequestFailed, e.Status, e.Title)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	for _, fe := range e.Errors {
+		msg += "; " + fe.Message
+	}
+	return msg
+}
+
+func (e *ProblemError) Unwrap() error {
+	return ErrRequestFailed
+}
+
+// Field returns the validation errors of the field.
+func (e *ProblemError) Field(field string) (res []ProblemFieldError) {
+	for _, fe := range e.Errors {
+		if fe.Field == field {
+			res = append(res, fe)
+		}
+	}
+	return
+}
+
+// decodeProblem returns the problem details of the response, or nil if it has none.
+func decodeProblem(resp *resty.Response) *ProblemError {
+	typ, _, err := mime.ParseMediaType(resp.Header().Get("Content-Type"))
+	if err != nil || typ != problemContentType {
+		return nil
+	}
+	var p ProblemError
+	if err := json.Unmarshal(resp.Body(), &p); err != nil {
+		return nil
+	}
+	if p.Status == 0 {
+		p.Status = resp.StatusCode()
+	}
+	return &p
+}
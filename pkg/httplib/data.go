@@ -1,60 +1,39 @@
 package httplib
 
 import (
-	"encoding/json"
 	"fmt"
 	"reflect"
-	"strconv"
+	"slices"
 	"strings"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/sandrolain/gomsvc/pkg/datalib"
 	"github.com/vmihailenco/msgpack/v5"
 	"google.golang.org/protobuf/proto"
 )
 
-func loadData[T any](ctx *fiber.Ctx, dest *T) error {
-	if err := ctx.ReqHeaderParser(dest); err != nil {
-		return err
-	}
-	if err := ctx.QueryParser(dest); err != nil {
-		return err
-	}
-	if err := ctx.ParamsParser(dest); err != nil {
-		return err
+// dataLoader loads the request data into dest.
+type dataLoader[T any] func(ctx *fiber.Ctx, dest *T) error
+
+// newDataLoader returns the loader of the request data of type T, built
+// when its route is registered. It panics if T cannot be bound.
+func newDataLoader[T any]() dataLoader[T] {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return func(ctx *fiber.Ctx, dest *T) error { return nil }
 	}
-	tagName := "req"
-	sv := reflect.ValueOf(dest)
-	if sv.Kind() == reflect.Ptr {
-		sv = sv.Elem()
+	plan, err := getBindPlan(t)
+	if err != nil {
+		panic(fmt.Sprintf("httplib: cannot bind the request data: %v", err))
 	}
-	st := sv.Type()
-	numFields := st.NumField()
-	for i := 0; i < numFields; i++ {
-		fieldType := st.Field(i)
-		fieldValue := sv.Field(i)
-		tag := fieldType.Tag.Get(tagName)
-		if tag != "" {
-			src, key, enc := getTagParts(tag)
-			var err error
-			switch src {
-			case "body":
-				err = extractBody(&fieldType, &fieldValue, ctx)
-			case "query":
-				if str := ctx.Query(key); len(str) > 0 {
-					err = convertValue(&fieldType, &fieldValue, str, enc)
-				}
-			}
-			if err != nil {
-				return err
-			}
-		}
+	return func(ctx *fiber.Ctx, dest *T) error {
+		return plan.bind(ctx, reflect.ValueOf(dest).Elem())
 	}
-	return nil
 }
 
 func getTagParts(tag string) (source string, key string, enc string) {
-	tagParts := strings.Split(tag, ":")
+	tagParts := strings.SplitN(tag, ":", 3)
 	source = tagParts[0]
 	if len(tagParts) > 1 {
 		key = tagParts[1]
@@ -65,10 +44,29 @@ func getTagParts(tag string) (source string, key string, enc string) {
 	return
 }
 
-func extractBody(ft *reflect.StructField, fv *reflect.Value, ctx *fiber.Ctx) (err error) {
-	field := *ft
-	fieldValue := *fv
-	ptr := reflect.New(field.Type).Interface()
+// bodyTypes are the content types of the bodies decoded by extractBody,
+// documented in the OpenAPI document. Protobuf is documented only when the
+// body is a proto.Message.
+var bodyTypes = []string{
+	datalib.TypeJson,
+	datalib.TypeMsgpack,
+	datalib.TypeXMsgpack,
+	datalib.TypeProtobuf,
+	datalib.TypeCBOR,
+}
+
+// bodyContentTypes returns the content types decoded into the body type.
+func bodyContentTypes(t reflect.Type) []string {
+	if isProtoMessage(t) {
+		return bodyTypes
+	}
+	return slices.DeleteFunc(slices.Clone(bodyTypes), func(typ string) bool {
+		return typ == datalib.TypeProtobuf
+	})
+}
+
+func extractBody(fieldValue reflect.Value, ctx *fiber.Ctx) (err error) {
+	ptr := reflect.New(fieldValue.Type()).Interface()
 	if err = ctx.BodyParser(ptr); err != nil {
 		if err != fiber.ErrUnprocessableEntity {
 			return
@@ -78,8 +76,16 @@ func extractBody(ft *reflect.StructField, fv *reflect.Value, ctx *fiber.Ctx) (er
 		switch resType[0] {
 		case datalib.TypeMsgpack, datalib.TypeXMsgpack:
 			err = msgpack.Unmarshal(ctx.Body(), ptr)
+		case datalib.TypeCBOR:
+			err = cbor.Unmarshal(ctx.Body(), ptr)
 		case datalib.TypeProtobuf:
 			d, ok := ptr.(proto.Message)
+			if !ok && fieldValue.Kind() == reflect.Pointer {
+				// The field is the message pointer: allocate the message to fill
+				msg := reflect.New(fieldValue.Type().Elem())
+				reflect.ValueOf(ptr).Elem().Set(msg)
+				d, ok = msg.Interface().(proto.Message)
+			}
 			if ok {
 				err = proto.Unmarshal(ctx.Body(), d)
 			} else {
@@ -97,61 +103,4 @@ func extractBody(ft *reflect.StructField, fv *reflect.Value, ctx *fiber.Ctx) (er
 	return nil
 }
 
-func convertValue(ft *reflect.StructField, fv *reflect.Value, str string, enc string) error {
-	fieldType := *ft
-	fieldValue := *fv
-	var refVal reflect.Value
-	fieldValueType := fieldValue.Type()
-	fieldValueTypeName := fieldValueType.Name()
-	switch fieldValueTypeName {
-	case "int":
-		v, err := strconv.Atoi(str)
-		if err != nil {
-			return err
-		}
-		refVal = reflect.ValueOf(v)
-	case "int32":
-		v, err := strconv.ParseInt(str, 10, 32)
-		if err != nil {
-			return err
-		}
-		refVal = reflect.ValueOf(v)
-	case "int64":
-		v, err := strconv.ParseInt(str, 10, 64)
-		if err != nil {
-			return err
-		}
-		refVal = reflect.ValueOf(v)
-	case "float32":
-		v, err := strconv.ParseFloat(str, 32)
-		if err != nil {
-			return err
-		}
-		refVal = reflect.ValueOf(v)
-	case "float64":
-		v, err := strconv.ParseFloat(str, 64)
-		if err != nil {
-			return err
-		}
-		refVal = reflect.ValueOf(v)
-	case "string":
-		refVal = reflect.ValueOf(str)
-	default:
-		switch enc {
-		case "json":
-			ptr := reflect.New(fieldType.Type).Interface()
-			err := json.Unmarshal([]byte(str), ptr)
-			if err != nil {
-				return err
-			}
-			refVal = reflect.ValueOf(ptr).Elem()
-		case "csv":
-			parts := strings.Split(str, ",")
-			refVal = reflect.ValueOf(parts).Elem()
-		}
-	}
-	fieldValue.Set(refVal)
-	return nil
-}
-
 type EmptyData struct{}
@@ -64,6 +64,13 @@ func UnprocessableEntityError(err error) RouteError {
 	}
 }
 
+func NotAcceptableError(err error) RouteError {
+	return RouteError{
+		Status: fiber.StatusNotAcceptable,
+		Err:    err,
+	}
+}
+
 type ResponseError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
@@ -1,7 +1,6 @@
 package httplib
 
 import (
-	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
 )
@@ -27,51 +26,70 @@ func SessionValue[R any, T any](r DataRequest[T], key string) (res R, ok bool) {
 
 type DataReceiver[T any] func(req DataRequest[T]) error
 
+// DataHandler handles the requests with the data of type T. It panics if T
+// cannot be bound from the requests.
 func DataHandler[T any](handler DataReceiver[T]) Handler {
+	load := newDataLoader[T]()
 	return func(r *Route, c *fiber.Ctx) error {
-		var obj T
-		var sess *session.Session
-		var err error
-
-		// Request authorization
-		if err = authorization(r, c); err != nil {
+		req, err := dataRequest(r, c, load)
+		if err != nil {
 			return err
 		}
-
-		// Request validation
-		if err = validation(r, c); err != nil {
-			return err
+		err = runDataMiddlewares(r, req, func() error {
+			return handler(req)
+		})
+		if err != nil {
+			return routeError(err)
 		}
 
-		// Data load
-		if err = loadData[T](c, &obj); err != nil {
-			return InternalServerError(err)
-		}
+		return nil
+	}
+}
 
-		// Data validation
-		if err = dataValidation(r, c, &obj); err != nil {
-			return err
-		}
+// dataRequest authorizes and validates the request, loading its data.
+func dataRequest[T any](r *Route, c *fiber.Ctx, load dataLoader[T]) (req DataRequest[T], err error) {
+	var obj T
+	var sess *session.Session
 
-		// Handle request
-		if sess, err = loadSession(r, c); err != nil {
-			return err
-		}
+	// Request authorization
+	if err = authorization(r, c); err != nil {
+		return
+	}
 
-		req := DataRequest[T]{
-			Ctx:     c,
-			Data:    &obj,
-			Session: sess,
-		}
-		if err = handler(req); err != nil {
-			if rerr, ok := err.(RouteError); ok {
-				return rerr
-			}
-			return InternalServerError(err)
-		}
+	// Request validation
+	if err = validation(r, c); err != nil {
+		return
+	}
 
-		return nil
+	// Data load
+	if err = load(c, &obj); err != nil {
+		err = routeError(err)
+		return
+	}
+
+	// Data validation
+	if err = dataValidation(r, c, &obj); err != nil {
+		return
+	}
+
+	// Handle request
+	if sess, err = loadSession(r, c); err != nil {
+		return
+	}
+
+	req = DataRequest[T]{
+		Ctx:     c,
+		Data:    &obj,
+		Session: sess,
+	}
+	return
+}
+
+func routeError(err error) error {
+	if rerr, ok := err.(RouteError); ok {
+		return rerr
 	}
+	return InternalServerError(err)
 }
 
 func loadSession(r *Route, c *fiber.Ctx) (sess *session.Session, err error) {
@@ -110,7 +128,11 @@ func dataValidation[T any](r *Route, c *fiber.Ctx, obj *T) (err error) {
 	if r.noValidateData {
 		return
 	}
-	if err = validator.New().Struct(*obj); err != nil {
+	v := defaultValidator
+	if r.server.problems != nil {
+		v = dataValidator
+	}
+	if err = v.Struct(*obj); err != nil {
 		err = BadRequestError(err)
 	}
 	return
@@ -0,0 +1,31 @@
+package httplib
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+const (
+	HealthPath    = "/healthz"
+	LivenessPath  = "/livez"
+	ReadinessPath = "/readyz"
+)
+
+func (s *Server) registerHealthRoutes() {
+	s.app.Get(HealthPath, healthHandler(svc.CheckHealth))
+	s.app.Get(LivenessPath, healthHandler(svc.CheckLiveness))
+	s.app.Get(ReadinessPath, healthHandler(svc.CheckReadiness))
+}
+
+func healthHandler(check func(ctx context.Context) svc.HealthReport) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := check(c.UserContext())
+		status := fiber.StatusOK
+		if !report.IsUp() {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(report)
+	}
+}
@@ -0,0 +1,39 @@
+package httplib
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.jetpack.io/typeid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// logContextMiddleware seeds the request context with the request ID,
+// taken from the request header or generated, so that the records logged
+// with the context while serving the request correlate to it.
+func logContextMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(RequestIDHeader)
+		if id == "" {
+			t, err := typeid.From("req", "")
+			if err != nil {
+				return err
+			}
+			id = t.String()
+		}
+		c.Set(RequestIDHeader, id)
+		c.SetUserContext(svc.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+// scopeMiddleware serves the request within a request scope of the
+// container, closing its instances when the request is served.
+func scopeMiddleware(container *svc.Container) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, end := container.BeginScope(c.UserContext())
+		defer end()
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
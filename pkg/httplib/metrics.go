@@ -0,0 +1,49 @@
+package httplib
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+var (
+	httpRequests = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_requests_total",
+		Help: "Total number of HTTP requests by route and status.",
+	}, []string{"method", "route", "status"}))
+	httpRequestDuration = svc.RegisterMetric(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Duration of HTTP requests by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"}))
+)
+
+// metricsMiddleware records the rate, errors and duration of the requests
+// by route template and status.
+func metricsMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		labels := prometheus.Labels{
+			"method": c.Method(),
+			"route":  c.Route().Path,
+			"status": strconv.Itoa(responseStatus(c, err)),
+		}
+		httpRequests.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+func (s *Server) registerMetricsRoute(path string) {
+	if path == "" {
+		path = svc.DefaultMetricsPath
+	}
+	s.app.Get(path, adaptor.HTTPHandler(svc.MetricsHandler()))
+}
@@ -0,0 +1,150 @@
+package httplib
+
+import (
+	"slices"
+)
+
+// Middleware wraps the handler of the routes. It can act before and after
+// calling next, short-circuit returning a RouteError, and modify the
+// response through the fiber context.
+type Middleware func(next Handler) Handler
+
+// DataMiddleware wraps the handler of the typed routes with request type T,
+// after the request data is bound and validated.
+type DataMiddleware[T any] func(req DataRequest[T], next func() error) error
+
+// MiddlewareTarget is a Server or a Route the data middlewares are attached to.
+type MiddlewareTarget interface {
+	useData(mw any)
+}
+
+// Use attaches the middlewares to all the routes of the server. The server
+// middlewares run before the ones of the groups and of the routes.
+func (s *Server) Use(mw ...Middleware) *Server {
+	s.middlewaresMu.Lock()
+	defer s.middlewaresMu.Unlock()
+	s.middlewares = append(s.middlewares, mw...)
+	s.middlewaresGen.Add(1)
+	return s
+}
+
+func (s *Server) useData(mw any) {
+	s.middlewaresMu.Lock()
+	defer s.middlewaresMu.Unlock()
+	s.dataMiddlewares = append(s.dataMiddlewares, mw)
+}
+
+// Use attaches the middlewares to the route, and to the routes of the group.
+func (r *Route) Use(mw ...Middleware) *Route {
+	r.server.middlewaresMu.Lock()
+	defer r.server.middlewaresMu.Unlock()
+	r.middlewares = append(r.middlewares, mw...)
+	r.server.middlewaresGen.Add(1)
+	return r
+}
+
+func (r *Route) useData(mw any) {
+	r.server.middlewaresMu.Lock()
+	defer r.server.middlewaresMu.Unlock()
+	r.dataMiddlewares = append(r.dataMiddlewares, mw)
+}
+
+// UseData attaches the data middlewares to the typed routes with request
+// type T of the server or route. The routes with other request types skip them.
+func UseData[T any](target MiddlewareTarget, mw ...DataMiddleware[T]) {
+	for _, m := range mw {
+		target.useData(m)
+	}
+}
+
+// Before returns the data middleware calling fn before the handler.
+func Before[T any](fn func(req DataRequest[T]) error) DataMiddleware[T] {
+	return func(req DataRequest[T], next func() error) error {
+		if err := fn(req); err != nil {
+			return err
+		}
+		return next()
+	}
+}
+
+// After returns the data middleware calling fn after the handler, with the
+// error of the handler. The error returned by fn replaces it.
+func After[T any](fn func(req DataRequest[T], err error) error) DataMiddleware[T] {
+	return func(req DataRequest[T], next func() error) error {
+		return fn(req, next())
+	}
+}
+
+func (r *Route) middlewareChain() []Middleware {
+	var res []Middleware
+	if r.ParentRoute != nil {
+		res = r.ParentRoute.middlewareChain()
+	} else {
+		res = slices.Clone(r.server.middlewares)
+	}
+	return append(res, r.middlewares...)
+}
+
+func (r *Route) dataMiddlewareChain() []any {
+	var res []any
+	if r.ParentRoute != nil {
+		res = r.ParentRoute.dataMiddlewareChain()
+	} else {
+		res = slices.Clone(r.server.dataMiddlewares)
+	}
+	return append(res, r.dataMiddlewares...)
+}
+
+// wrappedHandler is the handler of a route wrapped by the middlewares of
+// the generation gen.
+type wrappedHandler struct {
+	gen     uint64
+	handler Handler
+}
+
+// wrapCached returns the handler wrapped by the middlewares of the route,
+// built again when middlewares are attached, so that the middlewares
+// attached to the groups after the route registration apply.
+func (r *Route) wrapCached(handler Handler) Handler {
+	gen := r.server.middlewaresGen.Load()
+	if w := r.wrapped.Load(); w != nil && w.gen == gen {
+		return w.handler
+	}
+	h := r.wrap(handler)
+	r.wrapped.Store(&wrappedHandler{gen: gen, handler: h})
+	return h
+}
+
+// wrap returns the handler wrapped by the middlewares of the route.
+func (r *Route) wrap(handler Handler) Handler {
+	r.server.middlewaresMu.RLock()
+	chain := r.middlewareChain()
+	r.server.middlewaresMu.RUnlock()
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	return handler
+}
+
+// runDataMiddlewares calls handle through the data middlewares of the route
+// with request type T.
+func runDataMiddlewares[T any](r *Route, req DataRequest[T], handle func() error) error {
+	r.server.middlewaresMu.RLock()
+	all := r.dataMiddlewareChain()
+	r.server.middlewaresMu.RUnlock()
+
+	var chain []DataMiddleware[T]
+	for _, m := range all {
+		if mw, ok := m.(DataMiddleware[T]); ok {
+			chain = append(chain, mw)
+		}
+	}
+	var next func(i int) error
+	next = func(i int) error {
+		if i == len(chain) {
+			return handle()
+		}
+		return chain[i](req, func() error { return next(i + 1) })
+	}
+	return next(0)
+}
@@ -0,0 +1,299 @@
+package httplib
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"github.com/stretchr/testify/require"
+)
+
+type middlewareItem struct {
+	ID string `params:"id"`
+}
+
+func recordMiddleware(calls *[]string, name string) Middleware {
+	return func(next Handler) Handler {
+		return func(r *Route, c *fiber.Ctx) error {
+			*calls = append(*calls, name+":before")
+			err := next(r, c)
+			*calls = append(*calls, name+":after")
+			return err
+		}
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	server, err := NewServer(ServerOptions{
+		DisableHealthRoutes: true,
+	})
+	require.NoError(t, err)
+
+	var calls []string
+	server.Use(recordMiddleware(&calls, "server"))
+	items := server.Route("/items")
+	items.Handle("GET /:id", DataHandler(func(req DataRequest[middlewareItem]) error {
+		calls = append(calls, "handler")
+		return req.JSON(req.Data)
+	})).Use(recordMiddleware(&calls, "route"))
+	// Attached after the route registration
+	items.Use(recordMiddleware(&calls, "group"))
+
+	UseData(server, Before(func(req DataRequest[middlewareItem]) error {
+		calls = append(calls, "data:"+req.Data.ID)
+		if req.Data.ID == "secret" {
+			return ForbiddenError(errors.New("forbidden item"))
+		}
+		return nil
+	}))
+	UseData(items, After(func(req DataRequest[middlewareItem], err error) error {
+		req.Ctx.Set("X-Item", req.Data.ID)
+		return err
+	}))
+	UseData(server, Before(func(req DataRequest[EmptyData]) error {
+		t.Error("the middlewares of other request types must be skipped")
+		return nil
+	}))
+
+	t.Run("order", func(t *testing.T) {
+		calls = nil
+		res, err := server.app.Test(httptest.NewRequest(http.MethodGet, "/items/a1", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "a1", res.Header.Get("X-Item"))
+		require.Equal(t, []string{
+			"server:before", "group:before", "route:before",
+			"data:a1", "handler",
+			"route:after", "group:after", "server:after",
+		}, calls)
+	})
+
+	t.Run("short-circuit", func(t *testing.T) {
+		calls = nil
+		res, err := server.app.Test(httptest.NewRequest(http.MethodGet, "/items/secret", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+		require.NotContains(t, calls, "handler")
+	})
+}
+
+func TestBundledMiddlewares(t *testing.T) {
+	server, err := NewServer(ServerOptions{
+		DisableHealthRoutes: true,
+	})
+	require.NoError(t, err)
+
+	large := strings.Repeat("gomsvc ", 500)
+	server.Handle("GET", "/large", func(r *Route, c *fiber.Ctx) error {
+		return c.SendString(large)
+	}).Use(Compress(CompressOptions{}), ETag(ETagOptions{}), SecurityHeaders(SecurityHeadersOptions{HSTSMaxAge: time.Hour}))
+
+	api := server.Route("/api").Use(CORS(CORSOptions{
+		AllowOrigins:  []string{"https://app.example.com"},
+		ExposeHeaders: []string{"X-Correlation-ID"},
+		MaxAge:        time.Minute,
+	}), RequestID(RequestIDOptions{Header: "X-Correlation-ID", Generator: func() string { return "corr-1" }}))
+	api.Handle("GET /id", func(r *Route, c *fiber.Ctx) error {
+		return c.SendString(svc.RequestID(c.UserContext()))
+	})
+
+	server.Handle("GET", "/slow", func(r *Route, c *fiber.Ctx) error {
+		<-c.UserContext().Done()
+		return c.UserContext().Err()
+	}).Use(Timeout(10 * time.Millisecond))
+	server.Handle("POST", "/upload", func(r *Route, c *fiber.Ctx) error {
+		return nil
+	}).Use(BodyLimit(8))
+
+	test := func(req *http.Request) *http.Response {
+		res, err := server.app.Test(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	t.Run("compress and etag", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/large", nil)
+		req.Header.Set(fiber.HeaderAcceptEncoding, "gzip")
+		res := test(req)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "gzip", res.Header.Get(fiber.HeaderContentEncoding))
+		require.Equal(t, "nosniff", res.Header.Get(fiber.HeaderXContentTypeOptions))
+		require.Equal(t, "max-age=3600", res.Header.Get(fiber.HeaderStrictTransportSecurity))
+		zr, err := gzip.NewReader(res.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, large, string(body))
+
+		etag := res.Header.Get(fiber.HeaderETag)
+		require.NotEmpty(t, etag)
+		req = httptest.NewRequest(http.MethodGet, "/large", nil)
+		req.Header.Set(fiber.HeaderIfNoneMatch, etag)
+		res = test(req)
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+
+		res = test(httptest.NewRequest(http.MethodGet, "/large", nil))
+		require.Empty(t, res.Header.Get(fiber.HeaderContentEncoding))
+	})
+
+	t.Run("cors and request id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/id", nil)
+		req.Header.Set(fiber.HeaderOrigin, "https://app.example.com")
+		res := test(req)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "https://app.example.com", res.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+		require.Equal(t, "X-Correlation-ID", res.Header.Get(fiber.HeaderAccessControlExposeHeaders))
+		require.Equal(t, "corr-1", res.Header.Get("X-Correlation-ID"))
+		body, _ := io.ReadAll(res.Body)
+		require.Equal(t, "corr-1", string(body))
+
+		req = httptest.NewRequest(http.MethodOptions, "/api/id", nil)
+		req.Header.Set(fiber.HeaderOrigin, "https://app.example.com")
+		req.Header.Set(fiber.HeaderAccessControlRequestMethod, http.MethodPost)
+		res = test(req)
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		require.Contains(t, res.Header.Get(fiber.HeaderAccessControlAllowMethods), http.MethodPost)
+		require.Equal(t, "60", res.Header.Get(fiber.HeaderAccessControlMaxAge))
+
+		req = httptest.NewRequest(http.MethodGet, "/api/id", nil)
+		req.Header.Set(fiber.HeaderOrigin, "https://evil.example.com")
+		res = test(req)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Empty(t, res.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		res := test(httptest.NewRequest(http.MethodGet, "/slow", nil))
+		require.Equal(t, http.StatusRequestTimeout, res.StatusCode)
+	})
+
+	t.Run("body limit", func(t *testing.T) {
+		res := test(httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("0123456789")))
+		require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+		res = test(httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("0123")))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+}
+
+func TestCORSPreflight(t *testing.T) {
+	server, err := NewServer(ServerOptions{DisableHealthRoutes: true})
+	require.NoError(t, err)
+
+	server.Use(CORS(CORSOptions{AllowOrigins: []string{"https://app.example.com"}}))
+	server.Handle("GET", "/items", func(r *Route, c *fiber.Ctx) error {
+		return c.SendString("items")
+	})
+	server.Handle("DELETE", "/items", func(r *Route, c *fiber.Ctx) error {
+		return nil
+	})
+	server.Handle("GET", "/custom", func(r *Route, c *fiber.Ctx) error {
+		return nil
+	})
+	server.Handle("OPTIONS", "/custom", func(r *Route, c *fiber.Ctx) error {
+		return c.SendString("custom options")
+	})
+
+	test := func(path string, headers map[string]string) *http.Response {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := server.app.Test(req)
+		require.NoError(t, err)
+		return res
+	}
+	preflight := map[string]string{
+		fiber.HeaderOrigin:                     "https://app.example.com",
+		fiber.HeaderAccessControlRequestMethod: http.MethodDelete,
+	}
+
+	res := test("/items", preflight)
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	require.Equal(t, "https://app.example.com", res.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+	require.Contains(t, res.Header.Get(fiber.HeaderAccessControlAllowMethods), http.MethodDelete)
+
+	res = test("/items", nil)
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	require.Equal(t, "GET, DELETE, OPTIONS", res.Header.Get(fiber.HeaderAllow))
+
+	res = test("/custom", preflight)
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	require.Equal(t, "https://app.example.com", res.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+
+	res = test("/custom", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	body, _ := io.ReadAll(res.Body)
+	require.Equal(t, "custom options", string(body), "the OPTIONS routes handle the other requests")
+}
+
+func TestMiddlewaresChainCached(t *testing.T) {
+	server, err := NewServer(ServerOptions{DisableHealthRoutes: true})
+	require.NoError(t, err)
+
+	var wraps int
+	counting := func(next Handler) Handler {
+		wraps++
+		return next
+	}
+	server.Use(counting)
+	group := server.Route("/api")
+	group.Handle("GET /ping", func(r *Route, c *fiber.Ctx) error {
+		return c.SendString("pong")
+	})
+
+	test := func() {
+		res, err := server.app.Test(httptest.NewRequest(http.MethodGet, "/api/ping", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	}
+	test()
+	test()
+	require.Equal(t, 1, wraps, "the chain is built once")
+
+	var calls []string
+	group.Use(recordMiddleware(&calls, "group"))
+	test()
+	require.Equal(t, 2, wraps, "the chain is built again after Use")
+	require.Equal(t, []string{"group:before", "group:after"}, calls)
+}
+
+func TestMiddlewaresConcurrentUse(t *testing.T) {
+	server, err := NewServer(ServerOptions{DisableHealthRoutes: true})
+	require.NoError(t, err)
+
+	group := server.Route("/api")
+	group.Handle("GET /ping", DataHandler(func(req DataRequest[EmptyData]) error {
+		return req.Ctx.SendString("pong")
+	}))
+
+	noop := func(next Handler) Handler { return next }
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			server.Use(noop)
+			group.Use(noop)
+			UseData(server, Before(func(req DataRequest[EmptyData]) error { return nil }))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			res, err := server.app.Test(httptest.NewRequest(http.MethodGet, "/api/ping", nil))
+			if err != nil || res.StatusCode != http.StatusOK {
+				t.Errorf("unexpected response %v %v", res, err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,302 @@
+package httplib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"github.com/valyala/fasthttp"
+	"go.jetpack.io/typeid"
+)
+
+var (
+	ErrRequestTimeout = errors.New("request timeout")
+	ErrBodyTooLarge   = errors.New("request body too large")
+)
+
+type RequestIDOptions struct {
+	// Header is the header of the request ID, defaults to RequestIDHeader
+	Header string
+	// Generator generates the IDs of the requests without one, defaults to
+	// a "req" prefixed TypeID
+	Generator func() string
+}
+
+// RequestID takes the request ID from the header, or generates it, setting
+// it on the response and on the request context.
+func RequestID(opts RequestIDOptions) Middleware {
+	if opts.Header == "" {
+		opts.Header = RequestIDHeader
+	}
+	return func(next Handler) Handler {
+		return func(r *Route, c *fiber.Ctx) error {
+			id := c.Get(opts.Header)
+			if id == "" {
+				if opts.Generator != nil {
+					id = opts.Generator()
+				} else {
+					t, err := typeid.From("req", "")
+					if err != nil {
+						return err
+					}
+					id = t.String()
+				}
+			}
+			c.Set(opts.Header, id)
+			c.SetUserContext(svc.WithRequestID(c.UserContext(), id))
+			return next(r, c)
+		}
+	}
+}
+
+type CORSOptions struct {
+	// AllowOrigins are the allowed origins, "*" allowing all of them
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORS sets the CORS headers for the allowed origins. The requests from the
+// other origins are handled without them, so that the browsers block their
+// responses. The preflight requests are answered by CORS through the
+// OPTIONS routes that the server registers for the paths of the routes,
+// running the middlewares of the route of the requested method.
+func CORS(opts CORSOptions) Middleware {
+	if len(opts.AllowMethods) == 0 {
+		opts.AllowMethods = []string{fiber.MethodGet, fiber.MethodPost, fiber.MethodHead, fiber.MethodPut, fiber.MethodDelete, fiber.MethodPatch}
+	}
+	allowMethods := strings.Join(opts.AllowMethods, ", ")
+	allowHeaders := strings.Join(opts.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(opts.ExposeHeaders, ", ")
+
+	allowed := func(origin string) string {
+		for _, o := range opts.AllowOrigins {
+			if o == "*" && !opts.AllowCredentials {
+				return "*"
+			}
+			if o == "*" || strings.EqualFold(o, origin) {
+				return origin
+			}
+		}
+		return ""
+	}
+
+	return func(next Handler) Handler {
+		return func(r *Route, c *fiber.Ctx) error {
+			origin := c.Get(fiber.HeaderOrigin)
+			if origin == "" {
+				return next(r, c)
+			}
+			c.Vary(fiber.HeaderOrigin)
+			allowOrigin := allowed(origin)
+			if allowOrigin == "" {
+				return next(r, c)
+			}
+			c.Set(fiber.HeaderAccessControlAllowOrigin, allowOrigin)
+			if opts.AllowCredentials {
+				c.Set(fiber.HeaderAccessControlAllowCredentials, "true")
+			}
+
+			if c.Method() != fiber.MethodOptions || c.Get(fiber.HeaderAccessControlRequestMethod) == "" {
+				if exposeHeaders != "" {
+					c.Set(fiber.HeaderAccessControlExposeHeaders, exposeHeaders)
+				}
+				return next(r, c)
+			}
+
+			// Preflight request
+			c.Set(fiber.HeaderAccessControlAllowMethods, allowMethods)
+			if allowHeaders != "" {
+				c.Set(fiber.HeaderAccessControlAllowHeaders, allowHeaders)
+			} else if h := c.Get(fiber.HeaderAccessControlRequestHeaders); h != "" {
+				c.Set(fiber.HeaderAccessControlAllowHeaders, h)
+			}
+			if opts.MaxAge > 0 {
+				c.Set(fiber.HeaderAccessControlMaxAge, strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+	}
+}
+
+type SecurityHeadersOptions struct {
+	// ContentSecurityPolicy is the Content-Security-Policy header, not set if empty
+	ContentSecurityPolicy string
+	// FrameOptions is the X-Frame-Options header, defaults to DENY
+	FrameOptions string
+	// ReferrerPolicy is the Referrer-Policy header, defaults to no-referrer
+	ReferrerPolicy string
+	// HSTSMaxAge enables the Strict-Transport-Security header
+	HSTSMaxAge time.Duration
+	// HSTSIncludeSubdomains adds includeSubDomains to the Strict-Transport-Security header
+	HSTSIncludeSubdomains bool
+}
+
+// SecurityHeaders sets the security headers of the responses.
+func SecurityHeaders(opts SecurityHeadersOptions) Middleware {
+	if opts.FrameOptions == "" {
+		opts.FrameOptions = "DENY"
+	}
+	if opts.ReferrerPolicy == "" {
+		opts.ReferrerPolicy = "no-referrer"
+	}
+	headers := map[string]string{
+		fiber.HeaderXContentTypeOptions: "nosniff",
+		fiber.HeaderXFrameOptions:       opts.FrameOptions,
+		fiber.HeaderReferrerPolicy:      opts.ReferrerPolicy,
+		"Cross-Origin-Opener-Policy":    "same-origin",
+		"Cross-Origin-Resource-Policy":  "same-origin",
+	}
+	if opts.ContentSecurityPolicy != "" {
+		headers[fiber.HeaderContentSecurityPolicy] = opts.ContentSecurityPolicy
+	}
+	if opts.HSTSMaxAge > 0 {
+		hsts := fmt.Sprintf("max-age=%d", int(opts.HSTSMaxAge.Seconds()))
+		if opts.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		headers[fiber.HeaderStrictTransportSecurity] = hsts
+	}
+	return func(next Handler) Handler {
+		return func(r *Route, c *fiber.Ctx) error {
+			for k, v := range headers {
+				c.Set(k, v)
+			}
+			return next(r, c)
+		}
+	}
+}
+
+type CompressOptions struct {
+	// Level is the compression level, defaults to the default level of the encodings
+	Level int
+	// MinSize is the minimum size of the compressed bodies, defaults to 1024 bytes
+	MinSize int
+}
+
+// Compress compresses the response bodies with the brotli, gzip or deflate
+// encoding accepted by the request.
+func Compress(opts CompressOptions) Middleware {
+	if opts.MinSize == 0 {
+		opts.MinSize = 1024
+	}
+	return func(next Handler) Handler {
+		return func(r *Route, c *fiber.Ctx) error {
+			if err := next(r, c); err != nil {
+				return err
+			}
+			c.Vary(fiber.HeaderAcceptEncoding)
+			res := c.Response()
+			if c.Get(fiber.HeaderAcceptEncoding) == "" || res.IsBodyStream() ||
+				len(res.Header.Peek(fiber.HeaderContentEncoding)) > 0 || len(res.Body()) < opts.MinSize {
+				return nil
+			}
+			body := res.Body()
+			level := opts.Level
+			var compressed []byte
+			encoding := c.AcceptsEncodings("br", "gzip", "deflate")
+			switch encoding {
+			case "br":
+				if level == 0 {
+					level = fasthttp.CompressBrotliDefaultCompression
+				}
+				compressed = fasthttp.AppendBrotliBytesLevel(nil, body, level)
+			case "gzip":
+				if level == 0 {
+					level = fasthttp.CompressDefaultCompression
+				}
+				compressed = fasthttp.AppendGzipBytesLevel(nil, body, level)
+			case "deflate":
+				if level == 0 {
+					level = fasthttp.CompressDefaultCompression
+				}
+				compressed = fasthttp.AppendDeflateBytesLevel(nil, body, level)
+			default:
+				return nil
+			}
+			res.SetBodyRaw(compressed)
+			res.Header.Set(fiber.HeaderContentEncoding, encoding)
+			return nil
+		}
+	}
+}
+
+type ETagOptions struct {
+	// Weak generates weak ETags
+	Weak bool
+}
+
+// ETag sets the ETag of the successful GET and HEAD responses, replying
+// with status 304 to the requests with a matching If-None-Match header.
+func ETag(opts ETagOptions) Middleware {
+	return func(next Handler) Handler {
+		return func(r *Route, c *fiber.Ctx) error {
+			if err := next(r, c); err != nil {
+				return err
+			}
+			if (c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead) ||
+				c.Response().StatusCode() != fiber.StatusOK || c.Response().IsBodyStream() {
+				return nil
+			}
+			etag := c.GetRespHeader(fiber.HeaderETag)
+			if etag == "" {
+				body := c.Response().Body()
+				etag = fmt.Sprintf(`"%d-%08x"`, len(body), crc32.ChecksumIEEE(body))
+				if opts.Weak {
+					etag = "W/" + etag
+				}
+				c.Set(fiber.HeaderETag, etag)
+			}
+			for _, match := range strings.Split(c.Get(fiber.HeaderIfNoneMatch), ",") {
+				match = strings.TrimSpace(match)
+				if match == "*" || strings.TrimPrefix(match, "W/") == strings.TrimPrefix(etag, "W/") {
+					c.Context().ResetBody()
+					c.Status(fiber.StatusNotModified)
+					return nil
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// Timeout sets the deadline of the request context, failing with status
+// 408 the requests whose handler exceeds it. The handlers must observe the
+// context to stop.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(r *Route, c *fiber.Ctx) error {
+			parent := c.UserContext()
+			ctx, cancel := context.WithTimeout(parent, timeout)
+			defer cancel()
+			c.SetUserContext(ctx)
+			err := next(r, c)
+			c.SetUserContext(parent)
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return Error(fiber.StatusRequestTimeout, ErrRequestTimeout)
+			}
+			return err
+		}
+	}
+}
+
+// BodyLimit fails with status 413 the requests with a body larger than limit bytes.
+func BodyLimit(limit int) Middleware {
+	return func(next Handler) Handler {
+		return func(r *Route, c *fiber.Ctx) error {
+			if c.Request().Header.ContentLength() > limit || len(c.Request().Body()) > limit {
+				return Error(fiber.StatusRequestEntityTooLarge, ErrBodyTooLarge)
+			}
+			return next(r, c)
+		}
+	}
+}
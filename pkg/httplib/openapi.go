@@ -0,0 +1,490 @@
+package httplib
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+)
+
+const (
+	OpenAPIVersion        = "3.1.0"
+	DefaultOpenAPIPath    = "/openapi.json"
+	DefaultOpenAPIUIPath  = "/docs"
+	DefaultSwaggerUIURL   = "https://unpkg.com/swagger-ui-dist@5.17.14"
+	DefaultRedocScriptURL = "https://unpkg.com/redoc@2.1.5/bundles/redoc.standalone.js"
+)
+
+// OpenAPIUI is the documentation UI served with the OpenAPI document.
+type OpenAPIUI string
+
+const (
+	OpenAPIUISwagger OpenAPIUI = "swagger"
+	OpenAPIUIRedoc   OpenAPIUI = "redoc"
+	OpenAPIUINone    OpenAPIUI = "none"
+)
+
+type OpenAPIOptions struct {
+	Info OpenAPIInfo
+	// Servers are the base URLs of the API
+	Servers []string
+	// Path of the JSON document, defaults to DefaultOpenAPIPath
+	Path string
+	// UI is the documentation UI, defaults to OpenAPIUISwagger
+	UI OpenAPIUI
+	// UIPath is the path of the documentation UI, defaults to DefaultOpenAPIUIPath
+	UIPath string
+	// UIAssetsURL is the base URL of the swagger-ui-dist assets, or the URL
+	// of the Redoc script, defaulting to the pinned versions on the public CDN
+	UIAssetsURL string
+	// UIAssetsIntegrity are the Subresource Integrity hashes of the assets,
+	// keyed by file name: swagger-ui.css and swagger-ui-bundle.js, or
+	// redoc.standalone.js. The browsers refuse the assets not matching them.
+	UIAssetsIntegrity map[string]string
+	// UIAssets serves the assets from the file system, e.g. an embed.FS with
+	// the swagger-ui-dist files or redoc.standalone.js at its root, under
+	// UIPath/assets in place of UIAssetsURL
+	UIAssets fs.FS
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIDocument is the OpenAPI 3.1 document of the server routes.
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// RouteDoc describes the operation of a route in the OpenAPI document.
+type RouteDoc struct {
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// Hidden excludes the route from the document
+	Hidden bool
+}
+
+type routeResponse struct {
+	status      int
+	description string
+	typ         reflect.Type
+	// types are the content types of the body, defaulting to JSON
+	types []string
+}
+
+var reRouteParam = regexp.MustCompile(`:([A-Za-z0-9_-]+)(<[^>]*>)?\??|[*+]`)
+
+// Doc sets the description of the route in the OpenAPI document.
+func (r *Route) Doc(doc RouteDoc) *Route {
+	r.doc = doc
+	return r
+}
+
+// Returns declares a response of the route with the JSON body R, or without
+// body if R is EmptyData. The routes without success responses declared are
+// documented with an empty 200 response.
+func Returns[R any](r *Route, status int, description string) *Route {
+	res := routeResponse{status: status, description: description}
+	if t := reflect.TypeFor[R](); t != reflect.TypeFor[EmptyData]() {
+		res.typ = t
+	}
+	r.responses = append(r.responses, res)
+	return r
+}
+
+// fullPath returns the path of the route including the paths of its groups.
+func (r *Route) fullPath() string {
+	path := r.path
+	if r.ParentRoute != nil {
+		path = strings.TrimSuffix(r.ParentRoute.fullPath(), "/") + "/" + strings.TrimPrefix(path, "/")
+	}
+	return path
+}
+
+// OpenAPI generates the OpenAPI document of the routes registered on the server.
+func (s *Server) OpenAPI() *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+	if s.openAPI != nil {
+		doc.Info = s.openAPI.Info
+		for _, url := range s.openAPI.Servers {
+			doc.Servers = append(doc.Servers, OpenAPIServer{URL: url})
+		}
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "0.0.0"
+	}
+
+	g := newSchemaGenerator()
+	errorSchema, errorType := g.schema(reflect.TypeFor[ResponseErrorEnvelope]()), fiber.MIMEApplicationJSON
+	if s.problems != nil {
+		errorSchema, errorType = g.schema(reflect.TypeFor[Problem]()), TypeProblemJSON
+	}
+
+	s.routesMu.Lock()
+	routes := slices.Clone(s.routes)
+	s.routesMu.Unlock()
+
+	for _, r := range routes {
+		if r.doc.Hidden || r.method == "" {
+			continue
+		}
+		path, pathParams := openAPIPath(r.fullPath())
+		op := &OpenAPIOperation{
+			OperationID: r.doc.OperationID,
+			Summary:     r.doc.Summary,
+			Description: r.doc.Description,
+			Tags:        r.doc.Tags,
+			Deprecated:  r.doc.Deprecated,
+			Responses:   make(map[string]*OpenAPIResponse),
+		}
+
+		params := make(map[string]*OpenAPIParameter)
+		if r.reqType != nil {
+			op.RequestBody = requestParameters(g, r.reqType, params)
+		}
+		for _, name := range pathParams {
+			if p, ok := params["path:"+name]; ok {
+				p.Required = true
+				continue
+			}
+			params["path:"+name] = &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}}
+		}
+		for _, p := range params {
+			if p.In == "path" && !slices.Contains(pathParams, p.Name) {
+				continue
+			}
+			op.Parameters = append(op.Parameters, p)
+		}
+		slices.SortFunc(op.Parameters, func(a, b *OpenAPIParameter) int {
+			return strings.Compare(a.In+":"+a.Name, b.In+":"+b.Name)
+		})
+
+		success := false
+		for _, res := range r.responses {
+			resp := &OpenAPIResponse{Description: res.description}
+			if resp.Description == "" {
+				resp.Description = http.StatusText(res.status)
+			}
+			if res.typ != nil {
+				schema := g.schema(res.typ)
+				types := res.types
+				if len(types) == 0 {
+					types = []string{fiber.MIMEApplicationJSON}
+				}
+				resp.Content = make(map[string]OpenAPIMediaType, len(types))
+				for _, t := range types {
+					resp.Content[t] = OpenAPIMediaType{Schema: schema}
+				}
+			}
+			op.Responses[strconv.Itoa(res.status)] = resp
+			success = success || res.status < 400
+		}
+		if !success {
+			op.Responses["200"] = &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+		}
+
+		errorStatuses := []int{http.StatusInternalServerError}
+		if r.reqType != nil || r.getValidationFunc() != nil {
+			errorStatuses = append(errorStatuses, http.StatusBadRequest)
+		}
+		if r.getAuthorizationFunc() != nil {
+			errorStatuses = append(errorStatuses, http.StatusUnauthorized)
+		}
+		for _, status := range errorStatuses {
+			if _, ok := op.Responses[strconv.Itoa(status)]; !ok {
+				op.Responses[strconv.Itoa(status)] = &OpenAPIResponse{
+					Description: http.StatusText(status),
+					Content:     map[string]OpenAPIMediaType{errorType: {Schema: errorSchema}},
+				}
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(r.method)] = op
+	}
+
+	doc.Components.Schemas = g.components
+	return doc
+}
+
+// openAPIPath converts the fiber route path to the OpenAPI template,
+// returning the names of its parameters.
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	wildcards := 0
+	res := reRouteParam.ReplaceAllStringFunc(path, func(m string) string {
+		var name string
+		if m == "*" || m == "+" {
+			wildcards++
+			name = m + strconv.Itoa(wildcards)
+		} else {
+			name = reRouteParam.FindStringSubmatch(m)[1]
+		}
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	return res, params
+}
+
+// requestParameters collects the parameters of the request type of a
+// typed route, returning its request body.
+func requestParameters(g *schemaGenerator, t reflect.Type, params map[string]*OpenAPIParameter) (body *OpenAPIRequestBody) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	form := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	formType := "application/x-www-form-urlencoded"
+	// The fields of the untagged embedded structs are promoted, as in the binding
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if isEmbeddedStruct(f) && f.Tag.Get("req") == "" && !hasFiberTag(f) {
+				addFields(indirectType(f.Type))
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			validate := f.Tag.Get("validate")
+
+			if tag := f.Tag.Get("req"); tag != "" {
+				src, key, opts := getTagParts(tag)
+				switch src {
+				case "body":
+					schema := g.schema(f.Type)
+					body = &OpenAPIRequestBody{
+						Required: applyValidate(schema, f.Type, validate),
+						Content:  make(map[string]OpenAPIMediaType),
+					}
+					for _, typ := range bodyContentTypes(f.Type) {
+						body.Content[typ] = OpenAPIMediaType{Schema: schema}
+					}
+				case "query", "header", "cookie":
+					addParameter(g, params, f, src, key, validate)
+				case "param":
+					addParameter(g, params, f, "path", key, validate)
+				case "form":
+					addFormProperty(form, g.schema(f.Type), f, key, validate)
+				case "file":
+					formType = "multipart/form-data"
+					schema := &OpenAPISchema{Type: "string", ContentMediaType: "application/octet-stream"}
+					for _, opt := range strings.Split(opts, ",") {
+						if name, value, _ := strings.Cut(opt, "="); name == "types" && len(strings.Fields(value)) == 1 {
+							schema.ContentMediaType = value
+						}
+					}
+					if f.Type.Kind() == reflect.Slice {
+						schema = &OpenAPISchema{Type: "array", Items: schema}
+					}
+					addFormProperty(form, schema, f, key, validate)
+				}
+				continue
+			}
+			for tag, in := range map[string]string{"params": "path", "query": "query", "reqHeader": "header"} {
+				if key, _, _ := strings.Cut(f.Tag.Get(tag), ","); key != "" && key != "-" {
+					addParameter(g, params, f, in, key, validate)
+				}
+			}
+		}
+	}
+	addFields(t)
+	if body == nil && len(form.Properties) > 0 {
+		body = &OpenAPIRequestBody{
+			Required: len(form.Required) > 0,
+			Content:  map[string]OpenAPIMediaType{formType: {Schema: form}},
+		}
+	}
+	return body
+}
+
+// hasFiberTag reports whether the field has a params, query or reqHeader tag.
+func hasFiberTag(f reflect.StructField) bool {
+	for _, src := range fiberTagSources {
+		if f.Tag.Get(src[0]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func addFormProperty(form *OpenAPISchema, schema *OpenAPISchema, f reflect.StructField, name string, validate string) {
+	form.Properties[name] = schema
+	if applyValidate(schema, f.Type, validate) {
+		form.Required = append(form.Required, name)
+	}
+}
+
+func addParameter(g *schemaGenerator, params map[string]*OpenAPIParameter, f reflect.StructField, in string, name string, validate string) {
+	schema := g.schema(f.Type)
+	params[in+":"+name] = &OpenAPIParameter{
+		Name:     name,
+		In:       in,
+		Required: applyValidate(schema, f.Type, validate),
+		Schema:   schema,
+	}
+}
+
+// registerOpenAPIRoutes serves the OpenAPI document and its UI.
+func (s *Server) registerOpenAPIRoutes(opts OpenAPIOptions) error {
+	if opts.Path == "" {
+		opts.Path = DefaultOpenAPIPath
+	}
+	if opts.UIPath == "" {
+		opts.UIPath = DefaultOpenAPIUIPath
+	}
+	s.openAPI = &opts
+
+	s.app.Get(opts.Path, func(c *fiber.Ctx) error {
+		return c.JSON(s.OpenAPI())
+	})
+
+	var page *template.Template
+	assetsURL := opts.UIAssetsURL
+	assetsPath := strings.TrimSuffix(opts.UIPath, "/") + "/assets"
+	switch opts.UI {
+	case "", OpenAPIUISwagger:
+		page = swaggerUIPage
+		if opts.UIAssets != nil {
+			assetsURL = assetsPath
+		} else if assetsURL == "" {
+			assetsURL = DefaultSwaggerUIURL
+		}
+	case OpenAPIUIRedoc:
+		page = redocPage
+		if opts.UIAssets != nil {
+			assetsURL = assetsPath + "/redoc.standalone.js"
+		} else if assetsURL == "" {
+			assetsURL = DefaultRedocScriptURL
+		}
+	case OpenAPIUINone:
+		return nil
+	default:
+		return fmt.Errorf("unknown OpenAPI UI: %s", opts.UI)
+	}
+
+	if opts.UIAssets != nil {
+		assets := http.FS(opts.UIAssets)
+		s.app.Get(assetsPath+"/*", func(c *fiber.Ctx) error {
+			return filesystem.SendFile(c, assets, c.Params("*"))
+		})
+	}
+
+	var html strings.Builder
+	err := page.Execute(&html, map[string]any{
+		"Title":     opts.Info.Title,
+		"SpecURL":   opts.Path,
+		"AssetsURL": strings.TrimSuffix(assetsURL, "/"),
+		"Integrity": opts.UIAssetsIntegrity,
+	})
+	if err != nil {
+		return err
+	}
+	s.app.Get(opts.UIPath, func(c *fiber.Ctx) error {
+		c.Type("html")
+		return c.SendString(html.String())
+	})
+	return nil
+}
+
+var swaggerUIPage = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.AssetsURL}}/swagger-ui.css"{{with index .Integrity "swagger-ui.css"}} integrity="{{.}}" crossorigin="anonymous"{{end}}>
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.AssetsURL}}/swagger-ui-bundle.js"{{with index .Integrity "swagger-ui-bundle.js"}} integrity="{{.}}"{{end}} crossorigin="anonymous"></script>
+<script>
+window.onload = () => {
+  window.ui = SwaggerUIBundle({ url: "{{.SpecURL}}", dom_id: "#swagger-ui" });
+};
+</script>
+</body>
+</html>
+`))
+
+var redocPage = template.Must(template.New("redoc").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body>
+<redoc spec-url="{{.SpecURL}}"></redoc>
+<script src="{{.AssetsURL}}"{{with index .Integrity "redoc.standalone.js"}} integrity="{{.}}" crossorigin="anonymous"{{end}}></script>
+</body>
+</html>
+`))
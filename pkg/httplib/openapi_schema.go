@@ -0,0 +1,289 @@
+package httplib
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPISchema is a JSON Schema of the OpenAPI 3.1 document.
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	ContentEncoding      string                    `json:"contentEncoding,omitempty"`
+	ContentMediaType     string                    `json:"contentMediaType,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Enum                 []any                     `json:"enum,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64                  `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64                  `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	reSchemaName   = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+	validateFormat = map[string]string{
+		"email":    "email",
+		"url":      "uri",
+		"uri":      "uri",
+		"http_url": "uri",
+		"uuid":     "uuid",
+		"uuid4":    "uuid",
+		"ipv4":     "ipv4",
+		"ipv6":     "ipv6",
+		"hostname": "hostname",
+	}
+	validatePattern = map[string]string{
+		"alpha":    "^[a-zA-Z]+$",
+		"alphanum": "^[a-zA-Z0-9]+$",
+		"numeric":  "^[-+]?[0-9]+(?:\\.[0-9]+)?$",
+		"number":   "^[0-9]+$",
+	}
+)
+
+// schemaGenerator builds the schemas of the Go types, collecting the named
+// structs as components.
+type schemaGenerator struct {
+	components map[string]*OpenAPISchema
+	names      map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		components: make(map[string]*OpenAPISchema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	return &OpenAPISchema{}
+}
+
+// component registers the named struct type, returning its component name.
+func (g *schemaGenerator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := reSchemaName.ReplaceAllString(t.Name(), "_")
+	if _, taken := g.components[name]; taken {
+		pkg := t.PkgPath()
+		name = reSchemaName.ReplaceAllString(pkg[strings.LastIndex(pkg, "/")+1:]+"."+t.Name(), "_")
+	}
+	// Registered before the properties to support the recursive types
+	g.names[t] = name
+	g.components[name] = &OpenAPISchema{}
+	*g.components[name] = *g.object(t)
+	return name
+}
+
+func (g *schemaGenerator) object(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	g.fields(t, s)
+	return s
+}
+
+func (g *schemaGenerator) fields(t reflect.Type, s *OpenAPISchema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := jsonFieldName(f)
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, s)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schema(f.Type)
+		required := applyValidate(fs, f.Type, f.Tag.Get("validate"))
+		if d := f.Tag.Get("description"); d != "" {
+			fs.Description = d
+		}
+		s.Properties[name] = fs
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name
+}
+
+// applyValidate adds to s the constraints of the validator tag, reporting
+// whether the value is required. The rules after dive, and the alternatives,
+// are not represented.
+func applyValidate(s *OpenAPISchema, t reflect.Type, tag string) (required bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			break
+		}
+		if rule == "" || strings.Contains(rule, "|") {
+			continue
+		}
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "min", "gte":
+			setMin(s, t, value, false)
+		case "max", "lte":
+			setMax(s, t, value, false)
+		case "gt":
+			setMin(s, t, value, true)
+		case "lt":
+			setMax(s, t, value, true)
+		case "len":
+			setMin(s, t, value, false)
+			setMax(s, t, value, false)
+		case "oneof":
+			for _, v := range strings.Fields(value) {
+				s.Enum = append(s.Enum, enumValue(t, v))
+			}
+		default:
+			if f, ok := validateFormat[key]; ok {
+				s.Format = f
+			} else if p, ok := validatePattern[key]; ok {
+				s.Pattern = p
+			}
+		}
+	}
+	return
+}
+
+func setMin(s *OpenAPISchema, t reflect.Type, value string, exclusive bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return
+	case reflect.String:
+		if exclusive {
+			n++
+		}
+		s.MinLength = ptr(int(n))
+	case reflect.Slice, reflect.Array:
+		if exclusive {
+			n++
+		}
+		s.MinItems = ptr(int(n))
+	default:
+		if exclusive {
+			s.ExclusiveMinimum = &n
+		} else {
+			s.Minimum = &n
+		}
+	}
+}
+
+func setMax(s *OpenAPISchema, t reflect.Type, value string, exclusive bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return
+	case reflect.String:
+		if exclusive {
+			n--
+		}
+		s.MaxLength = ptr(int(n))
+	case reflect.Slice, reflect.Array:
+		if exclusive {
+			n--
+		}
+		s.MaxItems = ptr(int(n))
+	default:
+		if exclusive {
+			s.ExclusiveMaximum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
+
+func enumValue(t reflect.Type, v string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,187 @@
+package httplib
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sandrolain/gomsvc/pkg/datalib"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type openAPIUser struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name" validate:"required,min=2,max=64"`
+	Email     string    `json:"email,omitempty" validate:"omitempty,email"`
+	Role      string    `json:"role" validate:"oneof=admin user"`
+	Tags      []string  `json:"tags,omitempty" validate:"max=5,dive,alpha"`
+	CreatedAt time.Time `json:"createdAt"`
+	Manager   *openAPIUser
+	secret    string
+}
+
+type openAPIUpdateUser struct {
+	ID      string       `params:"id" validate:"required,uuid"`
+	DryRun  bool         `query:"dryRun"`
+	Fields  []string     `req:"query:fields:csv" validate:"max=3"`
+	TraceID string       `reqHeader:"X-Trace-ID"`
+	Body    *openAPIUser `req:"body" validate:"required"`
+}
+
+type openAPIEcho struct {
+	Body *wrapperspb.StringValue `req:"body"`
+}
+
+type openAPIListUsers struct {
+	Limit int `query:"limit" validate:"gte=1,lte=100"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	server, err := NewServer(ServerOptions{
+		DisableHealthRoutes: true,
+		OpenAPI: &OpenAPIOptions{
+			Info: OpenAPIInfo{Title: "Users", Version: "1.0.0"},
+			UI:   OpenAPIUIRedoc,
+		},
+	})
+	require.NoError(t, err)
+
+	users := server.Route("/users")
+	Returns[[]openAPIUser](Get[openAPIListUsers](server, "/users", func(req DataRequest[openAPIListUsers]) error {
+		return nil
+	}), http.StatusOK, "The users")
+	Returns[EmptyData](Put[openAPIUpdateUser](server, "/users/:id<guid>", func(req DataRequest[openAPIUpdateUser]) error {
+		return nil
+	}).Doc(RouteDoc{OperationID: "updateUser", Tags: []string{"users"}}).AuthWith(func(ctx *fiber.Ctx, r *Route) error {
+		return nil
+	}), http.StatusNoContent, "")
+	users.Handle("DELETE /:id", func(r *Route, c *fiber.Ctx) error { return nil })
+	server.Handle("GET", "/internal", func(r *Route, c *fiber.Ctx) error { return nil }).Doc(RouteDoc{Hidden: true})
+
+	doc := server.OpenAPI()
+	require.Equal(t, OpenAPIVersion, doc.OpenAPI)
+	require.Equal(t, "Users", doc.Info.Title)
+	require.NotContains(t, doc.Paths, "/internal")
+
+	list := doc.Paths["/users"]["get"]
+	require.NotNil(t, list)
+	require.Len(t, list.Parameters, 1)
+	require.Equal(t, "limit", list.Parameters[0].Name)
+	require.Equal(t, 1.0, *list.Parameters[0].Schema.Minimum)
+	require.Equal(t, 100.0, *list.Parameters[0].Schema.Maximum)
+	require.Equal(t, "array", list.Responses["200"].Content[fiber.MIMEApplicationJSON].Schema.Type)
+	require.Equal(t, "#/components/schemas/openAPIUser", list.Responses["200"].Content[fiber.MIMEApplicationJSON].Schema.Items.Ref)
+	require.Contains(t, list.Responses, "400")
+	require.NotContains(t, list.Responses, "401")
+
+	update := doc.Paths["/users/{id}"]["put"]
+	require.NotNil(t, update)
+	require.Equal(t, "updateUser", update.OperationID)
+	var params []string
+	for _, p := range update.Parameters {
+		params = append(params, p.In+":"+p.Name)
+	}
+	require.Equal(t, []string{"header:X-Trace-ID", "path:id", "query:dryRun", "query:fields"}, params)
+	require.Equal(t, "uuid", update.Parameters[1].Schema.Format)
+	require.Equal(t, 3, *update.Parameters[3].Schema.MaxItems)
+	require.True(t, update.RequestBody.Required)
+	require.Contains(t, update.RequestBody.Content, datalib.TypeMsgpack)
+	require.Contains(t, update.RequestBody.Content, datalib.TypeCBOR)
+	require.NotContains(t, update.RequestBody.Content, datalib.TypeProtobuf, "the body is not a protobuf message")
+	require.Contains(t, update.Responses, "204")
+	require.NotContains(t, update.Responses, "200")
+	require.Equal(t, "#/components/schemas/ResponseErrorEnvelope", update.Responses["401"].Content[fiber.MIMEApplicationJSON].Schema.Ref)
+
+	remove := doc.Paths["/users/{id}"]["delete"]
+	require.NotNil(t, remove)
+	require.Equal(t, "path", remove.Parameters[0].In)
+	require.Contains(t, remove.Responses, "200")
+
+	user := doc.Components.Schemas["openAPIUser"]
+	require.Equal(t, []string{"name"}, user.Required)
+	require.Equal(t, 2, *user.Properties["name"].MinLength)
+	require.Equal(t, "email", user.Properties["email"].Format)
+	require.Equal(t, []any{"admin", "user"}, user.Properties["role"].Enum)
+	require.Equal(t, 5, *user.Properties["tags"].MaxItems)
+	require.Empty(t, user.Properties["tags"].Items.Pattern, "the rules after dive are not applied")
+	require.Equal(t, "date-time", user.Properties["createdAt"].Format)
+	require.Equal(t, "#/components/schemas/openAPIUser", user.Properties["Manager"].Ref)
+	require.NotContains(t, user.Properties, "secret")
+
+	res, err := server.app.Test(httptest.NewRequest(http.MethodGet, DefaultOpenAPIPath, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var served map[string]any
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&served))
+	require.Equal(t, OpenAPIVersion, served["openapi"])
+
+	res, err = server.app.Test(httptest.NewRequest(http.MethodGet, DefaultOpenAPIUIPath, nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	require.True(t, strings.Contains(string(body), `<redoc spec-url="/openapi.json">`))
+}
+
+func TestOpenAPIProtobufBody(t *testing.T) {
+	server, err := NewServer(ServerOptions{DisableHealthRoutes: true, OpenAPI: &OpenAPIOptions{UI: OpenAPIUINone}})
+	require.NoError(t, err)
+
+	var received string
+	Post[openAPIEcho](server, "/echo", func(req DataRequest[openAPIEcho]) error {
+		received = req.Data.Body.GetValue()
+		return nil
+	})
+
+	echo := server.OpenAPI().Paths["/echo"]["post"]
+	require.NotNil(t, echo)
+	for _, typ := range []string{datalib.TypeJson, datalib.TypeMsgpack, datalib.TypeXMsgpack, datalib.TypeProtobuf, datalib.TypeCBOR} {
+		require.Contains(t, echo.RequestBody.Content, typ)
+	}
+
+	body, err := proto.Marshal(wrapperspb.String("hello"))
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(string(body)))
+	req.Header.Set(fiber.HeaderContentType, datalib.TypeProtobuf)
+	res, err := server.app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "hello", received)
+}
+
+func TestOpenAPIUIAssets(t *testing.T) {
+	server, err := NewServer(ServerOptions{
+		DisableHealthRoutes: true,
+		OpenAPI: &OpenAPIOptions{
+			UIAssets: fstest.MapFS{
+				"swagger-ui.css":       {Data: []byte("body {}")},
+				"swagger-ui-bundle.js": {Data: []byte("var SwaggerUIBundle;")},
+			},
+			UIAssetsIntegrity: map[string]string{"swagger-ui-bundle.js": "sha384-bundle"},
+		},
+	})
+	require.NoError(t, err)
+
+	res, err := server.app.Test(httptest.NewRequest(http.MethodGet, DefaultOpenAPIUIPath, nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	require.Contains(t, string(body), `href="/docs/assets/swagger-ui.css">`)
+	require.Contains(t, string(body), `src="/docs/assets/swagger-ui-bundle.js" integrity="sha384-bundle" crossorigin="anonymous"`)
+	require.NotContains(t, string(body), "unpkg.com")
+
+	res, err = server.app.Test(httptest.NewRequest(http.MethodGet, "/docs/assets/swagger-ui.css", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	body, _ = io.ReadAll(res.Body)
+	require.Equal(t, "body {}", string(body))
+
+	res, err = server.app.Test(httptest.NewRequest(http.MethodGet, "/docs/assets/missing.js", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
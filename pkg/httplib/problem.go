@@ -0,0 +1,221 @@
+package httplib
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TypeProblemJSON is the content type of the RFC 9457 problem details.
+const TypeProblemJSON = "application/problem+json"
+
+// DefaultProblemLanguage is the language of the validation messages when the
+// request accepts none of the configured ones.
+const DefaultProblemLanguage = "en"
+
+// DefaultValidationMessages are the templates of the validation messages by
+// language and validator tag. The "" tag is the message of the tags without
+// template.
+var DefaultValidationMessages = map[string]map[string]string{
+	"en": {
+		"":         "{{.Field}} is not valid",
+		"required": "{{.Field}} is required",
+		"min":      "{{.Field}} must be at least {{.Param}}",
+		"gte":      "{{.Field}} must be at least {{.Param}}",
+		"max":      "{{.Field}} must be at most {{.Param}}",
+		"lte":      "{{.Field}} must be at most {{.Param}}",
+		"gt":       "{{.Field}} must be greater than {{.Param}}",
+		"lt":       "{{.Field}} must be less than {{.Param}}",
+		"len":      "{{.Field}} must have length {{.Param}}",
+		"oneof":    "{{.Field}} must be one of: {{.Param}}",
+		"email":    "{{.Field}} must be a valid email address",
+		"url":      "{{.Field}} must be a valid URL",
+		"uri":      "{{.Field}} must be a valid URI",
+		"uuid":     "{{.Field}} must be a valid UUID",
+		"alpha":    "{{.Field}} must contain only letters",
+		"alphanum": "{{.Field}} must contain only letters and digits",
+		"numeric":  "{{.Field}} must be numeric",
+	},
+}
+
+type ProblemOptions struct {
+	// TypeBaseURI prefixes the error codes to build the problem types,
+	// without it the type is "about:blank"
+	TypeBaseURI string
+	// Messages are the templates of the validation messages by language and
+	// validator tag, overriding DefaultValidationMessages. The templates are
+	// executed with the ProblemFieldError.
+	Messages map[string]map[string]string
+	// Language is the language of the messages when the request accepts none,
+	// defaults to DefaultProblemLanguage
+	Language string
+}
+
+// Problem is the RFC 9457 problem details of the error responses, extended
+// with the error code and the invalid fields.
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Code     string              `json:"code,omitempty"`
+	Errors   []ProblemFieldError `json:"errors,omitempty"`
+}
+
+// ProblemFieldError describes a field failing the validation.
+type ProblemFieldError struct {
+	// Field is the path of the field, named after its json, params, query,
+	// reqHeader or req tag
+	Field string `json:"field"`
+	// Rule is the failed validator tag
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// problemFormatter formats the route errors as problem details.
+type problemFormatter struct {
+	typeBaseURI string
+	languages   []string
+	messages    map[string]map[string]*template.Template
+}
+
+func newProblemFormatter(opts ProblemOptions) (*problemFormatter, error) {
+	lang := opts.Language
+	if lang == "" {
+		lang = DefaultProblemLanguage
+	}
+	f := &problemFormatter{
+		typeBaseURI: opts.TypeBaseURI,
+		messages:    make(map[string]map[string]*template.Template),
+	}
+	for _, messages := range []map[string]map[string]string{DefaultValidationMessages, opts.Messages} {
+		for l, tags := range messages {
+			if f.messages[l] == nil {
+				f.messages[l] = make(map[string]*template.Template)
+			}
+			for tag, text := range tags {
+				tmpl, err := template.New(l + ":" + tag).Parse(text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid validation message %s of language %s: %w", tag, l, err)
+				}
+				f.messages[l][tag] = tmpl
+			}
+		}
+	}
+	// The first language is the default one of the negotiation
+	f.languages = slices.Sorted(maps.Keys(f.messages))
+	f.languages = slices.DeleteFunc(f.languages, func(l string) bool { return l == lang })
+	f.languages = append([]string{lang}, f.languages...)
+	return f, nil
+}
+
+func (f *problemFormatter) problem(ctx *fiber.Ctx, err RouteError) Problem {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(err.Status),
+		Status:   err.Status,
+		Detail:   err.Error(),
+		Instance: ctx.Path(),
+		Code:     err.Code,
+	}
+	if f.typeBaseURI != "" && err.Code != "" {
+		p.Type = strings.TrimSuffix(f.typeBaseURI, "/") + "/" + err.Code
+	}
+
+	var verrs validator.ValidationErrors
+	if errors.As(err.Err, &verrs) {
+		lang := ctx.AcceptsLanguages(f.languages...)
+		if lang == "" {
+			lang = f.languages[0]
+		}
+		p.Detail = "The request contains invalid fields"
+		for _, fe := range verrs {
+			p.Errors = append(p.Errors, f.fieldError(lang, fe))
+		}
+	}
+	return p
+}
+
+func (f *problemFormatter) fieldError(lang string, fe validator.FieldError) ProblemFieldError {
+	res := ProblemFieldError{
+		Field: fieldPath(fe.Namespace()),
+		Rule:  fe.Tag(),
+		Param: fe.Param(),
+	}
+	tmpl := f.template(lang, fe.Tag())
+	if tmpl == nil {
+		res.Message = fe.Error()
+		return res
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, res); err != nil {
+		res.Message = fe.Error()
+		return res
+	}
+	res.Message = b.String()
+	return res
+}
+
+// template returns the message template of the tag, falling back to the
+// default language and to the generic message.
+func (f *problemFormatter) template(lang string, tag string) *template.Template {
+	for _, l := range []string{lang, f.languages[0]} {
+		if tmpl, ok := f.messages[l][tag]; ok {
+			return tmpl
+		}
+	}
+	for _, l := range []string{lang, f.languages[0]} {
+		if tmpl, ok := f.messages[l][""]; ok {
+			return tmpl
+		}
+	}
+	return nil
+}
+
+// fieldPath removes the name of the validated struct from the namespace.
+func fieldPath(namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+	if !found {
+		return namespace
+	}
+	return path
+}
+
+var (
+	// defaultValidator validates the request data of the servers without Problems.
+	defaultValidator = validator.New()
+	// dataValidator names the fields after the tags their values are loaded
+	// from, for the problem field errors.
+	dataValidator = newDataValidator()
+)
+
+func newDataValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, key := range []string{"json", "params", "query", "reqHeader"} {
+			name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		if tag := f.Tag.Get("req"); tag != "" {
+			src, key, _ := getTagParts(tag)
+			if key != "" {
+				return key
+			}
+			return src
+		}
+		return f.Name
+	})
+	return v
+}
@@ -0,0 +1,117 @@
+package httplib
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type problemSignup struct {
+	Team string          `params:"team" validate:"required,alpha"`
+	Body *problemAccount `req:"body" validate:"required"`
+}
+
+type problemAccount struct {
+	Name string `json:"name" validate:"required"`
+	Age  int    `json:"age" validate:"gte=18"`
+}
+
+func TestProblems(t *testing.T) {
+	server, err := NewServer(ServerOptions{
+		DisableHealthRoutes: true,
+		Problems: &ProblemOptions{
+			TypeBaseURI: "https://errors.example.com/",
+			Messages: map[string]map[string]string{
+				"it": {
+					"required": "{{.Field}} è obbligatorio",
+				},
+			},
+		},
+		OpenAPI: &OpenAPIOptions{UI: OpenAPIUINone},
+	})
+	require.NoError(t, err)
+
+	Post[problemSignup](server, "/teams/:team/accounts", func(req DataRequest[problemSignup]) error {
+		return nil
+	})
+	server.Handle("GET", "/conflict", func(r *Route, c *fiber.Ctx) error {
+		return RouteError{Status: http.StatusConflict, Code: "account-exists", Err: errors.New("the account exists")}
+	})
+
+	do := func(req *http.Request) Problem {
+		res, err := server.app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, TypeProblemJSON, res.Header.Get(fiber.HeaderContentType))
+		var p Problem
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&p))
+		require.Equal(t, res.StatusCode, p.Status)
+		return p
+	}
+
+	t.Run("error", func(t *testing.T) {
+		p := do(httptest.NewRequest(http.MethodGet, "/conflict?token=secret", nil))
+		require.Equal(t, Problem{
+			Type:     "https://errors.example.com/account-exists",
+			Title:    "Conflict",
+			Status:   http.StatusConflict,
+			Detail:   "the account exists",
+			Instance: "/conflict",
+			Code:     "account-exists",
+		}, p)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/teams/42/accounts", strings.NewReader(`{"age":16}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		p := do(req)
+		require.Equal(t, http.StatusBadRequest, p.Status)
+		require.Equal(t, []ProblemFieldError{
+			{Field: "team", Rule: "alpha", Message: "team must contain only letters"},
+			{Field: "body.name", Rule: "required", Message: "body.name is required"},
+			{Field: "body.age", Rule: "gte", Param: "18", Message: "body.age must be at least 18"},
+		}, p.Errors)
+	})
+
+	t.Run("language", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/teams/abc/accounts", strings.NewReader(`{"age":20}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderAcceptLanguage, "it-IT, it;q=0.9, en;q=0.5")
+		p := do(req)
+		require.Len(t, p.Errors, 1)
+		require.Equal(t, "body.name è obbligatorio", p.Errors[0].Message)
+	})
+
+	t.Run("openapi", func(t *testing.T) {
+		doc := server.OpenAPI()
+		res := doc.Paths["/teams/{team}/accounts"]["post"].Responses["400"]
+		require.Equal(t, "#/components/schemas/Problem", res.Content[TypeProblemJSON].Schema.Ref)
+	})
+
+	_, err = NewServer(ServerOptions{Problems: &ProblemOptions{Messages: map[string]map[string]string{"en": {"required": "{{.Field"}}}})
+	require.Error(t, err)
+}
+
+func TestValidationWithoutProblems(t *testing.T) {
+	server, err := NewServer(ServerOptions{DisableHealthRoutes: true})
+	require.NoError(t, err)
+
+	Post[problemSignup](server, "/teams/:team/accounts", func(req DataRequest[problemSignup]) error {
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/teams/42/accounts", strings.NewReader(`{"age":20}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	res, err := server.app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "Field validation for 'Team' failed", "the fields keep their Go names")
+}
@@ -0,0 +1,161 @@
+package httplib
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sandrolain/gomsvc/pkg/datalib"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrNotAcceptable = errors.New("no acceptable response content type")
+
+// ResponseTypes are the content types negotiated by default, in order of
+// preference. Protobuf is offered only when the response is a proto.Message.
+// XML, which cannot encode every response, is offered only when listed in
+// the ResponseOptions Types.
+var ResponseTypes = []string{
+	datalib.TypeJson,
+	datalib.TypeMsgpack,
+	datalib.TypeXMsgpack,
+	datalib.TypeProtobuf,
+	datalib.TypeCBOR,
+}
+
+type ResponseOptions struct {
+	// Status of the successful responses, defaults to 200, or 204 when the
+	// response is EmptyData. The handler can change it with req.Ctx.Status.
+	Status int
+	// Headers are set on the responses before calling the handler.
+	Headers map[string]string
+	// Types are the content types offered to the negotiation, in order of
+	// preference, defaulting to ResponseTypes.
+	Types []string
+}
+
+// DataResponder handles a request returning the response, encoded in the
+// content type negotiated from the Accept header.
+type DataResponder[T any, R any] func(req DataRequest[T]) (R, error)
+
+// DataHandlerWithResponse is as DataHandler, writing the response returned
+// by handler. The requests accepting none of the offered types fail with
+// status 406 before calling handler.
+func DataHandlerWithResponse[T any, R any](handler DataResponder[T, R], opts ...ResponseOptions) Handler {
+	o := responseOptions[R](opts)
+	empty := reflect.TypeFor[R]() == reflect.TypeFor[EmptyData]()
+	load := newDataLoader[T]()
+
+	return func(r *Route, c *fiber.Ctx) error {
+		req, err := dataRequest(r, c, load)
+		if err != nil {
+			return err
+		}
+
+		var typ string
+		if !empty {
+			if typ = c.Accepts(o.Types...); typ == "" {
+				return NotAcceptableError(ErrNotAcceptable)
+			}
+		}
+
+		c.Status(o.Status)
+		for k, v := range o.Headers {
+			c.Set(k, v)
+		}
+		err = runDataMiddlewares(r, req, func() error {
+			res, err := handler(req)
+			if err != nil {
+				return routeError(err)
+			}
+			if empty {
+				return nil
+			}
+			return writeResponse(c, typ, res)
+		})
+		if err != nil {
+			return routeError(err)
+		}
+		return nil
+	}
+}
+
+func responseOptions[R any](opts []ResponseOptions) ResponseOptions {
+	var o ResponseOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Status == 0 {
+		o.Status = http.StatusOK
+		if reflect.TypeFor[R]() == reflect.TypeFor[EmptyData]() {
+			o.Status = http.StatusNoContent
+		}
+	}
+	if len(o.Types) == 0 {
+		o.Types = ResponseTypes
+	}
+	if !isProtoMessage(reflect.TypeFor[R]()) {
+		o.Types = slices.DeleteFunc(slices.Clone(o.Types), func(t string) bool {
+			return t == datalib.TypeProtobuf
+		})
+	}
+	return o
+}
+
+func isProtoMessage(t reflect.Type) bool {
+	m := reflect.TypeFor[proto.Message]()
+	return t.Implements(m) || reflect.PointerTo(t).Implements(m)
+}
+
+func writeResponse[R any](c *fiber.Ctx, typ string, res R) error {
+	if typ == datalib.TypeJson {
+		return c.JSON(res)
+	}
+	data, err := datalib.MarshalBody(typ, &res)
+	if err != nil {
+		return InternalServerError(err)
+	}
+	c.Set(fiber.HeaderContentType, typ)
+	return c.Send(data)
+}
+
+// handleDataWithResponse registers the typed route, recording its request
+// and response types for the OpenAPI document.
+func handleDataWithResponse[T any, R any](server Routable, method string, path string, handler DataResponder[T, R], opts []ResponseOptions) *Route {
+	o := responseOptions[R](opts)
+	r := server.Handle(method, path, DataHandlerWithResponse(handler, o))
+	r.reqType = reflect.TypeFor[T]()
+	res := routeResponse{status: o.Status, types: o.Types}
+	if t := reflect.TypeFor[R](); t != reflect.TypeFor[EmptyData]() {
+		res.typ = t
+	}
+	r.responses = append(r.responses, res)
+	return r
+}
+
+func HandleWithResponse[T any, R any](server Routable, methodPath string, handler DataResponder[T, R], opts ...ResponseOptions) *Route {
+	method, path := parsePath(methodPath)
+	return handleDataWithResponse(server, method, path, handler, opts)
+}
+
+func GetWithResponse[T any, R any](server Routable, path string, handler DataResponder[T, R], opts ...ResponseOptions) *Route {
+	return handleDataWithResponse(server, "GET", path, handler, opts)
+}
+
+func PostWithResponse[T any, R any](server Routable, path string, handler DataResponder[T, R], opts ...ResponseOptions) *Route {
+	return handleDataWithResponse(server, "POST", path, handler, opts)
+}
+
+func PutWithResponse[T any, R any](server Routable, path string, handler DataResponder[T, R], opts ...ResponseOptions) *Route {
+	return handleDataWithResponse(server, "PUT", path, handler, opts)
+}
+
+func PatchWithResponse[T any, R any](server Routable, path string, handler DataResponder[T, R], opts ...ResponseOptions) *Route {
+	return handleDataWithResponse(server, "PATCH", path, handler, opts)
+}
+
+func DeleteWithResponse[T any, R any](server Routable, path string, handler DataResponder[T, R], opts ...ResponseOptions) *Route {
+	return handleDataWithResponse(server, "DELETE", path, handler, opts)
+}
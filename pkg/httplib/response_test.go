@@ -0,0 +1,135 @@
+package httplib
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sandrolain/gomsvc/pkg/datalib"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type responseGreet struct {
+	Name string `params:"name" validate:"required"`
+}
+
+type responseGreeting struct {
+	XMLName xml.Name `json:"-" xml:"greeting" msgpack:"-" cbor:"-"`
+	Message string   `json:"message" xml:"message" msgpack:"message" cbor:"message"`
+}
+
+func TestDataHandlerWithResponse(t *testing.T) {
+	server, err := NewServer(ServerOptions{
+		DisableHealthRoutes: true,
+		OpenAPI:             &OpenAPIOptions{UI: OpenAPIUINone},
+	})
+	require.NoError(t, err)
+
+	GetWithResponse(server, "/greet/:name", func(req DataRequest[responseGreet]) (responseGreeting, error) {
+		if req.Data.Name == "nobody" {
+			return responseGreeting{}, BadRequestError(errors.New("unknown"))
+		}
+		return responseGreeting{Message: "hello " + req.Data.Name}, nil
+	}, ResponseOptions{Headers: map[string]string{"Cache-Control": "no-store"}})
+	PostWithResponse(server, "/created", func(req DataRequest[EmptyData]) (responseGreeting, error) {
+		return responseGreeting{Message: "created"}, nil
+	}, ResponseOptions{Status: http.StatusCreated})
+	DeleteWithResponse(server, "/greet/:name", func(req DataRequest[responseGreet]) (EmptyData, error) {
+		return EmptyData{}, nil
+	})
+	GetWithResponse(server, "/xml/:name", func(req DataRequest[responseGreet]) (responseGreeting, error) {
+		return responseGreeting{Message: "hello " + req.Data.Name}, nil
+	}, ResponseOptions{Types: []string{datalib.TypeJson, datalib.TypeXML, datalib.TypeTextXML}})
+	GetWithResponse(server, "/proto", func(req DataRequest[EmptyData]) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String("hello"), nil
+	})
+
+	do := func(method, path, accept string) (*http.Response, []byte) {
+		req := httptest.NewRequest(method, path, nil)
+		if accept != "" {
+			req.Header.Set(fiber.HeaderAccept, accept)
+		}
+		res, err := server.app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+
+	t.Run("json by default", func(t *testing.T) {
+		res, body := do(http.MethodGet, "/greet/bob", "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.True(t, strings.HasPrefix(res.Header.Get(fiber.HeaderContentType), datalib.TypeJson))
+		require.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+		var greeting responseGreeting
+		require.NoError(t, json.Unmarshal(body, &greeting))
+		require.Equal(t, "hello bob", greeting.Message)
+	})
+
+	for path, types := range map[string][]string{
+		"/greet/bob": {datalib.TypeMsgpack, datalib.TypeCBOR},
+		"/xml/bob":   {datalib.TypeXML, datalib.TypeTextXML},
+	} {
+		for _, typ := range types {
+			t.Run(typ, func(t *testing.T) {
+				res, body := do(http.MethodGet, path, typ+";q=0.9, application/json;q=0.5")
+				require.Equal(t, http.StatusOK, res.StatusCode)
+				require.Equal(t, typ, res.Header.Get(fiber.HeaderContentType))
+				greeting, err := datalib.UnmarshalBody[responseGreeting](typ, body)
+				require.NoError(t, err)
+				require.Equal(t, "hello bob", greeting.Message)
+			})
+		}
+	}
+
+	t.Run("protobuf", func(t *testing.T) {
+		res, body := do(http.MethodGet, "/proto", datalib.TypeProtobuf)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, datalib.TypeProtobuf, res.Header.Get(fiber.HeaderContentType))
+		var value wrapperspb.StringValue
+		require.NoError(t, proto.Unmarshal(body, &value))
+		require.Equal(t, "hello", value.Value)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		res, _ := do(http.MethodGet, "/greet/bob", "text/html")
+		require.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+		res, _ = do(http.MethodGet, "/greet/bob", datalib.TypeProtobuf)
+		require.Equal(t, http.StatusNotAcceptable, res.StatusCode, "the response is not a protobuf message")
+		res, _ = do(http.MethodGet, "/greet/bob", datalib.TypeXML)
+		require.Equal(t, http.StatusNotAcceptable, res.StatusCode, "xml is opt-in")
+	})
+
+	t.Run("status", func(t *testing.T) {
+		res, _ := do(http.MethodPost, "/created", "")
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		res, body := do(http.MethodDelete, "/greet/bob", "text/html")
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		require.Empty(t, body)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		res, body := do(http.MethodGet, "/greet/nobody", datalib.TypeCBOR)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Contains(t, string(body), "unknown")
+	})
+
+	t.Run("openapi", func(t *testing.T) {
+		doc := server.OpenAPI()
+		greet := doc.Paths["/greet/{name}"]["get"]
+		require.NotNil(t, greet)
+		require.Contains(t, greet.Responses["200"].Content, datalib.TypeCBOR)
+		require.NotContains(t, greet.Responses["200"].Content, datalib.TypeProtobuf)
+		require.Contains(t, doc.Paths["/proto"]["get"].Responses["200"].Content, datalib.TypeProtobuf)
+		require.Contains(t, doc.Paths["/created"]["post"].Responses, "201")
+		require.Nil(t, doc.Paths["/greet/{name}"]["delete"].Responses["204"].Content)
+	})
+}
@@ -1,6 +1,11 @@
 package httplib
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"reflect"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 type Route struct {
 	server            *Server
@@ -11,6 +16,12 @@ type Route struct {
 	validationFunc    ValidationFunc
 	authorizationFunc AuthorizationFunc
 	noValidateData    bool
+	reqType           reflect.Type
+	doc               RouteDoc
+	responses         []routeResponse
+	middlewares       []Middleware
+	dataMiddlewares   []any
+	wrapped           atomic.Pointer[wrappedHandler]
 }
 
 // Valid allow to define the validation function
@@ -34,9 +45,11 @@ func (s *Route) Handle(methodPath string, handler Handler) *Route {
 		path:        path,
 	}
 	router := (*s.Router).Add(r.method, r.path, func(ctx *fiber.Ctx) error {
-		return handler(r, ctx)
+		return r.wrapCached(handler)(r, ctx)
 	})
 	r.Router = &router
+	s.server.addRoute(r)
+	s.server.addPreflightRoute(*s.Router, r)
 	return r
 }
 
@@ -1,16 +1,22 @@
 package httplib
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
 	slogfiber "github.com/samber/slog-fiber"
 	"github.com/sandrolain/gomsvc/pkg/certlib"
+	"github.com/sandrolain/gomsvc/pkg/svc"
 )
 
 type ServerOptions struct {
@@ -19,6 +25,19 @@ type ServerOptions struct {
 	AuthorizationFunc AuthorizationFunc
 	ErrorFilterFunc   ErrorFilterFunc
 	TLSConfig         *certlib.ServerTLSConfigFiles `validate:"omitempty"`
+	// DisableHealthRoutes disables the health, liveness and readiness routes
+	DisableHealthRoutes bool
+	// EnableMetricsRoute serves the Prometheus metrics on the server, without
+	// authentication. The METRICS_ADDR dedicated listener is to be preferred.
+	EnableMetricsRoute bool
+	// MetricsPath is the path of the metrics route, defaults to svc.DefaultMetricsPath
+	MetricsPath string
+	// Container, when set, serves each request within one of its request scopes
+	Container *svc.Container
+	// OpenAPI, when set, serves the OpenAPI document of the routes and its UI
+	OpenAPI *OpenAPIOptions
+	// Problems, when set, formats the error responses as RFC 9457 problem details
+	Problems *ProblemOptions
 }
 
 type Server struct {
@@ -28,6 +47,19 @@ type Server struct {
 	authorizationFunc AuthorizationFunc
 	sessionStore      *session.Store
 	tlsConfig         *tls.Config
+	openAPI           *OpenAPIOptions
+	problems          *problemFormatter
+	routesMu          sync.Mutex
+	routes            []*Route
+	// preflights are the routes by path of the OPTIONS routes answering
+	// the preflight requests
+	preflights map[string][]*Route
+	// middlewaresMu guards the middlewares of the server and of its routes,
+	// middlewaresGen counts their changes to invalidate the wrapped handlers
+	middlewaresMu   sync.RWMutex
+	middlewaresGen  atomic.Uint64
+	middlewares     []Middleware
+	dataMiddlewares []any
 }
 
 func NewServer(opts ServerOptions) (res *Server, err error) {
@@ -37,6 +69,11 @@ func NewServer(opts ServerOptions) (res *Server, err error) {
 		authorizationFunc: opts.AuthorizationFunc,
 		errorFilter:       opts.ErrorFilterFunc,
 	}
+	if opts.Problems != nil {
+		if res.problems, err = newProblemFormatter(*opts.Problems); err != nil {
+			return
+		}
+	}
 	res.app = fiber.New(fiber.Config{
 		ErrorHandler: getFiberErrorHandler(res),
 		JSONEncoder:  sonic.Marshal,
@@ -56,8 +93,28 @@ func NewServer(opts ServerOptions) (res *Server, err error) {
 	if logger == nil {
 		logger = slog.Default()
 	}
+	res.app.Use(telemetryMiddleware())
+	res.app.Use(logContextMiddleware())
+	if opts.Container != nil {
+		res.app.Use(scopeMiddleware(opts.Container))
+	}
+	res.app.Use(metricsMiddleware())
 	res.app.Use(slogfiber.New(logger))
 
+	if !opts.DisableHealthRoutes {
+		res.registerHealthRoutes()
+	}
+
+	if opts.EnableMetricsRoute {
+		res.registerMetricsRoute(opts.MetricsPath)
+	}
+
+	if opts.OpenAPI != nil {
+		if err = res.registerOpenAPIRoutes(*opts.OpenAPI); err != nil {
+			return
+		}
+	}
+
 	return
 }
 
@@ -98,6 +155,8 @@ func getFiberErrorHandler(s *Server) func(ctx *fiber.Ctx, err error) error {
 		var sendErr error
 		if len(routeErr.Body) > 0 {
 			sendErr = ctx.Send(routeErr.Body)
+		} else if s.problems != nil {
+			sendErr = ctx.JSON(s.problems.problem(ctx, routeErr), TypeProblemJSON)
 		} else {
 			sendErr = ctx.JSON(formatStandardResponseError(routeErr))
 		}
@@ -142,12 +201,70 @@ func (s *Server) Handle(method string, path string, handler Handler) *Route {
 		path:   path,
 	}
 	router := s.app.Add(r.method, r.path, func(ctx *fiber.Ctx) error {
-		return handler(r, ctx)
+		return r.wrapCached(handler)(r, ctx)
 	})
 	r.Router = &router
+	s.addRoute(r)
+	s.addPreflightRoute(s.app, r)
 	return r
 }
 
+func (s *Server) addRoute(r *Route) {
+	s.routesMu.Lock()
+	s.routes = append(s.routes, r)
+	s.routesMu.Unlock()
+}
+
+// addPreflightRoute registers in router the OPTIONS route of the path of r,
+// once by path. It runs the middlewares of the route of the method
+// requested by the preflight, so that the middlewares as CORS answer it.
+// The other requests are passed to the next OPTIONS routes, and are
+// answered with the allowed methods when there are none.
+func (s *Server) addPreflightRoute(router fiber.Router, r *Route) {
+	if r.method == "" || r.method == fiber.MethodOptions {
+		return
+	}
+	path := r.fullPath()
+	s.routesMu.Lock()
+	if s.preflights == nil {
+		s.preflights = make(map[string][]*Route)
+	}
+	_, exists := s.preflights[path]
+	s.preflights[path] = append(s.preflights[path], r)
+	s.routesMu.Unlock()
+	if exists {
+		return
+	}
+
+	router.Add(fiber.MethodOptions, r.path, func(c *fiber.Ctx) error {
+		s.routesMu.Lock()
+		routes := s.preflights[path]
+		s.routesMu.Unlock()
+		route := routes[0]
+		for _, v := range routes {
+			if v.method == c.Get(fiber.HeaderAccessControlRequestMethod) {
+				route = v
+				break
+			}
+		}
+		methods := make([]string, 0, len(routes)+1)
+		for _, v := range routes {
+			methods = append(methods, v.method)
+		}
+		methods = append(methods, fiber.MethodOptions)
+
+		return route.wrap(func(_ *Route, c *fiber.Ctx) error {
+			err := c.Next()
+			var fe *fiber.Error
+			if errors.As(err, &fe) && (fe.Code == fiber.StatusNotFound || fe.Code == fiber.StatusMethodNotAllowed) {
+				c.Set(fiber.HeaderAllow, strings.Join(methods, ", "))
+				return c.SendStatus(fiber.StatusNoContent)
+			}
+			return err
+		})(route, c)
+	})
+}
+
 func (s *Server) Route(path string, handler ...func(*Route)) (res *Route) {
 	router := s.app.Group(path)
 	return &Route{
@@ -171,3 +288,30 @@ func (s *Server) Listen(addr string, tlsConfig ...certlib.ServerTLSConfigFiles)
 	err = s.app.Listener(ln)
 	return
 }
+
+// Component returns the svc lifecycle component listening on addr.
+// The listener is opened on start, so that binding errors abort the service startup.
+func (s *Server) Component(name string, addr string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fmt.Errorf("failed to listen: %w", err)
+			}
+			if s.tlsConfig != nil {
+				ln = tls.NewListener(ln, s.tlsConfig)
+			}
+			go func() {
+				if err := s.app.Listener(ln); err != nil {
+					_ = svc.Error("HTTP server stopped", err, "addr", addr)
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return s.app.ShutdownWithContext(ctx)
+		},
+	}
+}
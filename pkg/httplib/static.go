@@ -1,38 +1,47 @@
 package httplib
 
+import "reflect"
+
 type Routable interface {
 	Handle(method string, path string, handler Handler) *Route
 }
 
 func Handle[T any](server Routable, methodPath string, handler DataReceiver[T]) *Route {
 	method, path := parsePath(methodPath)
-	return server.Handle(method, path, DataHandler[T](handler))
+	return handleData(server, method, path, handler)
 }
 
 func Get[T any](server Routable, path string, handler DataReceiver[T]) *Route {
-	return server.Handle("GET", path, DataHandler[T](handler))
+	return handleData(server, "GET", path, handler)
 }
 
 func Post[T any](server Routable, path string, handler DataReceiver[T]) *Route {
-	return server.Handle("POST", path, DataHandler[T](handler))
+	return handleData(server, "POST", path, handler)
 }
 
 func Put[T any](server Routable, path string, handler DataReceiver[T]) *Route {
-	return server.Handle("PUT", path, DataHandler[T](handler))
+	return handleData(server, "PUT", path, handler)
 }
 
 func Patch[T any](server Routable, path string, handler DataReceiver[T]) *Route {
-	return server.Handle("PATCH", path, DataHandler[T](handler))
+	return handleData(server, "PATCH", path, handler)
 }
 
 func Head[T any](server Routable, path string, handler DataReceiver[T]) *Route {
-	return server.Handle("HEAD", path, DataHandler[T](handler))
+	return handleData(server, "HEAD", path, handler)
 }
 
 func Options[T any](server Routable, path string, handler DataReceiver[T]) *Route {
-	return server.Handle("OPTIONS", path, DataHandler[T](handler))
+	return handleData(server, "OPTIONS", path, handler)
 }
 
 func Delete[T any](server Routable, path string, handler DataReceiver[T]) *Route {
-	return server.Handle("DELETE", path, DataHandler[T](handler))
+	return handleData(server, "DELETE", path, handler)
+}
+
+// handleData registers the typed route, recording its request type for the OpenAPI document.
+func handleData[T any](server Routable, method string, path string, handler DataReceiver[T]) *Route {
+	r := server.Handle(method, path, DataHandler[T](handler))
+	r.reqType = reflect.TypeFor[T]()
+	return r
 }
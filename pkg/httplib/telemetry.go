@@ -0,0 +1,103 @@
+package httplib
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/sandrolain/gomsvc/pkg/httplib"
+
+// headerCarrier adapts the fasthttp request headers to the OpenTelemetry propagators.
+type headerCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	c.header.Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := []string{}
+	c.header.VisitAll(func(key, value []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// telemetryMiddleware starts a server span for each request, continuing the
+// trace propagated by the client, and records the request duration.
+func telemetryMiddleware() fiber.Handler {
+	tracer := otel.Tracer(instrumentationName)
+	duration, _ := otel.Meter(instrumentationName).Float64Histogram(
+		"http.server.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of HTTP server requests."),
+	)
+
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{&c.Request().Header})
+
+		ctx, span := tracer.Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLPath(c.Path()),
+				semconv.URLScheme(c.Protocol()),
+				semconv.ServerAddress(c.Hostname()),
+				semconv.ClientAddress(c.IP()),
+			),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		if err != nil {
+			span.RecordError(err)
+		}
+
+		route := c.Route().Path
+		attrs := []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(c.Method()),
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(status),
+		}
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(attrs[1:]...)
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+		duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+
+		return err
+	}
+}
+
+// responseStatus returns the status code of the response, resolving the one
+// that the error handler will send for err.
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	if fe, ok := err.(*fiber.Error); ok {
+		return fe.Code
+	}
+	if re, ok := err.(RouteError); ok {
+		return re.Status
+	}
+	return fiber.StatusInternalServerError
+}
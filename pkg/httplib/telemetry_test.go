@@ -0,0 +1,61 @@
+package httplib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTelemetryMiddleware(t *testing.T) {
+	tt := svc.InitTestTelemetry()
+	defer tt.Shutdown()
+
+	server, err := NewServer(ServerOptions{
+		DisableHealthRoutes: true,
+	})
+	require.NoError(t, err)
+	Get[middlewareItem](server, "/items/:id", func(req DataRequest[middlewareItem]) error {
+		if req.Data.ID == "broken" {
+			return InternalServerError(errors.New("broken item"))
+		}
+		return req.JSON(req.Data)
+	})
+
+	// The trace of the client is continued by the server span
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "client")
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	parent.End()
+	res, err := server.app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = server.app.Test(httptest.NewRequest(http.MethodGet, "/items/broken", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+	spans := tt.Spans.GetSpans()
+	require.Len(t, spans, 3)
+	ok, broken := spans[1], spans[2]
+
+	require.Equal(t, "GET /items/:id", ok.Name)
+	require.Equal(t, trace.SpanKindServer, ok.SpanKind)
+	require.Equal(t, parent.SpanContext().TraceID(), ok.SpanContext.TraceID())
+	require.Equal(t, parent.SpanContext().SpanID(), ok.Parent.SpanID())
+	require.Contains(t, ok.Attributes, semconv.HTTPRoute("/items/:id"))
+	require.Contains(t, ok.Attributes, semconv.HTTPResponseStatusCode(http.StatusOK))
+
+	require.NotEqual(t, parent.SpanContext().TraceID(), broken.SpanContext.TraceID())
+	require.Contains(t, broken.Attributes, semconv.HTTPResponseStatusCode(http.StatusInternalServerError))
+	require.Equal(t, codes.Error, broken.Status.Code)
+}
@@ -0,0 +1,153 @@
+// Package inboxlib makes the message consumers idempotent: each message is
+// claimed by its ID before being handled, so the redelivered messages
+// already processed are skipped, and the outcome of the processing is
+// recorded for a retention period.
+//
+// The stores are provided by redislib and dblib, the handler adapters by
+// redislib, mqttlib, mqttwatermill and gcplib.
+package inboxlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+const (
+	DefaultProcessingTimeout = 5 * time.Minute
+	DefaultRetention         = 72 * time.Hour
+)
+
+var (
+	// ErrEmptyID is returned when the ID of a message is empty.
+	ErrEmptyID = errors.New("inbox message id is empty")
+	// ErrInProgress is returned when the message is being processed by
+	// another consumer, to be redelivered later.
+	ErrInProgress = errors.New("inbox message is being processed")
+)
+
+// Status is the processing status of a message.
+type Status string
+
+const (
+	StatusProcessing Status = "processing"
+	StatusDone       Status = "done"
+	StatusFailed     Status = "failed"
+)
+
+// Record is the processing outcome of a message.
+type Record struct {
+	ID        string    `json:"id"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Store keeps the processing records of the messages of each consumer.
+type Store interface {
+	// Claim marks the message as processing, returning an empty status.
+	// If the message is already done or being processed, it returns its
+	// status instead. The failed messages, and those processing for longer
+	// than timeout, can be claimed again.
+	Claim(ctx context.Context, consumer string, id string, timeout time.Duration) (Status, error)
+	// Record stores the outcome of the processing, kept for retention.
+	Record(ctx context.Context, consumer string, rec Record, retention time.Duration) error
+}
+
+type Options struct {
+	// Consumer scopes the message IDs, as the consumer group of a stream.
+	Consumer string
+	// ProcessingTimeout is the time after which a message claimed by a
+	// consumer that did not record its outcome can be claimed again.
+	ProcessingTimeout time.Duration
+	// Retention is how long the processed messages are remembered.
+	Retention time.Duration
+}
+
+var messagesTotal = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "inbox_messages_total",
+	Help: "Total number of messages seen by the inbox, by outcome.",
+}, []string{"consumer", "outcome"}))
+
+// Inbox deduplicates the messages of a consumer.
+type Inbox struct {
+	store Store
+	opts  Options
+}
+
+// New creates the inbox of a consumer on store.
+func New(store Store, opts Options) *Inbox {
+	if opts.ProcessingTimeout <= 0 {
+		opts.ProcessingTimeout = DefaultProcessingTimeout
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	return &Inbox{store: store, opts: opts}
+}
+
+// Do runs fn for the message with the given ID unless it was already
+// processed, recording its outcome. The duplicates are skipped returning nil,
+// while the messages being processed by another consumer return ErrInProgress.
+func (in *Inbox) Do(ctx context.Context, id string, fn func(ctx context.Context) error) (err error) {
+	if id == "" {
+		return ErrEmptyID
+	}
+
+	status, err := in.store.Claim(ctx, in.opts.Consumer, id, in.opts.ProcessingTimeout)
+	if err != nil {
+		return fmt.Errorf("cannot claim inbox message: %w", err)
+	}
+	switch status {
+	case "":
+	case StatusDone:
+		messagesTotal.WithLabelValues(in.opts.Consumer, "duplicate").Inc()
+		svc.Logger().DebugContext(ctx, "Skipped duplicate message", "consumer", in.opts.Consumer, "id", id)
+		return nil
+	default:
+		messagesTotal.WithLabelValues(in.opts.Consumer, "in_progress").Inc()
+		return ErrInProgress
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			in.record(ctx, id, fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
+
+	err = fn(ctx)
+	in.record(ctx, id, err)
+	return err
+}
+
+func (in *Inbox) record(ctx context.Context, id string, cause error) {
+	rec := Record{ID: id, Status: StatusDone, UpdatedAt: time.Now()}
+	outcome := "processed"
+	if cause != nil {
+		rec.Status = StatusFailed
+		rec.Error = cause.Error()
+		outcome = "failed"
+	}
+	messagesTotal.WithLabelValues(in.opts.Consumer, outcome).Inc()
+
+	// The outcome is recorded also when the handler context is done
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	if err := in.store.Record(ctx, in.opts.Consumer, rec, in.opts.Retention); err != nil {
+		svc.Logger().ErrorContext(ctx, "Cannot record inbox message", "consumer", in.opts.Consumer, "id", id, "error", err)
+	}
+}
+
+// Handler wraps handler to skip the messages already processed, identified by id.
+func Handler[M any](in *Inbox, id func(msg M) string, handler func(ctx context.Context, msg M) error) func(ctx context.Context, msg M) error {
+	return func(ctx context.Context, msg M) error {
+		return in.Do(ctx, id(msg), func(ctx context.Context) error {
+			return handler(ctx, msg)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package inboxlib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRecord struct {
+	Record
+	expires time.Time
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+}
+
+func (s *memoryStore) Claim(ctx context.Context, consumer string, id string, timeout time.Duration) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := consumer + ":" + id
+	if rec, ok := s.records[key]; ok && rec.Status != StatusFailed && time.Now().Before(rec.expires) {
+		return rec.Status, nil
+	}
+	s.records[key] = memoryRecord{Record{ID: id, Status: StatusProcessing}, time.Now().Add(timeout)}
+	return "", nil
+}
+
+func (s *memoryStore) Record(ctx context.Context, consumer string, rec Record, retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[consumer+":"+rec.ID] = memoryRecord{rec, rec.UpdatedAt.Add(retention)}
+	return nil
+}
+
+func (s *memoryStore) get(consumer string, id string) Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[consumer+":"+id].Record
+}
+
+func TestInboxSkipsDuplicates(t *testing.T) {
+	store := &memoryStore{records: map[string]memoryRecord{}}
+	in := New(store, Options{Consumer: "orders"})
+
+	calls := 0
+	handler := Handler(in, func(msg string) string { return msg }, func(ctx context.Context, msg string) error {
+		calls++
+		return nil
+	})
+
+	require.NoError(t, handler(context.Background(), "a"))
+	require.NoError(t, handler(context.Background(), "a"))
+	require.NoError(t, handler(context.Background(), "b"))
+	require.Equal(t, 2, calls)
+	require.Equal(t, StatusDone, store.get("orders", "a").Status)
+
+	other := New(store, Options{Consumer: "billing"})
+	require.NoError(t, other.Do(context.Background(), "a", func(ctx context.Context) error {
+		calls++
+		return nil
+	}))
+	require.Equal(t, 3, calls, "the IDs are scoped by consumer")
+
+	require.ErrorIs(t, in.Do(context.Background(), "", func(ctx context.Context) error { return nil }), ErrEmptyID)
+}
+
+func TestInboxRetriesFailures(t *testing.T) {
+	store := &memoryStore{records: map[string]memoryRecord{}}
+	in := New(store, Options{Consumer: "orders"})
+
+	err := in.Do(context.Background(), "a", func(ctx context.Context) error { return errors.New("failed") })
+	require.EqualError(t, err, "failed")
+	rec := store.get("orders", "a")
+	require.Equal(t, StatusFailed, rec.Status)
+	require.Equal(t, "failed", rec.Error)
+
+	require.Panics(t, func() {
+		_ = in.Do(context.Background(), "a", func(ctx context.Context) error { panic("boom") })
+	})
+	require.Equal(t, "panic: boom", store.get("orders", "a").Error)
+
+	called := false
+	require.NoError(t, in.Do(context.Background(), "a", func(ctx context.Context) error {
+		called = true
+		return nil
+	}))
+	require.True(t, called)
+	require.Equal(t, StatusDone, store.get("orders", "a").Status)
+}
+
+func TestInboxProcessingTimeout(t *testing.T) {
+	store := &memoryStore{records: map[string]memoryRecord{}}
+	in := New(store, Options{Consumer: "orders", ProcessingTimeout: 20 * time.Millisecond})
+
+	status, err := store.Claim(context.Background(), "orders", "a", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.Empty(t, status)
+
+	called := false
+	fn := func(ctx context.Context) error {
+		called = true
+		return nil
+	}
+	require.ErrorIs(t, in.Do(context.Background(), "a", fn), ErrInProgress, "the message is being processed")
+	require.False(t, called)
+
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, in.Do(context.Background(), "a", fn))
+	require.True(t, called, "the stale claim is taken over")
+}
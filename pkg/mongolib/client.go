@@ -9,6 +9,7 @@ import (
 	"github.com/sandrolain/gomsvc/pkg/svc"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 const DefaultTimeout = 2000 * time.Millisecond
@@ -62,5 +63,23 @@ func Connect(config ClientOptions) (conn *Connection, err error) {
 		timeout:     timeout,
 		collections: map[string]*mongo.Collection{},
 	}
+	// keyed by database, so that several connections keep their own check
+	svc.AddHealthCheck("mongodb:"+config.Database, conn.HealthCheck)
 	return
 }
+
+// HealthCheck pings the primary of the MongoDB deployment
+func (c *Connection) HealthCheck(ctx context.Context) error {
+	return c.Client.Ping(ctx, readpref.Primary())
+}
+
+// Component returns the svc lifecycle component disconnecting the client on stop.
+func (c *Connection) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Stop: func(ctx context.Context) error {
+			return c.Client.Disconnect(ctx)
+		},
+	}
+}
@@ -0,0 +1,185 @@
+package mongolib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/outboxlib"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultOutboxCollection = "outbox"
+
+type outboxDocument struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Topic       string             `bson:"topic"`
+	Key         string             `bson:"key,omitempty"`
+	Payload     []byte             `bson:"payload"`
+	Headers     map[string]string  `bson:"headers,omitempty"`
+	Attempts    int                `bson:"attempts"`
+	LastError   string             `bson:"lastError,omitempty"`
+	FailedAt    time.Time          `bson:"failedAt,omitempty"`
+	RetryAt     time.Time          `bson:"retryAt,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt"`
+	DeliveredAt *time.Time         `bson:"deliveredAt"`
+	DeadAt      *time.Time         `bson:"deadAt"`
+}
+
+var _ outboxlib.Store = (*Outbox)(nil)
+
+// Outbox is the outbox store on a collection. The messages are ordered by
+// their ObjectID, generated when they are added.
+type Outbox struct {
+	coll *mongo.Collection
+}
+
+// NewOutbox returns the outbox store on the collection, DefaultOutboxCollection if empty.
+func NewOutbox(conn *Connection, collection string) *Outbox {
+	if collection == "" {
+		collection = DefaultOutboxCollection
+	}
+	return &Outbox{coll: conn.DB.Collection(collection)}
+}
+
+// OutboxCollection returns the definition of the outbox collection for DefineCollections.
+func OutboxCollection(name string) CollectionDef {
+	if name == "" {
+		name = DefaultOutboxCollection
+	}
+	return CollectionDef{
+		Name: name,
+		Indexes: []IndexDef{
+			{Fields: bson.D{{Key: "deliveredAt", Value: 1}, {Key: "deadAt", Value: 1}, {Key: "_id", Value: 1}}},
+			{Fields: bson.D{{Key: "retryAt", Value: 1}, {Key: "key", Value: 1}}},
+		},
+	}
+}
+
+// Add writes the messages in the outbox. To be atomic with the other
+// writes, ctx must be the mongo.SessionContext of their transaction.
+func (o *Outbox) Add(ctx context.Context, msgs ...outboxlib.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	docs := make([]any, len(msgs))
+	for i, msg := range msgs {
+		docs[i] = outboxDocument{
+			ID:        primitive.NewObjectID(),
+			Topic:     msg.Topic,
+			Key:       msg.Key,
+			Payload:   msg.Payload,
+			Headers:   msg.Headers,
+			CreatedAt: msg.CreatedAt,
+		}
+	}
+	_, err := o.coll.InsertMany(ctx, docs)
+	return err
+}
+
+func (o *Outbox) Pending(ctx context.Context, limit int) ([]outboxlib.Message, error) {
+	now := time.Now()
+	// The keys of the messages waiting for their retry are held back
+	blocked, err := o.coll.Distinct(ctx, "key", bson.M{
+		"deliveredAt": nil,
+		"deadAt":      nil,
+		"retryAt":     bson.M{"$gt": now},
+		"key":         bson.M{"$nin": bson.A{"", nil}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{
+		"deliveredAt": nil,
+		"deadAt":      nil,
+		"retryAt":     bson.M{"$not": bson.M{"$gt": now}},
+	}
+	if len(blocked) > 0 {
+		filter["key"] = bson.M{"$nin": blocked}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cur, err := o.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []outboxDocument
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	msgs := make([]outboxlib.Message, len(docs))
+	for i, d := range docs {
+		msgs[i] = outboxlib.Message{
+			ID:        d.ID.Hex(),
+			Topic:     d.Topic,
+			Key:       d.Key,
+			Payload:   d.Payload,
+			Headers:   d.Headers,
+			Attempts:  d.Attempts,
+			LastError: d.LastError,
+			FailedAt:  d.FailedAt,
+			RetryAt:   d.RetryAt,
+			CreatedAt: d.CreatedAt,
+		}
+	}
+	return msgs, nil
+}
+
+func (o *Outbox) MarkDelivered(ctx context.Context, ids []string) error {
+	oids := make([]primitive.ObjectID, len(ids))
+	for i, id := range ids {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return fmt.Errorf("invalid outbox message id: %w", err)
+		}
+		oids[i] = oid
+	}
+	_, err := o.coll.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": oids}},
+		bson.M{"$set": bson.M{"deliveredAt": time.Now()}},
+	)
+	return err
+}
+
+func (o *Outbox) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	return o.markFailed(ctx, id, cause, retryAt, false)
+}
+
+func (o *Outbox) MarkDead(ctx context.Context, id string, cause error) error {
+	return o.markFailed(ctx, id, cause, time.Time{}, true)
+}
+
+func (o *Outbox) markFailed(ctx context.Context, id string, cause error, retryAt time.Time, dead bool) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid outbox message id: %w", err)
+	}
+	if cause == nil {
+		cause = errors.New("unknown error")
+	}
+	now := time.Now()
+	set := bson.M{"lastError": cause.Error(), "failedAt": now}
+	if dead {
+		set["deadAt"] = now
+	} else {
+		set["retryAt"] = retryAt
+	}
+	_, err = o.coll.UpdateOne(ctx,
+		bson.M{"_id": oid},
+		bson.M{"$inc": bson.M{"attempts": 1}, "$set": set},
+	)
+	return err
+}
+
+func (o *Outbox) Cleanup(ctx context.Context, before time.Time) (int64, error) {
+	res, err := o.coll.DeleteMany(ctx, bson.M{"deliveredAt": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
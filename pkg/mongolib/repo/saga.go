@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/sagalib"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultSagaCollection = "sagas"
+
+type sagaDocument struct {
+	ID        string     `bson:"_id"`
+	Saga      string     `bson:"saga"`
+	Status    string     `bson:"status"`
+	Step      int        `bson:"step"`
+	StepName  string     `bson:"stepName,omitempty"`
+	Data      []byte     `bson:"data"`
+	Error     string     `bson:"error,omitempty"`
+	Attempts  int        `bson:"attempts"`
+	Deadline  *time.Time `bson:"deadline"`
+	Version   int        `bson:"version"`
+	CreatedAt time.Time  `bson:"createdAt"`
+	UpdatedAt time.Time  `bson:"updatedAt"`
+}
+
+var _ sagalib.Store = (*SagaStore)(nil)
+
+// SagaStore stores the state of the sagas in a collection.
+type SagaStore struct {
+	coll *mongo.Collection
+}
+
+// NewSagaStore returns the saga store on the collection, DefaultSagaCollection if empty.
+func NewSagaStore(connection *Connection, collection string) *SagaStore {
+	if collection == "" {
+		collection = DefaultSagaCollection
+	}
+	return &SagaStore{coll: connection.db.Collection(collection)}
+}
+
+// CreateIndexes creates the indexes of the expired deadlines and of the status lookups.
+func (s *SagaStore) CreateIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "saga", Value: 1}, {Key: "deadline", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	return err
+}
+
+func (s *SagaStore) Create(ctx context.Context, state *sagalib.State) error {
+	_, err := s.coll.InsertOne(ctx, sagaDoc(state))
+	if mongo.IsDuplicateKeyError(err) {
+		return sagalib.ErrExists
+	}
+	return err
+}
+
+func (s *SagaStore) Get(ctx context.Context, id string) (*sagalib.State, error) {
+	var doc sagaDocument
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, sagalib.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.state(), nil
+}
+
+func (s *SagaStore) Update(ctx context.Context, state *sagalib.State) error {
+	doc := sagaDoc(state)
+	doc.Version++
+	res, err := s.coll.ReplaceOne(ctx, bson.M{"_id": state.ID, "version": state.Version}, doc)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return sagalib.ErrConflict
+	}
+	state.Version++
+	return nil
+}
+
+func (s *SagaStore) Expired(ctx context.Context, saga string, before time.Time, limit int) ([]*sagalib.State, error) {
+	filter := bson.M{
+		"saga":     saga,
+		"deadline": bson.M{"$lt": before},
+		"status":   bson.M{"$in": bson.A{string(sagalib.StatusRunning), string(sagalib.StatusCompensating)}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "deadline", Value: 1}}).SetLimit(int64(limit))
+	return s.find(ctx, filter, opts)
+}
+
+func (s *SagaStore) Find(ctx context.Context, filter sagalib.Filter) ([]*sagalib.State, error) {
+	query := bson.M{}
+	if filter.Saga != "" {
+		query["saga"] = filter.Saga
+	}
+	if filter.Status != "" {
+		query["status"] = string(filter.Status)
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	return s.find(ctx, query, opts)
+}
+
+func (s *SagaStore) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*sagalib.State, error) {
+	cur, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []sagaDocument
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	states := make([]*sagalib.State, len(docs))
+	for i, d := range docs {
+		states[i] = d.state()
+	}
+	return states, nil
+}
+
+func sagaDoc(st *sagalib.State) sagaDocument {
+	return sagaDocument{
+		ID:        st.ID,
+		Saga:      st.Saga,
+		Status:    string(st.Status),
+		Step:      st.Step,
+		StepName:  st.StepName,
+		Data:      st.Data,
+		Error:     st.Error,
+		Attempts:  st.Attempts,
+		Deadline:  st.Deadline,
+		Version:   st.Version,
+		CreatedAt: st.CreatedAt,
+		UpdatedAt: st.UpdatedAt,
+	}
+}
+
+func (d sagaDocument) state() *sagalib.State {
+	return &sagalib.State{
+		ID:        d.ID,
+		Saga:      d.Saga,
+		Status:    sagalib.Status(d.Status),
+		Step:      d.Step,
+		StepName:  d.StepName,
+		Data:      d.Data,
+		Error:     d.Error,
+		Attempts:  d.Attempts,
+		Deadline:  d.Deadline,
+		Version:   d.Version,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
@@ -1,14 +1,24 @@
 package mqttlib
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sandrolain/gomsvc/pkg/inboxlib"
+	"github.com/sandrolain/gomsvc/pkg/outboxlib"
+	"github.com/sandrolain/gomsvc/pkg/resiliencelib"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // EnvClientConfig represents the environment configuration for MQTT client
@@ -36,6 +46,9 @@ type ClientOptions struct {
 	TLSConfig            *tls.Config
 	OnConnect            func()
 	OnConnectionLost     func(error)
+	// Resilience is the policy applied to Publish. Publishing is not
+	// idempotent, so the policy does not retry it
+	Resilience *resiliencelib.Policy
 }
 
 // DefaultClientOptions returns default client options
@@ -80,6 +93,8 @@ func NewClient(co ClientOptions) (*Client, error) {
 	opts.SetAutoReconnect(co.AutoReconnect)
 	opts.SetMaxReconnectInterval(co.MaxReconnectInterval)
 	opts.SetConnectTimeout(co.ConnectTimeout)
+	// The messages are acknowledged by Subscribe once handled
+	opts.SetAutoAckDisabled(true)
 
 	if co.Username != "" {
 		opts.SetUsername(co.Username)
@@ -112,29 +127,59 @@ func NewClient(co ClientOptions) (*Client, error) {
 		return nil, fmt.Errorf("cannot create mqtt client: %w", token.Error())
 	}
 
-	return &Client{
-		client: &client,
-		subs:   make(map[string]mqtt.MessageHandler),
-		mu:     &sync.RWMutex{},
-	}, nil
+	res := &Client{
+		client:     &client,
+		subs:       make(map[string]mqtt.MessageHandler),
+		mu:         &sync.RWMutex{},
+		resilience: co.Resilience,
+	}
+	svc.AddHealthCheck("mqtt:"+co.ClientID, res.HealthCheck)
+	return res, nil
 }
 
 // Client represents an MQTT client
 type Client struct {
-	client *mqtt.Client
-	subs   map[string]mqtt.MessageHandler
-	mu     *sync.RWMutex
+	client     *mqtt.Client
+	subs       map[string]mqtt.MessageHandler
+	mu         *sync.RWMutex
+	resilience *resiliencelib.Policy
 }
 
 // SubscribeHandler is a function type for handling incoming messages
 type SubscribeHandler func(context.Context, IncomingMessage)
 
+// AckHandler is a function type for handling incoming messages, which are
+// acknowledged only when it returns no error.
+type AckHandler func(context.Context, IncomingMessage) error
+
 // Subscribe subscribes to a topic with the given QoS and handler
 func (c *Client) Subscribe(ctx context.Context, topic string, qos byte, h SubscribeHandler) error {
+	return c.SubscribeAck(ctx, topic, qos, func(ctx context.Context, msg IncomingMessage) error {
+		h(ctx, msg)
+		return nil
+	})
+}
+
+// SubscribeAck subscribes to a topic with the given QoS and handler. The
+// messages whose handling fails are not acknowledged, and are delivered
+// again by the broker when the session resumes.
+func (c *Client) SubscribeAck(ctx context.Context, topic string, qos byte, h AckHandler) error {
 	handler := func(c mqtt.Client, m mqtt.Message) {
-		h(ctx, IncomingMessage{
-			Message: m,
-		})
+		msg := IncomingMessage{Message: m}
+		ctx := ctx
+		// The envelopes carry the trace context of the publisher
+		if env, err := msg.Envelope(); err == nil {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(env.Headers))
+		}
+		ctx, span := startSpan(ctx, trace.SpanKindConsumer, "process", m.Topic())
+		defer span.End()
+		if err := h(ctx, msg); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			svc.Logger().ErrorContext(ctx, "Cannot handle mqtt message", "topic", m.Topic(), "error", err)
+			return
+		}
+		m.Ack()
 	}
 
 	c.mu.Lock()
@@ -171,9 +216,20 @@ func (c *Client) Unsubscribe(topics ...string) error {
 
 // Publish publishes a message to the given topic with the specified QoS
 func (c *Client) Publish(ctx context.Context, topic string, qos byte, retained bool, payload interface{}) error {
-	token := (*c.client).Publish(topic, qos, retained, payload)
-	token.Wait()
-	if err := token.Error(); err != nil {
+	ctx, span := startSpan(ctx, trace.SpanKindProducer, "send", topic)
+	defer span.End()
+	return c.publish(ctx, span, topic, qos, retained, payload)
+}
+
+func (c *Client) publish(ctx context.Context, span trace.Span, topic string, qos byte, retained bool, payload interface{}) error {
+	err := c.resilience.DoOnce(ctx, func(ctx context.Context) error {
+		token := (*c.client).Publish(topic, qos, retained, payload)
+		token.Wait()
+		return token.Error()
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("failed to publish message to topic %s: %w", topic, err)
 	}
 	return nil
@@ -186,11 +242,31 @@ func (c *Client) Close() {
 	}
 }
 
+// Component returns the svc lifecycle component disconnecting the client on stop
+func (c *Client) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Stop: func(ctx context.Context) error {
+			c.Close()
+			return nil
+		},
+	}
+}
+
 // IsConnected returns true if the client is currently connected
 func (c *Client) IsConnected() bool {
 	return (*c.client).IsConnected()
 }
 
+// HealthCheck returns an error if the client is not connected
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("mqtt client not connected")
+	}
+	return nil
+}
+
 // IncomingMessage represents a received MQTT message
 type IncomingMessage struct {
 	Message mqtt.Message
@@ -213,3 +289,81 @@ func (m *IncomingMessage) Hash() []byte {
 	h.Write(d)
 	return h.Sum(nil)
 }
+
+// Envelope is the JSON format of the messages carrying an ID and headers,
+// which MQTT 3.1.1 messages lack.
+type Envelope struct {
+	ID      string            `json:"id"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload []byte            `json:"payload"`
+}
+
+// PublishEnvelope publishes the envelope to the given topic with the specified QoS.
+// The trace context is added to the headers, to be continued by the subscribers.
+func (c *Client) PublishEnvelope(ctx context.Context, topic string, qos byte, retained bool, env Envelope) error {
+	ctx, span := startSpan(ctx, trace.SpanKindProducer, "send", topic)
+	defer span.End()
+
+	headers := make(map[string]string, len(env.Headers)+2)
+	for k, v := range env.Headers {
+		headers[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	env.Headers = headers
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("cannot marshal envelope: %w", err)
+	}
+	return c.publish(ctx, span, topic, qos, retained, data)
+}
+
+// Envelope decodes the payload of the message published with PublishEnvelope.
+func (m *IncomingMessage) Envelope() (Envelope, error) {
+	var env Envelope
+	dec := json.NewDecoder(bytes.NewReader(m.Message.Payload()))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&env); err != nil {
+		return env, fmt.Errorf("invalid envelope: %w", err)
+	}
+	return env, nil
+}
+
+// OutboxPublisher returns the outbox publisher sending each message to the
+// MQTT topic named as its topic, in an Envelope keeping the outbox message
+// ID and headers.
+func (c *Client) OutboxPublisher(qos byte) outboxlib.Publisher {
+	return outboxlib.PublisherFunc(func(ctx context.Context, msg outboxlib.Message) error {
+		return c.PublishEnvelope(ctx, msg.Topic, qos, false, Envelope{
+			ID:      msg.ID,
+			Headers: msg.Headers,
+			Payload: msg.Payload,
+		})
+	})
+}
+
+// ID returns the ID of the message published with PublishEnvelope,
+// identifying it in the inbox. It is empty for the other messages.
+func (m *IncomingMessage) ID() string {
+	env, err := m.Envelope()
+	if err != nil {
+		return ""
+	}
+	return env.ID
+}
+
+// InboxHandler wraps h to skip the messages already processed, identified
+// by their ID, for SubscribeAck. The messages without an ID, not published
+// with PublishEnvelope, are handled without deduplication. The messages
+// failing or still in progress elsewhere are not acknowledged.
+func InboxHandler(in *inboxlib.Inbox, h AckHandler) AckHandler {
+	return func(ctx context.Context, msg IncomingMessage) error {
+		if id := msg.ID(); id != "" {
+			return in.Do(ctx, id, func(ctx context.Context) error {
+				return h(ctx, msg)
+			})
+		}
+		svc.Logger().WarnContext(ctx, "Cannot deduplicate mqtt message without id", "topic", msg.Topic())
+		return h(ctx, msg)
+	}
+}
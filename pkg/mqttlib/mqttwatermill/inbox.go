@@ -0,0 +1,28 @@
+package mqttwatermill
+
+import (
+	"context"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/sandrolain/gomsvc/pkg/inboxlib"
+)
+
+// InboxMiddleware is the router middleware skipping the messages already
+// processed, identified by their MetadataID. The messages without it are
+// handled without deduplication.
+func InboxMiddleware(in *inboxlib.Inbox) message.HandlerMiddleware {
+	return func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			id := msg.Metadata.Get(MetadataID)
+			if id == "" {
+				return h(msg)
+			}
+			var produced []*message.Message
+			err := in.Do(msg.Context(), id, func(ctx context.Context) (err error) {
+				produced, err = h(msg)
+				return
+			})
+			return produced, err
+		}
+	}
+}
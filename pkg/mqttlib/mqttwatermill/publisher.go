@@ -1,7 +1,6 @@
 package mqttwatermill
 
 import (
-	"context"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -18,6 +17,9 @@ const (
 	MetadataRetained  = "mqtt_retained"
 	MetadataMessageID = "mqtt_message_id"
 	MetadataDuplicate = "mqtt_duplicate"
+	// MetadataID is the ID of the received message published with
+	// mqttlib PublishEnvelope, missing for the other messages
+	MetadataID = "mqtt_id"
 )
 
 // Publisher is a Watermill Publisher implementation for MQTT
@@ -40,7 +42,8 @@ func NewPublisher(client *mqttlib.Client, logger *slog.Logger) (message.Publishe
 	}, nil
 }
 
-// Publish publishes messages to MQTT
+// Publish publishes messages to MQTT, in the mqttlib envelopes keeping
+// their UUID and metadata
 func (p *Publisher) Publish(topic string, messages ...*message.Message) error {
 	p.closedLock.Lock()
 	if p.closed {
@@ -72,7 +75,13 @@ func (p *Publisher) Publish(topic string, messages ...*message.Message) error {
 			"retained":  retained,
 		})
 
-		if err := p.client.Publish(context.Background(), topic, qos, retained, msg.Payload); err != nil {
+		// The metadata, with the trace context, travel in the envelope
+		env := mqttlib.Envelope{
+			ID:      msg.UUID,
+			Headers: msg.Metadata,
+			Payload: msg.Payload,
+		}
+		if err := p.client.PublishEnvelope(msg.Context(), topic, qos, retained, env); err != nil {
 			return err
 		}
 	}
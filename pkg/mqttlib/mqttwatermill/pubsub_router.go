@@ -12,6 +12,7 @@ import (
 	"github.com/ThreeDotsLabs/watermill/message/router/plugin"
 
 	"github.com/sandrolain/gomsvc/pkg/mqttlib"
+	"github.com/sandrolain/gomsvc/pkg/svc"
 )
 
 // PubSubRouter is a Watermill Router implementation that routes messages to GCP Pub/Sub
@@ -95,3 +96,31 @@ func (r *PubSubRouter) Start(ctx context.Context) error {
 func (r *PubSubRouter) Close() error {
 	return r.Subscriber.Close()
 }
+
+// Component returns the svc lifecycle component running the router.
+// Start waits until the router is running or the start context is done.
+func (r *PubSubRouter) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			go func() {
+				if err := r.Start(context.Background()); err != nil {
+					_ = svc.Error("router stopped", err)
+				}
+			}()
+			select {
+			case <-r.Router.Running():
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		Stop: func(ctx context.Context) error {
+			if err := r.Router.Close(); err != nil {
+				return err
+			}
+			return r.Close()
+		},
+	}
+}
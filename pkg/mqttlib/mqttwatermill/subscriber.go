@@ -2,6 +2,7 @@ package mqttwatermill
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -10,8 +11,13 @@ import (
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/sandrolain/gomsvc/pkg/mqttlib"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
+// errNacked fails the handling of the messages nacked by the router.
+var errNacked = errors.New("message nacked")
+
 // Subscriber is a Watermill Subscriber implementation for MQTT
 type Subscriber struct {
 	client     *mqttlib.Client
@@ -58,13 +64,33 @@ func (s *Subscriber) Subscribe(ctx context.Context, topic string) (<-chan *messa
 	s.outputChannelsLock.Unlock()
 
 	// Subscribe to MQTT topic
-	err := s.client.Subscribe(ctx, topic, 1, func(ctx context.Context, msg mqttlib.IncomingMessage) {
-		message := message.NewMessage(watermill.NewUUID(), msg.Payload())
+	err := s.client.SubscribeAck(ctx, topic, 1, func(ctx context.Context, msg mqttlib.IncomingMessage) error {
+		// The messages of the Publisher travel in envelopes
+		uuid, payload := watermill.NewUUID(), msg.Payload()
+		env, envErr := msg.Envelope()
+		if envErr == nil {
+			payload = env.Payload
+			if env.ID != "" {
+				uuid = env.ID
+			}
+		}
+		message := message.NewMessage(uuid, payload)
+		if envErr == nil {
+			for k, v := range env.Headers {
+				message.Metadata.Set(k, v)
+			}
+			if env.ID != "" {
+				message.Metadata.Set(MetadataID, env.ID)
+			}
+		}
 		message.Metadata.Set(MetadataTopic, msg.Topic())
 		message.Metadata.Set(MetadataMessageID, strconv.Itoa(int(msg.Message.MessageID())))
 		message.Metadata.Set(MetadataQoS, strconv.Itoa(int(msg.Message.Qos())))
 		message.Metadata.Set(MetadataDuplicate, strconv.FormatBool((msg.Message.Duplicate())))
 		message.Metadata.Set(MetadataRetained, strconv.FormatBool(msg.Message.Retained()))
+		// Forward the trace context of the received message to the next publisher
+		otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(message.Metadata))
+		message.SetContext(ctx)
 
 		s.logger.Trace("Received message", watermill.LogFields{
 			"topic":     topic,
@@ -73,13 +99,14 @@ func (s *Subscriber) Subscribe(ctx context.Context, topic string) (<-chan *messa
 
 		output <- message
 
-		// Send message to output channel
+		// The nacked messages are not acknowledged to the broker
 		select {
 		case <-message.Acked():
-			msg.Message.Ack()
+			return nil
 		case <-message.Nacked():
-			// TODO: Handle NACK
+			return errNacked
 		case <-ctx.Done():
+			return ctx.Err()
 		}
 	})
 
@@ -0,0 +1,50 @@
+package mqttlib
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/sandrolain/gomsvc/pkg/mqttlib"
+
+var (
+	sentMessages, _ = otel.Meter(instrumentationName).Int64Counter(
+		"messaging.client.sent.messages",
+		metric.WithDescription("Number of messages published to MQTT."),
+	)
+	consumedMessages, _ = otel.Meter(instrumentationName).Int64Counter(
+		"messaging.client.consumed.messages",
+		metric.WithDescription("Number of messages received from MQTT."),
+	)
+)
+
+func messagingAttributes(operation string, topic string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String("mqtt"),
+		semconv.MessagingOperationName(operation),
+		semconv.MessagingDestinationName(topic),
+	}
+}
+
+// startSpan starts the span of a published or received message.
+// MQTT 3.1.1 messages have no headers, so the trace context is propagated
+// only by the envelopes of PublishEnvelope: each other received message
+// starts a new trace from ctx.
+func startSpan(ctx context.Context, kind trace.SpanKind, operation string, topic string) (context.Context, trace.Span) {
+	attrs := messagingAttributes(operation, topic)
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, operation+" "+topic,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(attrs...),
+	)
+	counter := sentMessages
+	if kind == trace.SpanKindConsumer {
+		counter = consumedMessages
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(attrs...))
+	return ctx, span
+}
@@ -0,0 +1,99 @@
+// Package outboxlib implements the transactional outbox pattern: the events
+// are written in the same database transaction of the changes they describe,
+// then a relay publishes them to the broker with at-least-once delivery.
+//
+// The stores are provided by dblib (gorm) and mongolib, the publishers by
+// redislib, mqttlib and gcplib.
+package outboxlib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoRoute is returned by Routes for the topics without a publisher.
+var ErrNoRoute = errors.New("no publisher for the outbox topic")
+
+// Message is an event stored in the outbox.
+type Message struct {
+	// ID is assigned by the store, in the order the messages are written.
+	ID string
+	// Topic is the destination of the message, such as a stream or a topic of the broker.
+	Topic string
+	// Key is the aggregate key: the messages with the same key are published in order.
+	Key     string
+	Payload []byte
+	Headers map[string]string
+	// Attempts is the number of failed publish attempts.
+	Attempts  int
+	LastError string
+	// FailedAt is the time of the last failed publish attempt.
+	FailedAt time.Time
+	// RetryAt is the time of the next publish attempt after a failure.
+	RetryAt   time.Time
+	CreatedAt time.Time
+}
+
+// NewMessage creates a message with the JSON encoding of payload.
+func NewMessage(topic string, key string, payload any) (Message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, fmt.Errorf("cannot marshal outbox payload: %w", err)
+	}
+	return Message{
+		Topic:     topic,
+		Key:       key,
+		Payload:   data,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// Decode decodes the JSON payload of the message into v.
+func (m Message) Decode(v any) error {
+	return json.Unmarshal(m.Payload, v)
+}
+
+// Store is the storage of the outbox read by the relay. The messages are
+// written by the store specific methods, within the caller transaction.
+type Store interface {
+	// Pending returns up to limit messages neither delivered nor dead, in
+	// write order. The messages waiting for their RetryAt are excluded with
+	// all the messages of their key, so that they do not fill the batches.
+	Pending(ctx context.Context, limit int) ([]Message, error)
+	// MarkDelivered marks the messages as delivered.
+	MarkDelivered(ctx context.Context, ids []string) error
+	// MarkFailed records a failed publish attempt of the message, at
+	// FailedAt, to be retried at retryAt.
+	MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error
+	// MarkDead records the last failed publish attempt of the message and
+	// parks it, excluding it from Pending and from Cleanup.
+	MarkDead(ctx context.Context, id string, cause error) error
+	// Cleanup deletes the messages delivered before the given time.
+	Cleanup(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Publisher publishes the outbox messages to a broker.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// PublisherFunc is a function implementing Publisher.
+type PublisherFunc func(ctx context.Context, msg Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// Routes is a Publisher dispatching each message to the publisher of its topic.
+type Routes map[string]Publisher
+
+func (r Routes) Publish(ctx context.Context, msg Message) error {
+	p, ok := r[msg.Topic]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoRoute, msg.Topic)
+	}
+	return p.Publish(ctx, msg)
+}
@@ -0,0 +1,256 @@
+package outboxlib
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+const (
+	DefaultRelayInterval   = time.Second
+	DefaultRelayBatchSize  = 100
+	DefaultRetention       = 24 * time.Hour
+	DefaultCleanupInterval = 10 * time.Minute
+	DefaultMaxAttempts     = 10
+	DefaultRetryBackoff    = time.Second
+	DefaultMaxRetryBackoff = 5 * time.Minute
+)
+
+var relayedTotal = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "outbox_relayed_total",
+	Help: "Total number of outbox messages relayed, by result.",
+}, []string{"relay", "result"}))
+
+type RelayOptions struct {
+	// Name labels the logs and the metrics of the relay.
+	Name string
+	// Interval is the delay between the polls of the store when idle.
+	Interval time.Duration
+	// BatchSize is the maximum number of messages read at each poll.
+	BatchSize int
+	// Retention is how long the delivered messages are kept before the cleanup.
+	Retention       time.Duration
+	CleanupInterval time.Duration
+	// MaxAttempts is the number of failed publish attempts after which a
+	// message is marked as dead, no longer holding back the following
+	// messages of its key.
+	MaxAttempts int
+	// RetryBackoff is the delay before the retry of a failed message,
+	// doubled at each attempt up to MaxRetryBackoff.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// Relay publishes the pending messages of an outbox store. A single relay
+// must run for each store to keep the order of the messages, as with
+// svc.NewLeader calling Run in OnElected.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	opts      RelayOptions
+	notify    chan struct{}
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRelay creates the relay publishing the messages of store with publisher.
+func NewRelay(store Store, publisher Publisher, opts RelayOptions) *Relay {
+	if opts.Name == "" {
+		opts.Name = "outbox"
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultRelayInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultRelayBatchSize
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = DefaultCleanupInterval
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if opts.MaxRetryBackoff <= 0 {
+		opts.MaxRetryBackoff = DefaultMaxRetryBackoff
+	}
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		opts:      opts,
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the relay up, to publish the messages of a committed
+// transaction without waiting for the next poll.
+func (r *Relay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run relays the messages until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+
+	log := svc.Logger().With("relay", r.opts.Name)
+	var lastCleanup time.Time
+
+	for {
+		n, err := r.Flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warn("Cannot relay the outbox messages", "error", err)
+		}
+
+		if time.Since(lastCleanup) >= r.opts.CleanupInterval {
+			lastCleanup = time.Now()
+			deleted, err := r.store.Cleanup(ctx, lastCleanup.Add(-r.opts.Retention))
+			if err != nil && ctx.Err() == nil {
+				log.Warn("Cannot clean up the outbox", "error", err)
+			} else if deleted > 0 {
+				log.Debug("Outbox cleaned up", "deleted", deleted)
+			}
+		}
+
+		// A fully delivered batch means that more messages may be waiting
+		if n == r.opts.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.notify:
+		}
+	}
+}
+
+// Flush publishes a batch of pending messages, returning the number of
+// messages delivered. When a message fails, or waits for its retry, the
+// following ones with the same key are left pending to keep their order.
+// The messages failing MaxAttempts times are marked as dead.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	msgs, err := r.store.Pending(ctx, r.opts.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	failedKeys := make(map[string]bool)
+	delivered := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Key != "" && failedKeys[msg.Key] {
+			continue
+		}
+		// The stores exclude the messages waiting for their retry, checked
+		// again against the clock of the relay
+		if time.Now().Before(msg.RetryAt) {
+			if msg.Key != "" {
+				failedKeys[msg.Key] = true
+			}
+			continue
+		}
+		if err := r.publisher.Publish(ctx, msg); err != nil {
+			if msg.Attempts+1 >= r.opts.MaxAttempts {
+				relayedTotal.WithLabelValues(r.opts.Name, "dead").Inc()
+				svc.Logger().Error("Outbox message marked as dead", "relay", r.opts.Name,
+					"id", msg.ID, "topic", msg.Topic, "attempts", msg.Attempts+1, "error", err)
+				if e := r.store.MarkDead(ctx, msg.ID, err); e != nil {
+					return 0, e
+				}
+				continue
+			}
+			relayedTotal.WithLabelValues(r.opts.Name, "failed").Inc()
+			if msg.Key != "" {
+				failedKeys[msg.Key] = true
+			}
+			if e := r.store.MarkFailed(ctx, msg.ID, err, time.Now().Add(r.backoff(msg.Attempts+1))); e != nil {
+				return 0, e
+			}
+			continue
+		}
+		relayedTotal.WithLabelValues(r.opts.Name, "delivered").Inc()
+		delivered = append(delivered, msg.ID)
+	}
+
+	if len(delivered) > 0 {
+		if err := r.store.MarkDelivered(ctx, delivered); err != nil {
+			return 0, err
+		}
+	}
+	return len(delivered), nil
+}
+
+// backoff returns the delay before the retry of a message failed attempts times.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.opts.RetryBackoff
+	for i := 1; i < attempts && d < r.opts.MaxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.opts.MaxRetryBackoff)
+}
+
+// Start runs the relay in background.
+func (r *Relay) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	r.cancel, r.done = cancel, done
+	go func() {
+		defer close(done)
+		svc.Run("outboxlib.relay", func() { r.Run(ctx) })
+	}()
+}
+
+// Stop stops the relay started by Start and waits for it to return, until ctx is done.
+func (r *Relay) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if r.cancel == nil {
+		r.mu.Unlock()
+		return nil
+	}
+	r.cancel()
+	done := r.done
+	r.cancel, r.done = nil, nil
+	r.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Component returns the svc lifecycle component running the relay.
+func (r *Relay) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			r.Start()
+			return nil
+		},
+		Stop: r.Stop,
+	}
+}
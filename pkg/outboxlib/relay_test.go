@@ -0,0 +1,254 @@
+package outboxlib
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	mu        sync.Mutex
+	msgs      []Message
+	delivered map[string]time.Time
+	dead      map[string]bool
+}
+
+func newMemoryStore(msgs ...Message) *memoryStore {
+	s := &memoryStore{delivered: map[string]time.Time{}, dead: map[string]bool{}}
+	for i, msg := range msgs {
+		msg.ID = strconv.Itoa(i + 1)
+		s.msgs = append(s.msgs, msg)
+	}
+	return s
+}
+
+func (s *memoryStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	blocked := make(map[string]bool)
+	for _, msg := range s.msgs {
+		if _, ok := s.delivered[msg.ID]; !ok && !s.dead[msg.ID] && msg.Key != "" && now.Before(msg.RetryAt) {
+			blocked[msg.Key] = true
+		}
+	}
+	var res []Message
+	for _, msg := range s.msgs {
+		if _, ok := s.delivered[msg.ID]; ok || s.dead[msg.ID] || blocked[msg.Key] || now.Before(msg.RetryAt) {
+			continue
+		}
+		if len(res) < limit {
+			res = append(res, msg)
+		}
+	}
+	return res, nil
+}
+
+func (s *memoryStore) MarkDelivered(ctx context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.delivered[id] = time.Now()
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.msgs {
+		if s.msgs[i].ID == id {
+			s.msgs[i].Attempts++
+			s.msgs[i].LastError = cause.Error()
+			s.msgs[i].FailedAt = time.Now()
+			s.msgs[i].RetryAt = retryAt
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkDead(ctx context.Context, id string, cause error) error {
+	if err := s.MarkFailed(ctx, id, cause, time.Time{}); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dead[id] = true
+	return nil
+}
+
+func (s *memoryStore) Cleanup(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	kept := s.msgs[:0]
+	for _, msg := range s.msgs {
+		if at, ok := s.delivered[msg.ID]; ok && at.Before(before) {
+			n++
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	s.msgs = kept
+	return n, nil
+}
+
+func message(t *testing.T, topic string, key string, payload any) Message {
+	msg, err := NewMessage(topic, key, payload)
+	require.NoError(t, err)
+	return msg
+}
+
+func TestRelayKeepsKeyOrder(t *testing.T) {
+	store := newMemoryStore(
+		message(t, "orders", "a", 1),
+		message(t, "orders", "b", 2),
+		message(t, "orders", "a", 3),
+		message(t, "orders", "b", 4),
+	)
+
+	var published []int
+	failA := true
+	publisher := PublisherFunc(func(ctx context.Context, msg Message) error {
+		var n int
+		require.NoError(t, msg.Decode(&n))
+		if msg.Key == "a" && failA {
+			return errors.New("broker down")
+		}
+		published = append(published, n)
+		return nil
+	})
+
+	relay := NewRelay(store, publisher, RelayOptions{RetryBackoff: time.Nanosecond})
+	n, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []int{2, 4}, published)
+	require.Equal(t, 1, store.msgs[0].Attempts)
+	require.Equal(t, "broker down", store.msgs[0].LastError)
+	require.Zero(t, store.msgs[2].Attempts, "the following message of the key must not be attempted")
+
+	failA = false
+	n, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []int{2, 4, 1, 3}, published)
+}
+
+func TestRelayRun(t *testing.T) {
+	store := newMemoryStore(message(t, "a", "", "x"), message(t, "b", "", "y"), message(t, "c", "", "z"))
+
+	var mu sync.Mutex
+	var topics []string
+	publisher := Routes{
+		"a": PublisherFunc(func(ctx context.Context, msg Message) error {
+			mu.Lock()
+			topics = append(topics, msg.Topic)
+			mu.Unlock()
+			return nil
+		}),
+	}
+	publisher["b"] = publisher["a"]
+
+	relay := NewRelay(store, publisher, RelayOptions{
+		BatchSize:       1,
+		Interval:        time.Hour,
+		Retention:       time.Nanosecond,
+		CleanupInterval: time.Nanosecond,
+	})
+	relay.Start()
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(topics) == 2
+	}, time.Second, time.Millisecond)
+	require.NoError(t, relay.Stop(context.Background()))
+
+	require.Equal(t, []string{"a", "b"}, topics)
+	pending, _ := store.Pending(context.Background(), 10)
+	require.Empty(t, pending, "the failed message waits for its retry")
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Len(t, store.msgs, 1, "the delivered messages are cleaned up")
+	require.Contains(t, store.msgs[0].LastError, ErrNoRoute.Error())
+}
+
+func TestRelayBackoffAndDead(t *testing.T) {
+	store := newMemoryStore(
+		message(t, "orders", "a", 1),
+		message(t, "orders", "a", 2),
+	)
+
+	var published []int
+	publisher := PublisherFunc(func(ctx context.Context, msg Message) error {
+		var n int
+		require.NoError(t, msg.Decode(&n))
+		if n == 1 {
+			return errors.New("poison message")
+		}
+		published = append(published, n)
+		return nil
+	})
+
+	relay := NewRelay(store, publisher, RelayOptions{
+		MaxAttempts:  3,
+		RetryBackoff: 20 * time.Millisecond,
+	})
+	require.Equal(t, 40*time.Millisecond, relay.backoff(2))
+
+	_, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	// Waiting for the retry
+	_, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, store.msgs[0].Attempts)
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(relay.backoff(store.msgs[0].Attempts))
+		_, err = relay.Flush(context.Background())
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, store.msgs[0].Attempts)
+	require.True(t, store.dead[store.msgs[0].ID])
+	require.Equal(t, []int{2}, published, "the dead message no longer holds back its key")
+
+	pending, err := store.Pending(context.Background(), 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestRelayBackoffDoesNotStarve(t *testing.T) {
+	store := newMemoryStore(
+		message(t, "orders", "a", 1),
+		message(t, "orders", "a", 2),
+		message(t, "orders", "a", 3),
+		message(t, "orders", "b", 4),
+	)
+
+	var published []int
+	publisher := PublisherFunc(func(ctx context.Context, msg Message) error {
+		var n int
+		require.NoError(t, msg.Decode(&n))
+		if msg.Key == "a" {
+			return errors.New("broker down")
+		}
+		published = append(published, n)
+		return nil
+	})
+
+	relay := NewRelay(store, publisher, RelayOptions{BatchSize: 2, RetryBackoff: time.Hour})
+	n, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n, "the first batch holds only the messages of the failing key")
+
+	n, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []int{4}, published, "the key waiting for its retry does not fill the batch")
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/redis/go-redis/v9"
+	"github.com/sandrolain/gomsvc/pkg/resiliencelib"
 	"github.com/sandrolain/gomsvc/pkg/svc"
 )
 
@@ -33,6 +34,12 @@ type ClientOptions struct {
 	Password string        `validation:"required"`
 	Timeout  time.Duration `validation:"required"`
 	TLS      *tls.Config
+	// Resilience is the policy applied to the commands
+	Resilience *resiliencelib.Policy
+	// Idempotent reports whether a command, or all the commands of a
+	// pipeline, can be retried by Resilience, as ReadOnlyCommand.
+	// By default no command is retried.
+	Idempotent func(cmd redis.Cmder) bool
 }
 
 func ClientOptionsFromEnvConfig(cfg EnvClientConfig) ClientOptions {
@@ -71,6 +78,9 @@ func Connect(config ClientOptions) (err error) {
 		DB:        0, // use default DB
 		TLSConfig: config.TLS,
 	})
+	if config.Resilience != nil {
+		redisClient.AddHook(resilienceHook{config.Resilience, config.Idempotent})
+	}
 
 	ctx, cancel := timeoutCtx()
 	defer cancel()
@@ -79,5 +89,29 @@ func Connect(config ClientOptions) (err error) {
 		err = svc.Error("cannot ping redis", e)
 		return
 	}
+
+	svc.AddHealthCheck("redis", HealthCheck)
 	return
 }
+
+// HealthCheck pings the redis server
+func HealthCheck(ctx context.Context) error {
+	if redisClient == nil {
+		return fmt.Errorf("redis client not connected")
+	}
+	return redisClient.Ping(ctx).Err()
+}
+
+// Component returns the svc lifecycle component closing the redis client on stop.
+func Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Stop: func(ctx context.Context) error {
+			if redisClient == nil {
+				return nil
+			}
+			return redisClient.Close()
+		},
+	}
+}
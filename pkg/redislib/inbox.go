@@ -0,0 +1,79 @@
+package redislib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sandrolain/gomsvc/pkg/eventlib"
+	"github.com/sandrolain/gomsvc/pkg/inboxlib"
+)
+
+const DefaultInboxPrefix = "inbox"
+
+// claimInboxScript sets the processing record unless a record not failed
+// exists, returning its status. The processing records expire after the
+// processing timeout.
+var claimInboxScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current then
+	local status = cjson.decode(current).status
+	if status ~= "failed" then
+		return status
+	end
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return ""
+`)
+
+// InboxStore stores the inbox records in keys expiring with their retention.
+type InboxStore struct {
+	prefix string
+}
+
+var _ inboxlib.Store = (*InboxStore)(nil)
+
+// NewInboxStore returns the inbox store keeping the records under prefix,
+// DefaultInboxPrefix if empty.
+func NewInboxStore(prefix string) *InboxStore {
+	if prefix == "" {
+		prefix = DefaultInboxPrefix
+	}
+	return &InboxStore{prefix: prefix}
+}
+
+func (s *InboxStore) key(consumer string, id string) string {
+	return fmt.Sprintf("%s:%s:%s", s.prefix, consumer, id)
+}
+
+func (s *InboxStore) Claim(ctx context.Context, consumer string, id string, timeout time.Duration) (inboxlib.Status, error) {
+	rec, err := json.Marshal(inboxlib.Record{ID: id, Status: inboxlib.StatusProcessing, UpdatedAt: time.Now()})
+	if err != nil {
+		return "", err
+	}
+	res, err := claimInboxScript.Run(ctx, redisClient, []string{s.key(consumer, id)}, rec, timeout.Milliseconds()).Text()
+	if err != nil {
+		return "", err
+	}
+	return inboxlib.Status(res), nil
+}
+
+func (s *InboxStore) Record(ctx context.Context, consumer string, rec inboxlib.Record, retention time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return redisClient.Set(ctx, s.key(consumer, rec.ID), data, retention).Err()
+}
+
+// InboxHandler wraps the handler of the messages of a StreamConsumer
+// Emitter to skip the messages already processed.
+func InboxHandler[T any](in *inboxlib.Inbox, fn eventlib.OnEventFn[*Message[T]]) eventlib.OnEventFn[*Message[T]] {
+	return func(msg *Message[T]) error {
+		return in.Do(msg.Context(), msg.Id, func(ctx context.Context) error {
+			return fn(msg)
+		})
+	}
+}
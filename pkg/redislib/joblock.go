@@ -0,0 +1,35 @@
+package redislib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/asynclib"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+const DefaultJobLockTTL = 10 * time.Minute
+
+// JobLock lets only one replica run each scheduled run of the asynclib jobs,
+// through a key per run set by the first replica taking it.
+type JobLock struct {
+	prefix string
+	ttl    time.Duration
+}
+
+var _ asynclib.JobLock = (*JobLock)(nil)
+
+// NewJobLock returns the job lock storing the keys under prefix.
+// The TTL only needs to be longer than the clock skew between the replicas.
+func NewJobLock(prefix string, ttl time.Duration) *JobLock {
+	if ttl <= 0 {
+		ttl = DefaultJobLockTTL
+	}
+	return &JobLock{prefix: prefix, ttl: ttl}
+}
+
+func (l *JobLock) TryLock(ctx context.Context, job string, scheduled time.Time) (bool, error) {
+	key := fmt.Sprintf("%s:%s:%d", l.prefix, job, scheduled.Unix())
+	return redisClient.SetNX(ctx, key, svc.ServiceID(), l.ttl).Result()
+}
@@ -0,0 +1,78 @@
+package redislib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.jetpack.io/typeid"
+)
+
+const DefaultLeaseTTL = 15 * time.Second
+
+var renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Lease is a leader lock held through a Redis key expiring after its TTL,
+// unless renewed by the holder.
+type Lease struct {
+	key   string
+	value string
+	ttl   time.Duration
+}
+
+// NewLease returns the leader lock on the key. The TTL should be a few times
+// the election interval, so that a renewal can fail without losing the lease.
+func NewLease(key string, ttl time.Duration) (*Lease, error) {
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	id, err := typeid.From("lease", "")
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate lease ID: %w", err)
+	}
+	return &Lease{key: key, value: id.String(), ttl: ttl}, nil
+}
+
+func (l *Lease) Acquire(ctx context.Context) (bool, error) {
+	return redisClient.SetNX(ctx, l.key, l.value, l.ttl).Result()
+}
+
+func (l *Lease) Renew(ctx context.Context) (bool, error) {
+	res, err := renewLeaseScript.Run(ctx, redisClient, []string{l.key}, l.value, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+func (l *Lease) Release(ctx context.Context) error {
+	return releaseLeaseScript.Run(ctx, redisClient, []string{l.key}, l.value).Err()
+}
+
+// NewLeader returns the svc leader elected through a lease on the key,
+// renewed every TTL/3 and kept through the failed renewals until the TTL expires.
+func NewLeader(name string, key string, ttl time.Duration, opts svc.LeaderOptions) (*svc.Leader, error) {
+	lease, err := NewLease(key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = lease.ttl / 3
+	}
+	opts.TTL = lease.ttl
+	return svc.NewLeader(name, lease, opts), nil
+}
@@ -0,0 +1,85 @@
+package redislib
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+type streamGroup struct {
+	stream string
+	group  string
+}
+
+// streamCollector reports the lag and the pending messages of the groups
+// of the running stream consumers.
+type streamCollector struct {
+	mu      sync.Mutex
+	groups  map[streamGroup]int
+	lag     *prometheus.Desc
+	pending *prometheus.Desc
+}
+
+var streamMetrics = svc.RegisterMetric(&streamCollector{
+	groups: make(map[streamGroup]int),
+	lag: prometheus.NewDesc(
+		"redis_stream_consumer_lag",
+		"Number of stream entries not yet delivered to the consumer group.",
+		[]string{"stream", "group"}, nil,
+	),
+	pending: prometheus.NewDesc(
+		"redis_stream_pending_messages",
+		"Number of messages delivered to the consumer group and not yet acknowledged.",
+		[]string{"stream", "group"}, nil,
+	),
+})
+
+func (c *streamCollector) add(stream string, group string) {
+	c.mu.Lock()
+	c.groups[streamGroup{stream, group}]++
+	c.mu.Unlock()
+}
+
+func (c *streamCollector) remove(stream string, group string) {
+	key := streamGroup{stream, group}
+	c.mu.Lock()
+	if c.groups[key] <= 1 {
+		delete(c.groups, key)
+	} else {
+		c.groups[key]--
+	}
+	c.mu.Unlock()
+}
+
+func (c *streamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lag
+	ch <- c.pending
+}
+
+func (c *streamCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	groups := make([]streamGroup, 0, len(c.groups))
+	for g := range c.groups {
+		groups = append(groups, g)
+	}
+	c.mu.Unlock()
+
+	for _, g := range groups {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		infos, err := redisClient.XInfoGroups(ctx, g.stream).Result()
+		cancel()
+		if err != nil {
+			svc.Logger().Warn("cannot collect stream metrics", "error", err, "stream", g.stream)
+			continue
+		}
+		for _, info := range infos {
+			if info.Name != g.group {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(info.Lag), g.stream, g.group)
+			ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(info.Pending), g.stream, g.group)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/sandrolain/gomsvc/pkg/svc"
 	"go.jetpack.io/typeid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PublisherConfig struct {
@@ -16,22 +18,35 @@ type PublisherConfig struct {
 }
 
 func Publisher[T any](channel string, config PublisherConfig) func(T) error {
+	publish := PublisherWithContext[T](channel, config)
 	return func(payload T) error {
+		return publish(context.Background(), payload)
+	}
+}
+
+// PublisherWithContext is like Publisher, but the published messages
+// carry the trace context of ctx.
+func PublisherWithContext[T any](channel string, config PublisherConfig) func(context.Context, T) error {
+	return func(ctx context.Context, payload T) (err error) {
 		to := config.Timeout
 		if to == 0 {
 			to = time.Second * 10
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), to)
-		defer cancel()
 		t, err := typeid.From(config.Type, "")
 		if err != nil {
 			return err
 		}
+		ctx, span, headers := startProducerSpan(ctx, channel, t.String())
+		defer func() { endSpan(span, err) }()
+
+		ctx, cancel := context.WithTimeout(ctx, to)
+		defer cancel()
 		message := Message[T]{
 			Timestamp: time.Now(),
 			Id:        t.String(),
 			Type:      config.Type,
 			Origin:    config.Origin,
+			Headers:   headers,
 			Payload:   payload,
 		}
 		data, err := json.Marshal(message)
@@ -64,25 +79,30 @@ func Subscribe[T any](channel string, receiver ReceiverFunc[T], onError ErrorFun
 			}
 		}()
 
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case msg := <-subscription.Channel():
-				if msg == nil {
-					continue
-				}
+		svc.Run("redislib.subscribe", func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg := <-subscription.Channel():
+					if msg == nil {
+						continue
+					}
 
-				var message Message[T]
-				err := json.Unmarshal([]byte(msg.Payload), &message)
-				if err != nil {
-					onError(fmt.Errorf("failed to unmarshal message: %v", err))
-					continue
-				}
+					var message Message[T]
+					err := json.Unmarshal([]byte(msg.Payload), &message)
+					if err != nil {
+						onError(fmt.Errorf("failed to unmarshal message: %v", err))
+						continue
+					}
 
-				receiver(message)
+					var span trace.Span
+					message.ctx, span = startConsumerSpan(message.Headers, channel, message.Id)
+					receiver(message)
+					span.End()
+				}
 			}
-		}
+		})
 	}()
 
 	return func() {
@@ -94,9 +114,21 @@ func Subscribe[T any](channel string, receiver ReceiverFunc[T], onError ErrorFun
 }
 
 type Message[T any] struct {
-	Timestamp time.Time `json:"tsp"`
-	Id        string    `json:"idx"`
-	Type      string    `json:"typ"`
-	Origin    string    `json:"org"`
-	Payload   T         `json:"pld"`
+	Timestamp time.Time         `json:"tsp"`
+	Id        string            `json:"idx"`
+	Type      string            `json:"typ"`
+	Origin    string            `json:"org"`
+	Headers   map[string]string `json:"hdr,omitempty"`
+	Payload   T                 `json:"pld"`
+	ctx       context.Context
+	span      trace.Span
+}
+
+// Context returns the context of the received message, carrying the
+// span of its processing.
+func (m Message[T]) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
 }
@@ -0,0 +1,99 @@
+package redislib
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sandrolain/gomsvc/pkg/resiliencelib"
+)
+
+// resilienceHook runs the redis commands through a resilience policy,
+// retrying only the idempotent ones.
+type resilienceHook struct {
+	policy     *resiliencelib.Policy
+	idempotent func(cmd redis.Cmder) bool
+}
+
+var readOnlyCommands = map[string]bool{
+	"ping": true, "exists": true, "type": true, "ttl": true, "pttl": true, "keys": true, "scan": true, "dbsize": true,
+	"get": true, "mget": true, "strlen": true, "getrange": true,
+	"hget": true, "hmget": true, "hgetall": true, "hexists": true, "hkeys": true, "hvals": true, "hlen": true, "hscan": true,
+	"lrange": true, "llen": true, "lindex": true,
+	"smembers": true, "sismember": true, "scard": true, "sscan": true,
+	"zrange": true, "zrangebyscore": true, "zrevrange": true, "zscore": true, "zcard": true, "zrank": true, "zscan": true,
+	"xrange": true, "xrevrange": true, "xlen": true,
+}
+
+// ReadOnlyCommand reports whether cmd only reads data, to be retried as
+// ClientOptions Idempotent.
+func ReadOnlyCommand(cmd redis.Cmder) bool {
+	return readOnlyCommands[strings.ToLower(cmd.Name())]
+}
+
+func (h resilienceHook) retryable(cmds ...redis.Cmder) bool {
+	if h.idempotent == nil {
+		return false
+	}
+	for _, cmd := range cmds {
+		if !h.idempotent(cmd) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h resilienceHook) do(ctx context.Context, retry bool, fn func(ctx context.Context) error) error {
+	if retry {
+		return h.policy.Do(ctx, fn)
+	}
+	return h.policy.DoOnce(ctx, fn)
+}
+
+// commandError marks the errors replied by the server, such as redis.Nil or
+// a wrong type, as permanent, except those of a temporarily unavailable server.
+func commandError(err error) error {
+	var redisErr redis.Error
+	if err == nil || !errors.As(err, &redisErr) {
+		return err
+	}
+	for _, prefix := range []string{"LOADING", "BUSY", "TRYAGAIN", "CLUSTERDOWN"} {
+		if redis.HasErrorPrefix(err, prefix) {
+			return err
+		}
+	}
+	return resiliencelib.Permanent(err)
+}
+
+func (h resilienceHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h resilienceHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := h.do(ctx, h.retryable(cmd), func(ctx context.Context) error {
+			return commandError(next(ctx, cmd))
+		})
+		if err != nil && cmd.Err() == nil {
+			cmd.SetErr(err)
+		}
+		return err
+	}
+}
+
+func (h resilienceHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := h.do(ctx, h.retryable(cmds...), func(ctx context.Context) error {
+			return commandError(next(ctx, cmds))
+		})
+		if err != nil {
+			for _, cmd := range cmds {
+				if cmd.Err() == nil {
+					cmd.SetErr(err)
+				}
+			}
+		}
+		return err
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sandrolain/gomsvc/pkg/eventlib"
+	"github.com/sandrolain/gomsvc/pkg/outboxlib"
 	"github.com/sandrolain/gomsvc/pkg/svc"
 	"github.com/vmihailenco/msgpack/v5"
 	"go.jetpack.io/typeid"
@@ -42,24 +43,48 @@ type StreamPublisher[T any] struct {
 }
 
 func (s *StreamPublisher[T]) Publish(payload T) (err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
-	defer cancel()
+	return s.PublishContext(context.Background(), payload)
+}
 
+// PublishContext publishes the payload carrying the trace context of ctx.
+func (s *StreamPublisher[T]) PublishContext(ctx context.Context, payload T) (err error) {
 	t, e := typeid.From(s.stream, "")
 	if e != nil {
 		return svc.Error("cannot generate message id", e)
 	}
+	return s.publish(ctx, t.String(), nil, payload)
+}
+
+// publish adds the message to the stream, with the given headers and the
+// ones carrying the trace context of ctx.
+func (s *StreamPublisher[T]) publish(ctx context.Context, id string, extra map[string]string, payload T) (err error) {
+	ctx, span, headers := startProducerSpan(ctx, s.stream, id)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
 
 	pl, e := msgpack.Marshal(payload)
 	if e != nil {
-		return svc.Error("cannot marshal payload", e)
+		return svc.ErrorContext(ctx, "cannot marshal payload", e)
+	}
+
+	for k, v := range extra {
+		if _, ok := headers[k]; !ok {
+			headers[k] = v
+		}
+	}
+	hdr, e := msgpack.Marshal(headers)
+	if e != nil {
+		return svc.ErrorContext(ctx, "cannot marshal headers", e)
 	}
 
 	values := map[string]interface{}{
 		"tms": time.Now().Format(time.RFC3339Nano),
-		"ids": t.String(),
+		"ids": id,
 		"typ": s.messageType,
 		"ori": s.messageOrigin,
+		"hdr": hdr,
 		"pld": pl,
 	}
 
@@ -71,11 +96,24 @@ func (s *StreamPublisher[T]) Publish(payload T) (err error) {
 	}).Err()
 
 	if e != nil {
-		return svc.Error("cannot publish message", e)
+		return svc.ErrorContext(ctx, "cannot publish message", e)
 	}
 	return
 }
 
+// OutboxPublisher returns the outbox publisher adding each message to the
+// stream, with its JSON payload decoded as T. The message keeps the outbox
+// message ID and headers, so that its retries can be deduplicated.
+func (s *StreamPublisher[T]) OutboxPublisher() outboxlib.Publisher {
+	return outboxlib.PublisherFunc(func(ctx context.Context, msg outboxlib.Message) error {
+		var payload T
+		if err := msg.Decode(&payload); err != nil {
+			return fmt.Errorf("cannot decode outbox payload: %w", err)
+		}
+		return s.publish(ctx, msg.ID, msg.Headers, payload)
+	})
+}
+
 type StreamConsumerConfig struct {
 	Stream   string
 	Group    string
@@ -94,6 +132,12 @@ func NewStreamConsumer[T any](cfg StreamConsumerConfig) (res *StreamConsumer[T],
 		cancel:   cancel,
 		Emitter:  eventlib.NewEmitter[*Message[T]](context.Background(), cfg.Size),
 	}
+	// The processing span ends once the handlers of the message returned
+	res.Emitter.AfterEvent(func(m *Message[T]) {
+		if m.span != nil {
+			m.span.End()
+		}
+	})
 	return
 }
 
@@ -123,67 +167,89 @@ func (s *StreamConsumer[T]) Consume() error {
 		return fmt.Errorf("cannot create group consumer: %w", e)
 	}
 
+	streamMetrics.add(s.stream, s.group)
+
 	go func() {
-		for {
-			if s.ctx.Err() != nil {
-				return
-			}
-			stream, err := redisClient.XReadGroup(s.ctx, &redis.XReadGroupArgs{
-				Group:    s.group,
-				Consumer: s.consumer,
-				Streams:  []string{s.stream, ">"},
-				//count is number of entries we want to read from redis
-				Count: 1,
-				//we use the block command to make sure if no entry is found we wait
-				//until an entry is found
-				Block: 0,
-			}).Result()
-
-			if err != nil {
-				_ = svc.Error("cannot read messages stream",
-					err,
-					"stream", s.stream,
-					"group", s.group,
-					"consumer", s.consumer,
-				)
-				if err != redis.Nil {
-					// Only sleep on real errors, not on empty results
-					time.Sleep(time.Second)
+		defer streamMetrics.remove(s.stream, s.group)
+		svc.Run("redislib.stream", func() {
+			for {
+				if s.ctx.Err() != nil {
+					return
+				}
+				stream, err := redisClient.XReadGroup(s.ctx, &redis.XReadGroupArgs{
+					Group:    s.group,
+					Consumer: s.consumer,
+					Streams:  []string{s.stream, ">"},
+					//count is number of entries we want to read from redis
+					Count: 1,
+					//we use the block command to make sure if no entry is found we wait
+					//until an entry is found
+					Block: 0,
+				}).Result()
+
+				if err != nil {
+					_ = svc.Error("cannot read messages stream",
+						err,
+						"stream", s.stream,
+						"group", s.group,
+						"consumer", s.consumer,
+					)
+					if err != redis.Nil {
+						// Only sleep on real errors, not on empty results
+						time.Sleep(time.Second)
+					}
+					continue
 				}
-				continue
-			}
 
-			///we have received the data we should loop it and queue the messages
-			//so that our jobs can start processing
-			for _, item := range stream {
-				for _, msg := range item.Messages {
-					message, err := parseStreamMessage[T](&msg)
-					if err != nil {
-						svc.Logger().Error("cannot parse message",
-							"error", err,
-							"message_id", msg.ID,
-							"stream", s.stream,
-						)
-						// Acknowledge the message even if we can't parse it
-						// to prevent endless retry of unparseable messages
-						if ackErr := redisClient.XAck(s.ctx, s.stream, s.group, msg.ID).Err(); ackErr != nil {
-							svc.Logger().Error("failed to acknowledge unparseable message",
-								"error", ackErr,
-								"original_error", err,
+				///we have received the data we should loop it and queue the messages
+				//so that our jobs can start processing
+				for _, item := range stream {
+					for _, msg := range item.Messages {
+						message, err := parseStreamMessage[T](&msg)
+						if err != nil {
+							svc.Logger().Error("cannot parse message",
+								"error", err,
 								"message_id", msg.ID,
 								"stream", s.stream,
 							)
+							// Acknowledge the message even if we can't parse it
+							// to prevent endless retry of unparseable messages
+							if ackErr := redisClient.XAck(s.ctx, s.stream, s.group, msg.ID).Err(); ackErr != nil {
+								svc.Logger().Error("failed to acknowledge unparseable message",
+									"error", ackErr,
+									"original_error", err,
+									"message_id", msg.ID,
+									"stream", s.stream,
+								)
+							}
+							continue
 						}
-						continue
+						message.ctx, message.span = startConsumerSpan(message.Headers, s.stream, message.Id)
+						s.Emitter.Emit(message)
 					}
-					s.Emitter.Emit(message)
 				}
 			}
-		}
+		})
 	}()
 	return nil
 }
 
+// Component returns the svc lifecycle component consuming the stream.
+func (s *StreamConsumer[T]) Component(name string, dependsOn ...string) svc.Component {
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			return s.Consume()
+		},
+		Stop: func(ctx context.Context) error {
+			s.Cancel()
+			s.Emitter.End()
+			return nil
+		},
+	}
+}
+
 func parseStreamMessage[T any](msg *redis.XMessage) (res *Message[T], err error) {
 	pldBytes, err := getValue(msg, "pld")
 	if err != nil {
@@ -204,11 +270,17 @@ func parseStreamMessage[T any](msg *redis.XMessage) (res *Message[T], err error)
 
 	timestamp, _ := time.Parse(time.RFC3339Nano, ts)
 
+	var headers map[string]string
+	if hdr, e := getValue(msg, "hdr"); e == nil {
+		_ = msgpack.Unmarshal([]byte(hdr), &headers)
+	}
+
 	res = &Message[T]{
 		Id:        id,
 		Timestamp: timestamp,
 		Type:      ty,
 		Origin:    or,
+		Headers:   headers,
 		Payload:   pld,
 	}
 
@@ -0,0 +1,81 @@
+package redislib
+
+import (
+	"context"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/sandrolain/gomsvc/pkg/redislib"
+
+// requestIDHeader carries the request ID of the publisher context,
+// so that the consumer logs correlate to the originating request.
+const requestIDHeader = "x-request-id"
+
+var (
+	sentMessages, _ = otel.Meter(instrumentationName).Int64Counter(
+		"messaging.client.sent.messages",
+		metric.WithDescription("Number of messages published to Redis."),
+	)
+	consumedMessages, _ = otel.Meter(instrumentationName).Int64Counter(
+		"messaging.client.consumed.messages",
+		metric.WithDescription("Number of messages received from Redis."),
+	)
+)
+
+func messagingAttributes(operation string, destination string, id string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String("redis"),
+		semconv.MessagingOperationName(operation),
+		semconv.MessagingDestinationName(destination),
+		semconv.MessagingMessageID(id),
+	}
+}
+
+// startProducerSpan starts the span of a published message and returns
+// the headers carrying its trace context.
+func startProducerSpan(ctx context.Context, destination string, id string) (context.Context, trace.Span, map[string]string) {
+	attrs := messagingAttributes("send", destination, id)
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "send "+destination,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+	)
+	headers := map[string]string{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	if reqID := svc.RequestID(ctx); reqID != "" {
+		headers[requestIDHeader] = reqID
+	}
+	sentMessages.Add(ctx, 1, metric.WithAttributes(attrs[:3]...))
+	return ctx, span, headers
+}
+
+// startConsumerSpan starts the span of a received message, continuing the
+// trace carried by its headers.
+func startConsumerSpan(headers map[string]string, destination string, id string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(headers))
+	if reqID := headers[requestIDHeader]; reqID != "" {
+		ctx = svc.WithRequestID(ctx, reqID)
+	}
+	attrs := messagingAttributes("process", destination, id)
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "process "+destination,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+	)
+	consumedMessages.Add(ctx, 1, metric.WithAttributes(attrs[:3]...))
+	return ctx, span
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,64 @@
+package redislib
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSpanPropagation(t *testing.T) {
+	tt := svc.InitTestTelemetry()
+	defer tt.Shutdown()
+
+	ctx := svc.WithRequestID(context.Background(), "req-1")
+	_, producer, headers := startProducerSpan(ctx, "orders", "1")
+	endSpan(producer, nil)
+	require.Equal(t, "req-1", headers[requestIDHeader])
+
+	ctx, consumer := startConsumerSpan(headers, "orders", "1")
+	consumer.End()
+	require.Equal(t, "req-1", svc.RequestID(ctx))
+
+	spans := tt.Spans.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "send orders", spans[0].Name)
+	require.Equal(t, trace.SpanKindProducer, spans[0].SpanKind)
+	require.Equal(t, "process orders", spans[1].Name)
+	require.Equal(t, trace.SpanKindConsumer, spans[1].SpanKind)
+	require.Equal(t, spans[0].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+	require.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+}
+
+func TestStreamConsumerSpanCoversHandler(t *testing.T) {
+	tt := svc.InitTestTelemetry()
+	defer tt.Shutdown()
+
+	consumer, err := NewStreamConsumer[string](StreamConsumerConfig{Stream: "orders", Size: 1})
+	require.NoError(t, err)
+	defer consumer.Emitter.End()
+
+	release := make(chan struct{})
+	handled := make(chan struct{})
+	consumer.Emitter.Subscribe(func(msg *Message[string]) error {
+		<-release
+		return nil
+	}, nil)
+	consumer.Emitter.Subscribe(func(msg *Message[string]) error {
+		close(handled)
+		return nil
+	}, nil)
+
+	msg := &Message[string]{Id: "1", Payload: "x"}
+	msg.ctx, msg.span = startConsumerSpan(nil, "orders", msg.Id)
+	consumer.Emitter.Emit(msg)
+
+	time.Sleep(10 * time.Millisecond)
+	require.Empty(t, tt.Spans.GetSpans(), "the span is open while the handlers run")
+	close(release)
+	<-handled
+	require.Eventually(t, func() bool { return len(tt.Spans.GetSpans()) == 1 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,38 @@
+package resiliencelib
+
+import "errors"
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not caused by a failure of the dependency, such as
+// an invalid request: it is neither retried nor counted by the circuit breaker.
+// The policy returns the original error.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+func unwrapPermanent(err error) error {
+	var p *permanentError
+	if errors.As(err, &p) && err == error(p) {
+		return p.err
+	}
+	return err
+}
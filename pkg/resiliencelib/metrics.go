@@ -0,0 +1,26 @@
+package resiliencelib
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+var (
+	callsTotal = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "resilience_calls_total",
+		Help: "Total number of calls through the resilience policies, by outcome.",
+	}, []string{"policy", "outcome"}))
+	retriesTotal = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "resilience_retries_total",
+		Help: "Total number of retried calls.",
+	}, []string{"policy"}))
+	hedgesTotal = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "resilience_hedges_total",
+		Help: "Total number of hedged calls sent.",
+	}, []string{"policy"}))
+	breakerState = svc.RegisterMetric(svc.NewGaugeSet(
+		"resilience_breaker_state",
+		"State of the circuit breaker: 0 closed, 1 open, 2 half-open.",
+		"policy",
+	))
+)
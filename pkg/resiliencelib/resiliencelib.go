@@ -0,0 +1,433 @@
+// Package resiliencelib provides resilience policies for the calls to the
+// dependencies of a service: retries with exponential backoff and jitter,
+// circuit breaker, bulkhead, rate limiter and hedged requests.
+//
+// A Policy is created for each dependency and shared by all its calls, so
+// that the breaker, the bulkhead and the rate limiter see the whole traffic.
+// The clients of gomsvc accept a Policy in their options.
+package resiliencelib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/eapache/go-resiliency/breaker"
+	"github.com/eapache/go-resiliency/retrier"
+	"github.com/eapache/go-resiliency/semaphore"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrBreakerOpen is returned without calling the dependency when its circuit breaker is open.
+	ErrBreakerOpen = breaker.ErrBreakerOpen
+	// ErrBulkheadFull is returned when no concurrent call slot is freed within MaxWait.
+	ErrBulkheadFull = semaphore.ErrNoTickets
+	// ErrRateLimited is returned when the rate limit does not allow the call within MaxWait.
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+const (
+	DefaultRetryBackoff     = 100 * time.Millisecond
+	DefaultRetryMaxBackoff  = 10 * time.Second
+	DefaultBreakerSuccesses = 1
+	DefaultBreakerTimeout   = 30 * time.Second
+)
+
+// Config configures a Policy. Each mechanism is disabled when left to its zero value.
+type Config struct {
+	// Retries is the number of times a failed call is retried.
+	Retries int
+	// RetryBackoff is the delay before the first retry, doubled at each following one up to RetryMaxBackoff.
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// RetryJitter randomizes each delay by up to this fraction of it, between 0 and 1.
+	RetryJitter float64
+	// Retryable reports whether a failed call is retried. By default every
+	// error is, except the Permanent ones, the policy rejections and the
+	// context errors.
+	Retryable func(err error) bool
+
+	// BreakerErrors is the number of errors, without an error-free period of
+	// BreakerTimeout, opening the circuit breaker.
+	BreakerErrors int
+	// BreakerSuccesses is the number of consecutive successes closing the half-open breaker.
+	BreakerSuccesses int
+	// BreakerTimeout is also the time the breaker stays open before letting a call through.
+	BreakerTimeout time.Duration
+
+	// MaxConcurrent is the maximum number of concurrent calls.
+	MaxConcurrent int
+	// RateLimit is the maximum number of calls per second, with bursts of RateBurst.
+	RateLimit float64
+	RateBurst int
+	// MaxWait is how long a call waits for the bulkhead or the rate limiter before being rejected.
+	MaxWait time.Duration
+
+	// HedgeDelay is the delay after which a hedged call is sent again if no
+	// response was received yet, up to MaxHedges times.
+	HedgeDelay time.Duration
+	MaxHedges  int
+}
+
+// EnvConfig is the environment configuration of a Policy, meant to be
+// embedded with an envPrefix for each dependency.
+type EnvConfig struct {
+	Retries          int           `env:"RESILIENCE_RETRIES" validate:"min=0"`
+	RetryBackoff     time.Duration `env:"RESILIENCE_RETRY_BACKOFF"`
+	RetryMaxBackoff  time.Duration `env:"RESILIENCE_RETRY_MAX_BACKOFF"`
+	RetryJitter      float64       `env:"RESILIENCE_RETRY_JITTER" validate:"min=0,max=1"`
+	BreakerErrors    int           `env:"RESILIENCE_BREAKER_ERRORS" validate:"min=0"`
+	BreakerSuccesses int           `env:"RESILIENCE_BREAKER_SUCCESSES" validate:"min=0"`
+	BreakerTimeout   time.Duration `env:"RESILIENCE_BREAKER_TIMEOUT"`
+	MaxConcurrent    int           `env:"RESILIENCE_MAX_CONCURRENT" validate:"min=0"`
+	RateLimit        float64       `env:"RESILIENCE_RATE_LIMIT" validate:"min=0"`
+	RateBurst        int           `env:"RESILIENCE_RATE_BURST" validate:"min=0"`
+	MaxWait          time.Duration `env:"RESILIENCE_MAX_WAIT"`
+	HedgeDelay       time.Duration `env:"RESILIENCE_HEDGE_DELAY"`
+	MaxHedges        int           `env:"RESILIENCE_MAX_HEDGES" validate:"min=0"`
+}
+
+func FromEnvConfig(cfg EnvConfig) Config {
+	return Config{
+		Retries:          cfg.Retries,
+		RetryBackoff:     cfg.RetryBackoff,
+		RetryMaxBackoff:  cfg.RetryMaxBackoff,
+		RetryJitter:      cfg.RetryJitter,
+		BreakerErrors:    cfg.BreakerErrors,
+		BreakerSuccesses: cfg.BreakerSuccesses,
+		BreakerTimeout:   cfg.BreakerTimeout,
+		MaxConcurrent:    cfg.MaxConcurrent,
+		RateLimit:        cfg.RateLimit,
+		RateBurst:        cfg.RateBurst,
+		MaxWait:          cfg.MaxWait,
+		HedgeDelay:       cfg.HedgeDelay,
+		MaxHedges:        cfg.MaxHedges,
+	}
+}
+
+// Policy applies the configured resilience mechanisms to the calls of a dependency.
+// A nil Policy runs the calls as they are.
+type Policy struct {
+	name      string
+	cfg       Config
+	retrier   *retrier.Retrier
+	breaker   *breaker.Breaker
+	bulkhead  *semaphore.Semaphore
+	limiter   *rate.Limiter
+	lastState atomic.Uint32
+}
+
+// New creates the policy of the named dependency. The name labels its logs and metrics.
+func New(name string, cfg Config) *Policy {
+	p := &Policy{name: name, cfg: cfg}
+
+	if cfg.Retries > 0 {
+		if cfg.RetryBackoff <= 0 {
+			cfg.RetryBackoff = DefaultRetryBackoff
+		}
+		if cfg.RetryMaxBackoff <= 0 {
+			cfg.RetryMaxBackoff = DefaultRetryMaxBackoff
+		}
+		p.retrier = retrier.New(
+			retrier.LimitedExponentialBackoff(cfg.Retries, cfg.RetryBackoff, cfg.RetryMaxBackoff),
+			classifier(p.retryable),
+		).WithSurfaceWorkErrors()
+		p.retrier.SetJitter(cfg.RetryJitter)
+	}
+
+	if cfg.BreakerErrors > 0 {
+		if cfg.BreakerSuccesses <= 0 {
+			cfg.BreakerSuccesses = DefaultBreakerSuccesses
+		}
+		if cfg.BreakerTimeout <= 0 {
+			cfg.BreakerTimeout = DefaultBreakerTimeout
+		}
+		p.breaker = breaker.New(cfg.BreakerErrors, cfg.BreakerSuccesses, cfg.BreakerTimeout)
+		breakerState.Set(name, func() float64 {
+			return float64(p.breaker.GetState())
+		})
+	}
+
+	if cfg.MaxConcurrent > 0 {
+		p.bulkhead = semaphore.New(cfg.MaxConcurrent, cfg.MaxWait)
+	}
+
+	if cfg.RateLimit > 0 {
+		p.limiter = rate.NewLimiter(rate.Limit(cfg.RateLimit), max(cfg.RateBurst, 1))
+	}
+
+	p.cfg = cfg
+	return p
+}
+
+// Name returns the name of the dependency of the policy.
+func (p *Policy) Name() string {
+	if p == nil {
+		return ""
+	}
+	return p.name
+}
+
+// Do runs fn through the policy.
+func (p *Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := Execute(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// DoOnce runs fn through the policy without retrying it, for the calls
+// that are not idempotent.
+func (p *Policy) DoOnce(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := ExecuteOnce(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Execute runs fn through the policy, returning its result.
+func Execute[T any](ctx context.Context, p *Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	return execute(ctx, p, fn, true, false)
+}
+
+// ExecuteOnce is like Execute, without retrying the call.
+func ExecuteOnce[T any](ctx context.Context, p *Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	return execute(ctx, p, fn, false, false)
+}
+
+// Hedged is like Execute, also sending the call again after HedgeDelay while
+// no response is received, and returning the first successful response.
+// fn must be idempotent and safe to run concurrently.
+func Hedged[T any](ctx context.Context, p *Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	return execute(ctx, p, fn, true, true)
+}
+
+func execute[T any](ctx context.Context, p *Policy, fn func(ctx context.Context) (T, error), retry bool, hedged bool) (res T, err error) {
+	if p == nil {
+		res, err = fn(ctx)
+		return res, unwrapPermanent(err)
+	}
+
+	attempt := func(ctx context.Context, retries int) error {
+		if retries > 0 {
+			retriesTotal.WithLabelValues(p.name).Inc()
+		}
+		var e error
+		res, e = attemptCall(ctx, p, fn, hedged)
+		return e
+	}
+
+	if p.retrier != nil && retry {
+		err = p.retrier.RunFn(ctx, attempt)
+	} else {
+		err = attempt(ctx, 0)
+	}
+
+	callsTotal.WithLabelValues(p.name, outcome(err)).Inc()
+	return res, unwrapPermanent(err)
+}
+
+// attemptCall runs a single attempt of the call through the rate limiter,
+// the bulkhead and the circuit breaker. The rejections of the bulkhead are
+// not failures of the dependency, and do not reach the breaker.
+func attemptCall[T any](ctx context.Context, p *Policy, fn func(ctx context.Context) (T, error), hedged bool) (res T, err error) {
+	if err = p.wait(ctx); err != nil {
+		return
+	}
+	if err = p.acquire(); err != nil {
+		return
+	}
+
+	// The slot is released by the call, or here when the breaker is open
+	started := false
+	defer func() {
+		if !started {
+			p.release()
+		}
+	}()
+	call := func() error {
+		started = true
+		if hedged && p.cfg.HedgeDelay > 0 && p.cfg.MaxHedges > 0 {
+			res, err = hedge(ctx, p, fn)
+			return err
+		}
+		defer p.release()
+		res, err = fn(ctx)
+		return err
+	}
+
+	if p.breaker == nil {
+		err = call()
+		return
+	}
+
+	// Permanent errors are not failures of the dependency and must not open the breaker
+	berr := p.breaker.Run(func() error {
+		if e := call(); e != nil && !isPermanent(e) {
+			return e
+		}
+		return nil
+	})
+	p.logStateChange()
+	if berr != nil {
+		err = berr
+	}
+	return
+}
+
+// wait waits for the rate limiter to allow the call.
+func (p *Policy) wait(ctx context.Context) error {
+	if p.limiter == nil {
+		return nil
+	}
+	if p.cfg.MaxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.MaxWait)
+		defer cancel()
+	}
+	if err := p.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrRateLimited, err)
+	}
+	return nil
+}
+
+// acquire takes a concurrent call slot of the bulkhead.
+func (p *Policy) acquire() error {
+	if p.bulkhead == nil {
+		return nil
+	}
+	return p.bulkhead.Acquire()
+}
+
+func (p *Policy) release() {
+	if p.bulkhead != nil {
+		p.bulkhead.Release()
+	}
+}
+
+// hedge runs fn, starting another run after each HedgeDelay without a
+// response, up to MaxHedges. The first run releases the bulkhead slot taken
+// by attemptCall, each hedge takes its own, and the first success cancels
+// the other runs.
+func hedge[T any](ctx context.Context, p *Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		value T
+		err   error
+	}
+	results := make(chan result, p.cfg.MaxHedges+1)
+	run := func(acquire bool) {
+		if acquire {
+			if err := p.acquire(); err != nil {
+				results <- result{err: err}
+				return
+			}
+		}
+		defer p.release()
+		value, err := fn(ctx)
+		results <- result{value, err}
+	}
+
+	go run(false)
+	running, hedges := 1, 0
+	timer := time.NewTimer(p.cfg.HedgeDelay)
+	defer timer.Stop()
+
+	var last result
+	for running > 0 {
+		select {
+		case r := <-results:
+			running--
+			if r.err == nil {
+				return r.value, nil
+			}
+			// A rejected hedge does not hide the error of another run
+			if last.err == nil || !isRejection(r.err) {
+				last = r
+			}
+		case <-timer.C:
+			if hedges == p.cfg.MaxHedges {
+				continue
+			}
+			hedgesTotal.WithLabelValues(p.name).Inc()
+			hedges++
+			running++
+			go run(true)
+			timer.Reset(p.cfg.HedgeDelay)
+		}
+	}
+	return last.value, last.err
+}
+
+// logStateChange logs the transitions of the circuit breaker seen since the previous call.
+func (p *Policy) logStateChange() {
+	state := uint32(p.breaker.GetState())
+	prev := p.lastState.Swap(state)
+	if prev == state {
+		return
+	}
+	log := svc.Logger().With("policy", p.name, "from", stateName(prev), "to", stateName(state))
+	if breaker.State(state) == breaker.Open {
+		log.Warn("Circuit breaker opened")
+	} else {
+		log.Info("Circuit breaker state changed")
+	}
+}
+
+func stateName(state uint32) string {
+	switch breaker.State(state) {
+	case breaker.Closed:
+		return "closed"
+	case breaker.Open:
+		return "open"
+	case breaker.HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+func (p *Policy) retryable(err error) bool {
+	if isPermanent(err) || isRejection(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if p.cfg.Retryable != nil {
+		return p.cfg.Retryable(err)
+	}
+	return true
+}
+
+type classifier func(err error) bool
+
+func (c classifier) Classify(err error) retrier.Action {
+	if err == nil {
+		return retrier.Succeed
+	}
+	if c(err) {
+		return retrier.Retry
+	}
+	return retrier.Fail
+}
+
+func isRejection(err error) bool {
+	return errors.Is(err, ErrBreakerOpen) || errors.Is(err, ErrBulkheadFull) || errors.Is(err, ErrRateLimited)
+}
+
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrBreakerOpen):
+		return "breaker_open"
+	case errors.Is(err, ErrBulkheadFull):
+		return "bulkhead_full"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	}
+	return "failure"
+}
@@ -0,0 +1,191 @@
+package resiliencelib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errFailed = errors.New("failed")
+
+func TestNilPolicy(t *testing.T) {
+	var p *Policy
+	res, err := Execute(context.Background(), p, func(ctx context.Context) (int, error) { return 1, nil })
+	require.NoError(t, err)
+	require.Equal(t, 1, res)
+
+	err = p.Do(context.Background(), func(ctx context.Context) error { return Permanent(errFailed) })
+	require.Equal(t, errFailed, err)
+}
+
+func TestRetries(t *testing.T) {
+	p := New("retries", Config{Retries: 3, RetryBackoff: time.Millisecond, RetryJitter: 0.5})
+
+	var calls atomic.Int32
+	res, err := Execute(context.Background(), p, func(ctx context.Context) (int, error) {
+		if calls.Add(1) < 3 {
+			return 0, errFailed
+		}
+		return 42, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 42, res)
+	require.Equal(t, int32(3), calls.Load())
+
+	calls.Store(0)
+	err = p.Do(context.Background(), func(ctx context.Context) error {
+		calls.Add(1)
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+	require.Equal(t, int32(4), calls.Load())
+
+	calls.Store(0)
+	err = p.Do(context.Background(), func(ctx context.Context) error {
+		calls.Add(1)
+		return Permanent(errFailed)
+	})
+	require.Equal(t, errFailed, err)
+	require.Equal(t, int32(1), calls.Load())
+
+	calls.Store(0)
+	err = p.DoOnce(context.Background(), func(ctx context.Context) error {
+		calls.Add(1)
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+	require.Equal(t, int32(1), calls.Load(), "DoOnce does not retry")
+}
+
+func TestBreaker(t *testing.T) {
+	p := New("breaker", Config{BreakerErrors: 2, BreakerTimeout: 50 * time.Millisecond})
+	failing := func(ctx context.Context) error { return errFailed }
+
+	require.ErrorIs(t, p.Do(context.Background(), func(ctx context.Context) error { return Permanent(errFailed) }), errFailed)
+	require.ErrorIs(t, p.Do(context.Background(), failing), errFailed)
+	require.ErrorIs(t, p.Do(context.Background(), failing), errFailed)
+
+	called := false
+	err := p.Do(context.Background(), func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	require.ErrorIs(t, err, ErrBreakerOpen)
+	require.False(t, called)
+
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, p.Do(context.Background(), func(ctx context.Context) error { return nil }))
+	require.Equal(t, "closed", stateName(p.lastState.Load()))
+}
+
+func TestBulkhead(t *testing.T) {
+	p := New("bulkhead", Config{MaxConcurrent: 2, MaxWait: 10 * time.Millisecond})
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = p.Do(context.Background(), func(ctx context.Context) error {
+				<-release
+				return nil
+			})
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return errors.Is(p.Do(context.Background(), func(ctx context.Context) error { return nil }), ErrBulkheadFull)
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestBulkheadWithBreaker(t *testing.T) {
+	p := New("bulkhead-breaker", Config{MaxConcurrent: 1, MaxWait: time.Millisecond, BreakerErrors: 1})
+
+	release := make(chan struct{})
+	held := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = p.Do(context.Background(), func(ctx context.Context) error {
+			close(held)
+			<-release
+			return nil
+		})
+	}()
+	<-held
+
+	called := false
+	_, err := Execute(context.Background(), p, func(ctx context.Context) (int, error) {
+		called = true
+		return 1, nil
+	})
+	require.ErrorIs(t, err, ErrBulkheadFull)
+	require.False(t, called)
+
+	close(release)
+	<-done
+	res, err := Execute(context.Background(), p, func(ctx context.Context) (int, error) { return 1, nil })
+	require.NoError(t, err, "the bulkhead rejection does not open the breaker")
+	require.Equal(t, 1, res)
+}
+
+func TestRateLimit(t *testing.T) {
+	p := New("ratelimit", Config{RateLimit: 1, RateBurst: 1, MaxWait: 10 * time.Millisecond})
+	ok := func(ctx context.Context) error { return nil }
+
+	require.NoError(t, p.Do(context.Background(), ok))
+	require.ErrorIs(t, p.Do(context.Background(), ok), ErrRateLimited)
+}
+
+func TestHedged(t *testing.T) {
+	p := New("hedged", Config{HedgeDelay: 10 * time.Millisecond, MaxHedges: 2})
+
+	var calls atomic.Int32
+	res, err := Hedged(context.Background(), p, func(ctx context.Context) (int, error) {
+		n := calls.Add(1)
+		if n == 1 {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return int(n), nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, res)
+
+	calls.Store(0)
+	_, err = Hedged(context.Background(), p, func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		time.Sleep(30 * time.Millisecond)
+		return 0, errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+	require.Equal(t, int32(3), calls.Load())
+}
+
+func TestHedgedBulkhead(t *testing.T) {
+	p := New("hedged-bulkhead", Config{HedgeDelay: 5 * time.Millisecond, MaxHedges: 2, MaxConcurrent: 2, MaxWait: time.Millisecond})
+
+	var running, peak, calls atomic.Int32
+	_, err := Hedged(context.Background(), p, func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			if old := peak.Load(); n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		return 0, errFailed
+	})
+	require.ErrorIs(t, err, errFailed, "the rejected hedge does not hide the call error")
+	require.Equal(t, int32(2), peak.Load(), "each hedge takes a bulkhead slot")
+	require.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,369 @@
+package sagalib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sandrolain/gomsvc/pkg/svc"
+)
+
+const (
+	DefaultStepTimeout   = time.Minute
+	DefaultInterval      = time.Second
+	DefaultBatchSize     = 100
+	DefaultRetryInterval = 10 * time.Second
+	DefaultMaxAttempts   = 10
+)
+
+var (
+	stepsTotal = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "saga_steps_total",
+		Help: "Total number of saga steps, by outcome.",
+	}, []string{"saga", "step", "outcome"}))
+	finishedTotal = svc.RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "saga_finished_total",
+		Help: "Total number of sagas reaching a final status.",
+	}, []string{"saga", "status"}))
+)
+
+// Step is a step of a saga on the data T. The actions and the compensations
+// can run more than once, after a restart or a timeout, so they must be
+// idempotent, as the commands they send.
+type Step[T any] struct {
+	Name string
+	// Action executes the step, or sends its command when the step waits
+	// for a reply. The changes to data are persisted.
+	Action func(ctx context.Context, id string, data *T) error
+	// Compensate undoes the step, optional.
+	Compensate func(ctx context.Context, id string, data *T) error
+	// Reply makes the step wait, after Action, for Complete or Fail.
+	Reply bool
+	// Timeout is the maximum duration of the step, DefaultStepTimeout if zero.
+	// A timed out step is compensated too, as its outcome is unknown.
+	Timeout time.Duration
+}
+
+// Definition is a saga on the data T, with its steps run in order.
+type Definition[T any] struct {
+	Name  string
+	Steps []Step[T]
+}
+
+type Options struct {
+	// Interval is the delay between the checks of the expired deadlines.
+	Interval time.Duration
+	// BatchSize is the maximum number of expired sagas handled at each check.
+	BatchSize int
+	// RetryInterval is the delay before retrying a failed compensation.
+	RetryInterval time.Duration
+	// MaxAttempts is the number of failed attempts of a compensation after
+	// which the saga is marked as failed.
+	MaxAttempts int
+}
+
+// Orchestrator runs the sagas of a definition.
+type Orchestrator[T any] struct {
+	store Store
+	def   Definition[T]
+	opts  Options
+}
+
+// New creates the orchestrator of the sagas of def stored in store.
+func New[T any](store Store, def Definition[T], opts Options) *Orchestrator[T] {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultRetryInterval
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	return &Orchestrator[T]{store: store, def: def, opts: opts}
+}
+
+// Begin creates the saga with the given ID and runs its steps until one
+// waits for a reply. An error of a step starts the compensation and is not
+// returned: the outcome is reported by the returned state.
+func (o *Orchestrator[T]) Begin(ctx context.Context, id string, data T) (*State, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal saga data: %w", err)
+	}
+	now := time.Now()
+	st := &State{
+		ID:        id,
+		Saga:      o.def.Name,
+		Status:    StatusRunning,
+		Data:      raw,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := o.store.Create(ctx, st); err != nil {
+		return nil, err
+	}
+	svc.Logger().DebugContext(ctx, "Saga started", "saga", o.def.Name, "id", id)
+	return st, o.advance(ctx, st, &data)
+}
+
+// Complete completes the step waiting for a reply, applying the reply to
+// the saga data with apply, and runs the following steps. It returns
+// ErrUnexpectedStep if the saga is not waiting for the step, as for the
+// duplicated replies.
+func (o *Orchestrator[T]) Complete(ctx context.Context, id string, step string, apply func(data *T) error) error {
+	st, data, err := o.waiting(ctx, id, step)
+	if err != nil {
+		return err
+	}
+	if apply != nil {
+		if err := apply(&data); err != nil {
+			return err
+		}
+	}
+	stepsTotal.WithLabelValues(o.def.Name, step, "completed").Inc()
+	st.Step++
+	return o.advance(ctx, st, &data)
+}
+
+// Fail fails the step waiting for a reply, compensating the previous steps.
+func (o *Orchestrator[T]) Fail(ctx context.Context, id string, step string, cause error) error {
+	st, data, err := o.waiting(ctx, id, step)
+	if err != nil {
+		return err
+	}
+	stepsTotal.WithLabelValues(o.def.Name, step, "failed").Inc()
+	return o.compensate(ctx, st, &data, fmt.Errorf("step %s: %w", step, cause))
+}
+
+// Status returns the state of the saga with the given ID.
+func (o *Orchestrator[T]) Status(ctx context.Context, id string) (*State, error) {
+	return o.store.Get(ctx, id)
+}
+
+// CheckTimeouts handles the sagas with an expired deadline, compensating
+// the timed out steps and retrying the failed compensations. It returns the
+// number of sagas handled.
+func (o *Orchestrator[T]) CheckTimeouts(ctx context.Context) (int, error) {
+	states, err := o.store.Expired(ctx, o.def.Name, time.Now(), o.opts.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, st := range states {
+		data, err := o.decode(st)
+		if err == nil {
+			switch st.Status {
+			case StatusRunning:
+				stepsTotal.WithLabelValues(o.def.Name, st.StepName, "timeout").Inc()
+				cause := fmt.Errorf("%w: %s", ErrStepTimeout, st.StepName)
+				st.Step++
+				err = o.compensate(ctx, st, &data, cause)
+			case StatusCompensating:
+				err = o.rollback(ctx, st, &data)
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, ErrConflict) && ctx.Err() == nil {
+				svc.Logger().WarnContext(ctx, "Cannot handle expired saga", "saga", o.def.Name, "id", st.ID, "error", err)
+			}
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Run checks the expired deadlines until ctx is done. Multiple instances
+// can run concurrently, the conflicting updates being discarded.
+func (o *Orchestrator[T]) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := o.CheckTimeouts(ctx)
+		if err != nil && ctx.Err() == nil {
+			svc.Logger().Warn("Cannot check the saga timeouts", "saga", o.def.Name, "error", err)
+		}
+		// A full batch means that more sagas may be expired
+		if n == o.opts.BatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Component returns the svc lifecycle component running the timeout checks.
+func (o *Orchestrator[T]) Component(name string, dependsOn ...string) svc.Component {
+	var cancel context.CancelFunc
+	var done chan struct{}
+
+	return svc.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				svc.Run("sagalib.orchestrator", func() { o.Run(runCtx) })
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// waiting loads the saga, checking that it is waiting for the reply of step.
+func (o *Orchestrator[T]) waiting(ctx context.Context, id string, step string) (*State, T, error) {
+	var data T
+	st, err := o.store.Get(ctx, id)
+	if err != nil {
+		return nil, data, err
+	}
+	if st.Status != StatusRunning || st.StepName != step ||
+		st.Step >= len(o.def.Steps) || !o.def.Steps[st.Step].Reply {
+		return nil, data, fmt.Errorf("%w: %s is %s at %s", ErrUnexpectedStep, id, st.Status, st.StepName)
+	}
+	data, err = o.decode(st)
+	return st, data, err
+}
+
+func (o *Orchestrator[T]) decode(st *State) (T, error) {
+	var data T
+	if st.Step > len(o.def.Steps) {
+		return data, fmt.Errorf("saga %s is at unknown step %d", st.ID, st.Step)
+	}
+	if err := st.Decode(&data); err != nil {
+		return data, fmt.Errorf("cannot unmarshal saga data: %w", err)
+	}
+	return data, nil
+}
+
+// save persists the state with the data.
+func (o *Orchestrator[T]) save(ctx context.Context, st *State, data *T) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("cannot marshal saga data: %w", err)
+	}
+	st.Data = raw
+	st.UpdatedAt = time.Now()
+	return o.store.Update(ctx, st)
+}
+
+// advance runs the steps of a running saga until one waits for a reply.
+// The state is saved with the step deadline before running each action,
+// so that a step interrupted by a crash times out.
+func (o *Orchestrator[T]) advance(ctx context.Context, st *State, data *T) error {
+	for st.Status == StatusRunning {
+		if st.Step >= len(o.def.Steps) {
+			return o.finish(ctx, st, data, StatusCompleted)
+		}
+
+		step := o.def.Steps[st.Step]
+		timeout := step.Timeout
+		if timeout <= 0 {
+			timeout = DefaultStepTimeout
+		}
+		deadline := time.Now().Add(timeout)
+		st.StepName = step.Name
+		st.Deadline = &deadline
+		if err := o.save(ctx, st, data); err != nil {
+			return err
+		}
+
+		if step.Action != nil {
+			if err := step.Action(ctx, st.ID, data); err != nil {
+				stepsTotal.WithLabelValues(o.def.Name, step.Name, "failed").Inc()
+				return o.compensate(ctx, st, data, fmt.Errorf("step %s: %w", step.Name, err))
+			}
+		}
+		if step.Reply {
+			return o.save(ctx, st, data)
+		}
+		stepsTotal.WithLabelValues(o.def.Name, step.Name, "completed").Inc()
+		st.Step++
+	}
+	return nil
+}
+
+// compensate starts the compensation of the steps before st.Step.
+func (o *Orchestrator[T]) compensate(ctx context.Context, st *State, data *T, cause error) error {
+	svc.Logger().WarnContext(ctx, "Saga compensating", "saga", o.def.Name, "id", st.ID, "error", cause)
+	st.Status = StatusCompensating
+	st.Error = cause.Error()
+	st.Attempts = 0
+	return o.rollback(ctx, st, data)
+}
+
+// rollback runs the compensations in reverse order. A failed compensation
+// is retried after the retry interval by CheckTimeouts.
+func (o *Orchestrator[T]) rollback(ctx context.Context, st *State, data *T) error {
+	for st.Step > 0 {
+		step := o.def.Steps[st.Step-1]
+		deadline := time.Now().Add(o.opts.RetryInterval)
+		st.StepName = step.Name
+		st.Deadline = &deadline
+		if err := o.save(ctx, st, data); err != nil {
+			return err
+		}
+
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, st.ID, data); err != nil {
+				st.Attempts++
+				svc.Logger().WarnContext(ctx, "Cannot compensate saga step",
+					"saga", o.def.Name, "id", st.ID, "step", step.Name, "attempts", st.Attempts, "error", err)
+				if st.Attempts >= o.opts.MaxAttempts {
+					return o.finish(ctx, st, data, StatusFailed)
+				}
+				return o.save(ctx, st, data)
+			}
+		}
+		stepsTotal.WithLabelValues(o.def.Name, step.Name, "compensated").Inc()
+		st.Step--
+		st.Attempts = 0
+	}
+	return o.finish(ctx, st, data, StatusCompensated)
+}
+
+func (o *Orchestrator[T]) finish(ctx context.Context, st *State, data *T, status Status) error {
+	st.Status = status
+	st.Deadline = nil
+	if status != StatusFailed {
+		st.StepName = ""
+	}
+	if err := o.save(ctx, st, data); err != nil {
+		return err
+	}
+	finishedTotal.WithLabelValues(o.def.Name, string(status)).Inc()
+	if status == StatusFailed {
+		svc.Logger().ErrorContext(ctx, "Saga compensation failed", "saga", o.def.Name, "id", st.ID, "step", st.StepName)
+	} else {
+		svc.Logger().DebugContext(ctx, "Saga finished", "saga", o.def.Name, "id", st.ID, "status", status)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package sagalib
+
+import (
+	"context"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/sandrolain/gomsvc/pkg/outboxlib"
+)
+
+// HeaderSagaID is the header carrying the saga ID in the commands sent by Send.
+const HeaderSagaID = "saga_id"
+
+// Send returns a step action publishing on topic the command built from the
+// saga data, keyed by the saga ID. The publisher can be any outbox
+// publisher, as redislib StreamPublisher.OutboxPublisher, or a watermill
+// publisher adapted by WatermillPublisher.
+func Send[T any](publisher outboxlib.Publisher, topic string, command func(id string, data *T) (any, error)) func(ctx context.Context, id string, data *T) error {
+	return func(ctx context.Context, id string, data *T) error {
+		cmd, err := command(id, data)
+		if err != nil {
+			return err
+		}
+		msg, err := outboxlib.NewMessage(topic, id, cmd)
+		if err != nil {
+			return err
+		}
+		msg.Headers = map[string]string{HeaderSagaID: id}
+		return publisher.Publish(ctx, msg)
+	}
+}
+
+// WatermillPublisher adapts a watermill publisher to publish the messages
+// on their topic, with the headers as metadata.
+func WatermillPublisher(pub message.Publisher) outboxlib.Publisher {
+	return outboxlib.PublisherFunc(func(ctx context.Context, msg outboxlib.Message) error {
+		m := message.NewMessage(watermill.NewUUID(), msg.Payload)
+		m.SetContext(ctx)
+		for k, v := range msg.Headers {
+			m.Metadata.Set(k, v)
+		}
+		return pub.Publish(msg.Topic, m)
+	})
+}
@@ -0,0 +1,100 @@
+// Package sagalib orchestrates sagas: workflows spanning multiple services,
+// made of steps with compensating actions undoing them when a following
+// step fails.
+//
+// The state of the sagas is persisted in a Store, provided by dblib and
+// mongolib/repo, so that any instance of the service can resume them. The
+// step deadlines are stored with the state and checked by the orchestrator
+// loop, so they survive the restarts. The steps can send their commands
+// with Send, through the redislib streams or a watermill publisher, and be
+// completed by the replies calling Complete or Fail.
+package sagalib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("saga not found")
+	ErrExists         = errors.New("saga already exists")
+	ErrConflict       = errors.New("saga updated concurrently")
+	ErrUnexpectedStep = errors.New("saga is not waiting for the step")
+	ErrStepTimeout    = errors.New("saga step timed out")
+)
+
+// Status is the status of a saga.
+type Status string
+
+const (
+	StatusRunning      Status = "running"
+	StatusCompensating Status = "compensating"
+	StatusCompleted    Status = "completed"
+	StatusCompensated  Status = "compensated"
+	// StatusFailed marks the sagas whose compensation was given up, to be
+	// fixed manually.
+	StatusFailed Status = "failed"
+)
+
+// State is the persisted state of a saga.
+type State struct {
+	ID   string `json:"id"`
+	Saga string `json:"saga"`
+	// Status is the status of the saga.
+	Status Status `json:"status"`
+	// Step is the index of the running step or, while compensating, the
+	// number of steps still to be compensated.
+	Step     int    `json:"step"`
+	StepName string `json:"stepName,omitempty"`
+	// Data is the JSON encoding of the saga data.
+	Data json.RawMessage `json:"data"`
+	// Error is the cause of the compensation.
+	Error string `json:"error,omitempty"`
+	// Attempts is the number of failed attempts of the current compensation.
+	Attempts int `json:"attempts,omitempty"`
+	// Deadline is the time after which the current step times out, or the
+	// failed compensation is retried.
+	Deadline  *time.Time `json:"deadline,omitempty"`
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// Done reports whether the saga reached a final status.
+func (s *State) Done() bool {
+	switch s.Status {
+	case StatusCompleted, StatusCompensated, StatusFailed:
+		return true
+	}
+	return false
+}
+
+// Decode decodes the saga data into v.
+func (s *State) Decode(v any) error {
+	return json.Unmarshal(s.Data, v)
+}
+
+// Filter selects the sagas returned by Store.Find.
+type Filter struct {
+	Saga   string
+	Status Status
+	Limit  int
+}
+
+// Store persists the state of the sagas.
+type Store interface {
+	// Create stores a new saga, returning ErrExists if the ID is taken.
+	Create(ctx context.Context, state *State) error
+	// Get returns the saga with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*State, error)
+	// Update stores the saga if its version was not changed meanwhile,
+	// returning ErrConflict otherwise, and increments its version.
+	Update(ctx context.Context, state *State) error
+	// Expired returns up to limit sagas of the given definition, running
+	// or compensating, with the deadline before the given time.
+	Expired(ctx context.Context, saga string, before time.Time, limit int) ([]*State, error)
+	// Find returns the sagas matching the filter, the most recent first.
+	Find(ctx context.Context, filter Filter) ([]*State, error)
+}
@@ -0,0 +1,299 @@
+package sagalib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/outboxlib"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{states: map[string]State{}}
+}
+
+func (s *memoryStore) Create(ctx context.Context, state *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.states[state.ID]; ok {
+		return ErrExists
+	}
+	s.states[state.ID] = *state
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, id string) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &st, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, state *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states[state.ID].Version != state.Version {
+		return ErrConflict
+	}
+	state.Version++
+	s.states[state.ID] = *state
+	return nil
+}
+
+func (s *memoryStore) Expired(ctx context.Context, saga string, before time.Time, limit int) ([]*State, error) {
+	res, _ := s.Find(ctx, Filter{Saga: saga})
+	expired := []*State{}
+	for _, st := range res {
+		if !st.Done() && st.Deadline != nil && st.Deadline.Before(before) && len(expired) < limit {
+			expired = append(expired, st)
+		}
+	}
+	return expired, nil
+}
+
+func (s *memoryStore) Find(ctx context.Context, filter Filter) ([]*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []*State
+	for _, st := range s.states {
+		if (filter.Saga == "" || st.Saga == filter.Saga) && (filter.Status == "" || st.Status == filter.Status) {
+			res = append(res, &st)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	return res, nil
+}
+
+// expire moves the deadline of the saga in the past.
+func (s *memoryStore) expire(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.states[id]
+	past := time.Now().Add(-time.Second)
+	st.Deadline = &past
+	s.states[id] = st
+}
+
+type order struct {
+	Amount    int    `json:"amount"`
+	PaymentID string `json:"paymentId,omitempty"`
+}
+
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) step(name string, err error) func(ctx context.Context, id string, data *order) error {
+	return func(ctx context.Context, id string, data *order) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls = append(r.calls, name)
+		return err
+	}
+}
+
+func orderSaga(r *recorder, shippingErr error) Definition[order] {
+	return Definition[order]{
+		Name: "order",
+		Steps: []Step[order]{
+			{Name: "reserve", Action: r.step("reserve", nil), Compensate: r.step("release", nil)},
+			{Name: "payment", Action: r.step("charge", nil), Compensate: r.step("refund", nil), Reply: true},
+			{Name: "shipping", Action: r.step("ship", shippingErr)},
+		},
+	}
+}
+
+func TestSagaCompleted(t *testing.T) {
+	store := newMemoryStore()
+	r := &recorder{}
+	o := New(store, orderSaga(r, nil), Options{})
+	ctx := context.Background()
+
+	st, err := o.Begin(ctx, "o1", order{Amount: 10})
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, st.Status)
+	require.Equal(t, "payment", st.StepName)
+	require.NotNil(t, st.Deadline)
+
+	_, err = o.Begin(ctx, "o1", order{})
+	require.ErrorIs(t, err, ErrExists)
+
+	require.ErrorIs(t, o.Complete(ctx, "o1", "shipping", nil), ErrUnexpectedStep)
+	require.NoError(t, o.Complete(ctx, "o1", "payment", func(data *order) error {
+		data.PaymentID = "p1"
+		return nil
+	}))
+	require.ErrorIs(t, o.Complete(ctx, "o1", "payment", nil), ErrUnexpectedStep, "duplicated reply")
+
+	st, err = o.Status(ctx, "o1")
+	require.NoError(t, err)
+	require.Equal(t, StatusCompleted, st.Status)
+	require.Nil(t, st.Deadline)
+	var data order
+	require.NoError(t, st.Decode(&data))
+	require.Equal(t, order{Amount: 10, PaymentID: "p1"}, data)
+	require.Equal(t, []string{"reserve", "charge", "ship"}, r.calls)
+}
+
+func TestSagaCompensated(t *testing.T) {
+	store := newMemoryStore()
+	r := &recorder{}
+	o := New(store, orderSaga(r, errors.New("no courier")), Options{})
+	ctx := context.Background()
+
+	_, err := o.Begin(ctx, "o1", order{Amount: 10})
+	require.NoError(t, err)
+	require.NoError(t, o.Complete(ctx, "o1", "payment", nil))
+
+	st, err := o.Status(ctx, "o1")
+	require.NoError(t, err)
+	require.Equal(t, StatusCompensated, st.Status)
+	require.Equal(t, "step shipping: no courier", st.Error)
+	require.Equal(t, []string{"reserve", "charge", "ship", "refund", "release"}, r.calls)
+
+	r.calls = nil
+	_, err = o.Begin(ctx, "o2", order{Amount: 10})
+	require.NoError(t, err)
+	require.NoError(t, o.Fail(ctx, "o2", "payment", errors.New("declined")))
+	st, err = o.Status(ctx, "o2")
+	require.NoError(t, err)
+	require.Equal(t, StatusCompensated, st.Status)
+	require.Equal(t, []string{"reserve", "charge", "release"}, r.calls, "the failed step is not compensated")
+}
+
+func TestSagaTimeout(t *testing.T) {
+	store := newMemoryStore()
+	r := &recorder{}
+	o := New(store, orderSaga(r, nil), Options{})
+	ctx := context.Background()
+
+	_, err := o.Begin(ctx, "o1", order{Amount: 10})
+	require.NoError(t, err)
+	n, err := o.CheckTimeouts(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	store.expire("o1")
+	n, err = o.CheckTimeouts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	st, err := o.Status(ctx, "o1")
+	require.NoError(t, err)
+	require.Equal(t, StatusCompensated, st.Status)
+	require.Contains(t, st.Error, ErrStepTimeout.Error())
+	require.Equal(t, []string{"reserve", "charge", "refund", "release"}, r.calls, "the timed out step is compensated")
+	require.ErrorIs(t, o.Complete(ctx, "o1", "payment", nil), ErrUnexpectedStep, "late reply")
+}
+
+func TestSagaCompensationRetry(t *testing.T) {
+	store := newMemoryStore()
+	r := &recorder{}
+	refunds := 0
+	def := orderSaga(r, nil)
+	def.Steps[1].Compensate = func(ctx context.Context, id string, data *order) error {
+		refunds++
+		if refunds < 2 {
+			return errors.New("payment service down")
+		}
+		return nil
+	}
+	def.Steps[0].Compensate = func(ctx context.Context, id string, data *order) error {
+		return errors.New("inventory service down")
+	}
+	o := New(store, def, Options{MaxAttempts: 2})
+	ctx := context.Background()
+
+	_, err := o.Begin(ctx, "o1", order{Amount: 10})
+	require.NoError(t, err)
+	store.expire("o1")
+	_, err = o.CheckTimeouts(ctx)
+	require.NoError(t, err)
+
+	st, err := o.Status(ctx, "o1")
+	require.NoError(t, err)
+	require.Equal(t, StatusCompensating, st.Status)
+	require.Equal(t, "payment", st.StepName)
+	require.Equal(t, 1, st.Attempts)
+	require.True(t, st.Deadline.After(time.Now()), "the compensation is retried later")
+
+	store.expire("o1")
+	_, err = o.CheckTimeouts(ctx)
+	require.NoError(t, err)
+	st, err = o.Status(ctx, "o1")
+	require.NoError(t, err)
+	require.Equal(t, StatusCompensating, st.Status)
+	require.Equal(t, "reserve", st.StepName)
+
+	store.expire("o1")
+	_, err = o.CheckTimeouts(ctx)
+	require.NoError(t, err)
+	st, err = o.Status(ctx, "o1")
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, st.Status)
+	require.Equal(t, "reserve", st.StepName)
+	require.Nil(t, st.Deadline)
+}
+
+func TestSend(t *testing.T) {
+	var sent outboxlib.Message
+	publisher := outboxlib.PublisherFunc(func(ctx context.Context, msg outboxlib.Message) error {
+		sent = msg
+		return nil
+	})
+	action := Send(publisher, "payments", func(id string, data *order) (any, error) {
+		return map[string]any{"order": id, "amount": data.Amount}, nil
+	})
+	require.NoError(t, action(context.Background(), "o1", &order{Amount: 10}))
+	require.Equal(t, "payments", sent.Topic)
+	require.Equal(t, "o1", sent.Key)
+	require.Equal(t, "o1", sent.Headers[HeaderSagaID])
+	require.JSONEq(t, `{"order":"o1","amount":10}`, string(sent.Payload))
+}
+
+func TestHandler(t *testing.T) {
+	store := newMemoryStore()
+	o := New(store, orderSaga(&recorder{}, nil), Options{})
+	_, err := o.Begin(context.Background(), "o1", order{Amount: 10})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.StripPrefix("/sagas", Handler(store)))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/sagas/o1")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var st State
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&st))
+	require.Equal(t, "payment", st.StepName)
+	require.JSONEq(t, `{"amount":10}`, string(st.Data))
+
+	res, err = http.Get(srv.URL + "/sagas/?status=running")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	var states []State
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&states))
+	require.Len(t, states, 1)
+
+	res, err = http.Get(srv.URL + "/sagas/missing")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,221 @@
+package svc
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/sandrolain/gomsvc/pkg/certlib"
+)
+
+var ErrAdminUnprotected = errors.New("admin server requires ADMIN_TOKEN or the ADMIN_TLS_* files")
+
+type AdminInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	ID        string `json:"id"`
+	GoVersion string `json:"goVersion"`
+	Module    string `json:"module,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+}
+
+type AdminComponent struct {
+	Name      string   `json:"name"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+	Started   bool     `json:"started"`
+}
+
+type AdminLogLevel struct {
+	Level string `json:"level"`
+}
+
+// adminComponent serves the admin endpoints on a dedicated listener,
+// protected by the bearer token and/or the client certificates.
+func adminComponent(env DefaultEnv) Component {
+	var server *http.Server
+
+	return Component{
+		Name: "admin",
+		Start: func(ctx context.Context) error {
+			var tlsConfig *tls.Config
+			if env.AdminTLSCertFile != "" {
+				var err error
+				tlsConfig, err = certlib.LoadServerTLSConfig(certlib.ServerTLSConfigFiles{
+					CertFile: env.AdminTLSCertFile,
+					KeyFile:  env.AdminTLSKeyFile,
+					CAFile:   env.AdminTLSCAFile,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to load admin credentials: %w", err)
+				}
+			} else if env.AdminToken == "" {
+				return ErrAdminUnprotected
+			}
+
+			ln, err := net.Listen("tcp", env.AdminAddr)
+			if err != nil {
+				return fmt.Errorf("failed to listen: %w", err)
+			}
+			if tlsConfig != nil {
+				ln = tls.NewListener(ln, tlsConfig)
+			}
+
+			server = &http.Server{Handler: AdminHandler(env.AdminToken)}
+			go func() {
+				if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+					_ = Error("Admin server stopped", err, "addr", env.AdminAddr)
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if server == nil {
+				return nil
+			}
+			return server.Shutdown(ctx)
+		},
+	}
+}
+
+// AdminHandler returns the HTTP handler of the admin endpoints.
+// When token is not empty the requests must carry it as bearer token.
+func AdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("GET /admin/info", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, adminInfo())
+	})
+	mux.HandleFunc("GET /admin/config", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, adminConfig())
+	})
+	mux.HandleFunc("GET /admin/components", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, adminComponents())
+	})
+	mux.HandleFunc("GET /admin/exit-hooks", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, adminExitHooks())
+	})
+	mux.HandleFunc("GET /admin/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, AdminLogLevel{Level: currentLogLevel()})
+	})
+	mux.HandleFunc("PUT /admin/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		var body AdminLogLevel
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !isLogLevel(body.Level) {
+			http.Error(w, "invalid log level", http.StatusBadRequest)
+			return
+		}
+		Logger().Info("Log level changed", "from", currentLogLevel(), "to", strings.ToUpper(body.Level))
+		LogLevel(body.Level)
+		writeAdminJSON(w, http.StatusOK, AdminLogLevel{Level: currentLogLevel()})
+	})
+
+	if token == "" {
+		return mux
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func adminInfo() AdminInfo {
+	info := AdminInfo{GoVersion: runtime.Version(), ID: ServiceID()}
+	optionsMu.RLock()
+	if options != nil {
+		info.Name = options.Name
+		info.Version = options.Version
+	}
+	optionsMu.RUnlock()
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Module = bi.Main.Path
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Revision = s.Value
+			case "vcs.time":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	return info
+}
+
+// adminConfig returns the fields of the current configuration,
+// with the secret values masked.
+func adminConfig() ConfigReport {
+	globalConfigMu.RLock()
+	defer globalConfigMu.RUnlock()
+	res := make(ConfigReport, len(globalConfigReport))
+	for i, f := range globalConfigReport {
+		if f.Secret {
+			f.Value = secretMask
+		}
+		res[i] = f
+	}
+	return res
+}
+
+func adminComponents() []AdminComponent {
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	res := make([]AdminComponent, len(components))
+	for i, c := range components {
+		res[i] = AdminComponent{
+			Name:      c.Name,
+			DependsOn: c.DependsOn,
+			Started:   isComponentStarted(c.Name),
+		}
+	}
+	return res
+}
+
+// adminExitHooks returns the function names of the OnExit callbacks.
+func adminExitHooks() []string {
+	exitCallbacksMu.RLock()
+	defer exitCallbacksMu.RUnlock()
+	res := make([]string, len(exitCallbacks))
+	for i, fn := range exitCallbacks {
+		res[i] = runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	}
+	return res
+}
+
+func currentLogLevel() string {
+	Logger()
+	return loggerLevel.Level().String()
+}
+
+func isLogLevel(level string) bool {
+	switch strings.ToUpper(level) {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func adminRequest(t *testing.T, h http.Handler, method string, path string, token string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminHandlerAuth(t *testing.T) {
+	h := AdminHandler("s3cret")
+
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, h, "GET", "/admin/info", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, h, "GET", "/admin/info", "wrong", "").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(t, h, "GET", "/admin/info", "s3cret", "").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(t, h, "GET", "/debug/pprof/", "s3cret", "").Code)
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	h := AdminHandler("")
+	defer LogLevel("INFO")
+
+	rec := adminRequest(t, h, "PUT", "/admin/loglevel", "", `{"level":"debug"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res AdminLogLevel
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, "DEBUG", res.Level)
+	assert.True(t, Logger().Enabled(context.Background(), slog.LevelDebug))
+
+	rec = adminRequest(t, h, "PUT", "/admin/loglevel", "", `{"level":"verbose"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest(t, h, "GET", "/admin/loglevel", "", "")
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, "DEBUG", res.Level)
+}
+
+func TestAdminConfigMasksSecrets(t *testing.T) {
+	globalConfigMu.Lock()
+	prev := globalConfigReport
+	globalConfigReport = ConfigReport{
+		{Key: "APP_NAME", Source: SourceEnv, Value: "test"},
+		{Key: "ADMIN_TOKEN", Source: SourceEnv, Secret: true, Value: "s3cret"},
+	}
+	globalConfigMu.Unlock()
+	defer func() {
+		globalConfigMu.Lock()
+		globalConfigReport = prev
+		globalConfigMu.Unlock()
+	}()
+
+	rec := adminRequest(t, AdminHandler(""), "GET", "/admin/config", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "test")
+	assert.NotContains(t, rec.Body.String(), "s3cret")
+	assert.Contains(t, rec.Body.String(), secretMask)
+}
+
+func TestAdminComponentsAndExitHooks(t *testing.T) {
+	resetComponents()
+	defer resetComponents()
+	require.NoError(t, AddComponent(Component{Name: "db"}))
+	require.NoError(t, AddComponent(Component{Name: "api", DependsOn: []string{"db"}}))
+
+	exitCallbacksMu.Lock()
+	prev := exitCallbacks
+	exitCallbacks = []OnExitFunc{resetComponents}
+	exitCallbacksMu.Unlock()
+	defer func() {
+		exitCallbacksMu.Lock()
+		exitCallbacks = prev
+		exitCallbacksMu.Unlock()
+	}()
+
+	h := AdminHandler("")
+
+	var comps []AdminComponent
+	require.NoError(t, json.NewDecoder(adminRequest(t, h, "GET", "/admin/components", "", "").Body).Decode(&comps))
+	assert.Equal(t, []AdminComponent{{Name: "db"}, {Name: "api", DependsOn: []string{"db"}}}, comps)
+
+	var hooks []string
+	require.NoError(t, json.NewDecoder(adminRequest(t, h, "GET", "/admin/exit-hooks", "", "").Body).Decode(&hooks))
+	require.Len(t, hooks, 1)
+	assert.True(t, strings.HasSuffix(hooks[0], ".resetComponents"))
+}
+
+func TestAdminComponentRequiresProtection(t *testing.T) {
+	c := adminComponent(DefaultEnv{AdminAddr: "127.0.0.1:0"})
+	assert.ErrorIs(t, c.Start(context.Background()), ErrAdminUnprotected)
+}
@@ -0,0 +1,295 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ComponentHook is a lifecycle hook of a Component.
+// Start hooks must not block: long-running work has to be moved to a goroutine.
+type ComponentHook func(ctx context.Context) error
+
+// Component describes a part of the service with a managed lifecycle.
+// Components are started in dependency order and stopped in reverse start order.
+type Component struct {
+	Name        string
+	DependsOn   []string
+	Start       ComponentHook
+	Stop        ComponentHook
+	StopTimeout time.Duration
+}
+
+var (
+	// startMu serializes the starts, componentsMu guards the lists
+	startMu           sync.Mutex
+	componentsMu      sync.Mutex
+	components        = make([]Component, 0)
+	startedComponents = make([]Component, 0)
+	shutdownTimeout   = DefaultShutdownTimeout
+	// startOnAdd starts the components on registration, once Service has
+	// started the ones registered before it
+	startOnAdd bool
+)
+
+var (
+	ErrComponentName       = errors.New("component name is required")
+	ErrComponentDuplicated = errors.New("component already registered")
+	ErrComponentDependency = errors.New("component dependency not registered")
+	ErrComponentCycle      = errors.New("component dependency cycle")
+)
+
+// AddComponent registers a component to be managed by the service lifecycle.
+// Components registered before Service are started before calling the
+// ServiceFunc, the ones registered within it are started on registration,
+// and AddComponent returns their start error.
+func AddComponent(c Component) error {
+	if c.Name == "" {
+		return ErrComponentName
+	}
+	componentsMu.Lock()
+	for _, v := range components {
+		if v.Name == c.Name {
+			componentsMu.Unlock()
+			return fmt.Errorf("%w: %s", ErrComponentDuplicated, c.Name)
+		}
+	}
+	components = append(components, c)
+	start := startOnAdd
+	componentsMu.Unlock()
+	// The components registered by a start hook are started by the running start
+	if !start || !startMu.TryLock() {
+		return nil
+	}
+	if err := startComponents(context.Background(), false); err != nil {
+		removeComponent(c.Name)
+		return err
+	}
+	return nil
+}
+
+// removeComponent unregisters the component if not started.
+func removeComponent(name string) {
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	if isComponentStarted(name) {
+		return
+	}
+	for i, c := range components {
+		if c.Name == name {
+			components = append(components[:i:i], components[i+1:]...)
+			return
+		}
+	}
+}
+
+// Components returns the names of the registered components.
+func Components() []string {
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	res := make([]string, len(components))
+	for i, c := range components {
+		res[i] = c.Name
+	}
+	return res
+}
+
+func getShutdownTimeout() time.Duration {
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	return shutdownTimeout
+}
+
+// SetShutdownTimeout sets the maximum duration of the graceful shutdown
+// performed by Exit.
+func SetShutdownTimeout(timeout time.Duration) {
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	shutdownTimeout = timeout
+}
+
+// StartComponents starts the registered components not yet started,
+// in dependency order. If a component fails to start, the components
+// already started are stopped and the error is returned.
+// The hooks run without holding the components lock, so that they can
+// register and list the components.
+func StartComponents(ctx context.Context) error {
+	startMu.Lock()
+	return startComponents(ctx, true)
+}
+
+// startComponents starts the components not yet started, including the ones
+// registered meanwhile, stopping the started ones on failure with rollback.
+// It must be called holding startMu, which it releases: the pending
+// components are checked before releasing it, so that the registrations
+// failing to acquire it are started by this call.
+func startComponents(ctx context.Context, rollback bool) error {
+	for {
+		componentsMu.Lock()
+		ordered, err := sortComponents(components)
+		timeout := shutdownTimeout
+		pending := false
+		for _, c := range ordered {
+			pending = pending || !isComponentStarted(c.Name)
+		}
+		if err != nil || !pending {
+			startMu.Unlock()
+			componentsMu.Unlock()
+			return err
+		}
+		componentsMu.Unlock()
+
+		for _, c := range ordered {
+			componentsMu.Lock()
+			started := isComponentStarted(c.Name)
+			componentsMu.Unlock()
+			if started {
+				continue
+			}
+			if c.Start != nil {
+				if err := c.Start(ctx); err != nil {
+					if rollback {
+						componentsMu.Lock()
+						started := startedComponents
+						startedComponents = make([]Component, 0)
+						componentsMu.Unlock()
+						stopComponents(started, timeout)
+					}
+					startMu.Unlock()
+					return fmt.Errorf("cannot start component %s: %w", c.Name, err)
+				}
+			}
+			componentsMu.Lock()
+			startedComponents = append(startedComponents, c)
+			componentsMu.Unlock()
+			Logger().Debug("Component started", "name", c.Name)
+		}
+	}
+}
+
+// StopComponents stops the started components in reverse start order.
+func StopComponents() {
+	ctx, cancel := context.WithTimeout(context.Background(), getShutdownTimeout())
+	defer cancel()
+	stopStartedComponents(ctx)
+}
+
+// stopStartedComponents stops the started components within the deadline
+// of the context.
+func stopStartedComponents(ctx context.Context) {
+	componentsMu.Lock()
+	started := startedComponents
+	startedComponents = make([]Component, 0)
+	componentsMu.Unlock()
+	stopComponentsContext(ctx, started)
+}
+
+// setStartOnAdd enables or disables the start of the components on registration.
+func setStartOnAdd(start bool) {
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	startOnAdd = start
+}
+
+func isComponentStarted(name string) bool {
+	for _, c := range startedComponents {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func stopComponents(started []Component, timeout time.Duration) {
+	if len(started) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	stopComponentsContext(ctx, started)
+}
+
+func stopComponentsContext(ctx context.Context, started []Component) {
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if c.Stop == nil {
+			continue
+		}
+		if err := stopComponent(ctx, c); err != nil {
+			Logger().Error("Component stop failed", "name", c.Name, "err", err)
+			continue
+		}
+		Logger().Debug("Component stopped", "name", c.Name)
+	}
+}
+
+func stopComponent(ctx context.Context, c Component) error {
+	if c.StopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.StopTimeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Stop(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sortComponents returns the components in dependency order,
+// keeping the registration order between independent components.
+func sortComponents(list []Component) ([]Component, error) {
+	byName := make(map[string]Component, len(list))
+	for _, c := range list {
+		byName[c.Name] = c
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(list))
+	res := make([]Component, 0, len(list))
+
+	var visit func(c Component) error
+	visit = func(c Component) error {
+		switch state[c.Name] {
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrComponentCycle, c.Name)
+		case visited:
+			return nil
+		}
+		state[c.Name] = visiting
+		for _, dep := range c.DependsOn {
+			d, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("%w: %s depends on %s", ErrComponentDependency, c.Name, dep)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[c.Name] = visited
+		res = append(res, c)
+		return nil
+	}
+
+	for _, c := range list {
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
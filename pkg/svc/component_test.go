@@ -0,0 +1,199 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetComponents() {
+	componentsMu.Lock()
+	components = make([]Component, 0)
+	startedComponents = make([]Component, 0)
+	shutdownTimeout = DefaultShutdownTimeout
+	startOnAdd = false
+	componentsMu.Unlock()
+}
+
+type componentRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *componentRecorder) component(name string, dependsOn ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			r.add("start " + name)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			r.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+func (r *componentRecorder) add(call string) {
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.mu.Unlock()
+}
+
+func TestComponentsLifecycle(t *testing.T) {
+	setupTest()
+	resetComponents()
+	defer resetComponents()
+
+	rec := &componentRecorder{}
+	assert.NoError(t, AddComponent(rec.component("http", "db", "cache")))
+	assert.NoError(t, AddComponent(rec.component("db")))
+	assert.NoError(t, AddComponent(rec.component("cache", "db")))
+
+	assert.Equal(t, []string{"http", "db", "cache"}, Components())
+
+	assert.NoError(t, StartComponents(context.Background()))
+	StopComponents()
+
+	assert.Equal(t, []string{
+		"start db", "start cache", "start http",
+		"stop http", "stop cache", "stop db",
+	}, rec.calls)
+}
+
+func TestAddComponentErrors(t *testing.T) {
+	setupTest()
+	resetComponents()
+	defer resetComponents()
+
+	assert.ErrorIs(t, AddComponent(Component{}), ErrComponentName)
+	assert.NoError(t, AddComponent(Component{Name: "db"}))
+	assert.ErrorIs(t, AddComponent(Component{Name: "db"}), ErrComponentDuplicated)
+}
+
+func TestStartComponentsDependencyErrors(t *testing.T) {
+	setupTest()
+
+	t.Run("missing dependency", func(t *testing.T) {
+		resetComponents()
+		defer resetComponents()
+		assert.NoError(t, AddComponent(Component{Name: "http", DependsOn: []string{"db"}}))
+		assert.ErrorIs(t, StartComponents(context.Background()), ErrComponentDependency)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		resetComponents()
+		defer resetComponents()
+		assert.NoError(t, AddComponent(Component{Name: "a", DependsOn: []string{"b"}}))
+		assert.NoError(t, AddComponent(Component{Name: "b", DependsOn: []string{"a"}}))
+		assert.ErrorIs(t, StartComponents(context.Background()), ErrComponentCycle)
+	})
+}
+
+func TestStartComponentsFailure(t *testing.T) {
+	setupTest()
+	resetComponents()
+	defer resetComponents()
+
+	rec := &componentRecorder{}
+	assert.NoError(t, AddComponent(rec.component("db")))
+	assert.NoError(t, AddComponent(Component{
+		Name:      "http",
+		DependsOn: []string{"db"},
+		Start: func(ctx context.Context) error {
+			return errors.New("bind failed")
+		},
+	}))
+
+	err := StartComponents(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "http")
+	assert.Equal(t, []string{"start db", "stop db"}, rec.calls)
+}
+
+func TestStopComponentsTimeout(t *testing.T) {
+	setupTest()
+	resetComponents()
+	defer resetComponents()
+
+	rec := &componentRecorder{}
+	assert.NoError(t, AddComponent(rec.component("db")))
+	assert.NoError(t, AddComponent(Component{
+		Name:        "hung",
+		StopTimeout: 50 * time.Millisecond,
+		Stop: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	}))
+
+	assert.NoError(t, StartComponents(context.Background()))
+
+	start := time.Now()
+	StopComponents()
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, []string{"start db", "stop db"}, rec.calls)
+}
+
+func TestStartComponentsHookRegisters(t *testing.T) {
+	setupTest()
+	resetComponents()
+	defer resetComponents()
+
+	rec := &componentRecorder{}
+	assert.NoError(t, AddComponent(Component{
+		Name: "plugins",
+		Start: func(ctx context.Context) error {
+			rec.add("components " + strings.Join(Components(), ","))
+			return AddComponent(rec.component("plugin"))
+		},
+	}))
+
+	done := make(chan error, 1)
+	go func() { done <- StartComponents(context.Background()) }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("StartComponents deadlocked")
+	}
+	assert.NoError(t, StartComponents(context.Background()))
+	assert.Equal(t, []string{"components plugins", "start plugin"}, rec.calls)
+}
+
+func TestAddComponentAfterStart(t *testing.T) {
+	setupTest()
+	resetComponents()
+	defer resetComponents()
+
+	rec := &componentRecorder{}
+	assert.NoError(t, AddComponent(rec.component("db")))
+	assert.NoError(t, StartComponents(context.Background()))
+	setStartOnAdd(true)
+
+	assert.NoError(t, AddComponent(rec.component("cache", "db")))
+	assert.NoError(t, AddComponent(Component{
+		Name: "plugins",
+		Start: func(ctx context.Context) error {
+			return AddComponent(rec.component("plugin"))
+		},
+	}))
+	assert.Equal(t, []string{"start db", "start cache", "start plugin"}, rec.calls)
+
+	errStart := errors.New("start failed")
+	err := AddComponent(Component{
+		Name:  "broken",
+		Start: func(ctx context.Context) error { return errStart },
+	})
+	assert.ErrorIs(t, err, errStart)
+	assert.NotContains(t, Components(), "broken")
+
+	StopComponents()
+	assert.Equal(t, []string{"stop plugin", "stop cache", "stop db"}, rec.calls[3:])
+}
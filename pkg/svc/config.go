@@ -0,0 +1,322 @@
+package svc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/caarlos0/env/v9"
+	"github.com/go-playground/validator/v10"
+	"github.com/sandrolain/gomsvc/pkg/svc/envtype"
+	"gopkg.in/yaml.v3"
+)
+
+type ConfigSource string
+
+const (
+	SourceDefault    ConfigSource = "default"
+	SourceFile       ConfigSource = "file"
+	SourceDotEnv     ConfigSource = "dotenv"
+	SourceEnv        ConfigSource = "env"
+	SourceSecretFile ConfigSource = "secret_file"
+)
+
+// SecretFileSuffix is the suffix of the environment variables
+// containing the path of a file holding the value of a secret.
+const SecretFileSuffix = "_FILE"
+
+const secretMask = "******"
+
+// ConfigOptions defines the layers loaded, in order of increasing priority:
+// struct defaults, config files, dotenv files, environment variables
+// and secret files referenced by the "_FILE" suffixed variables of the
+// keys declared by the configuration struct.
+type ConfigOptions struct {
+	// Files are YAML, JSON or TOML files, detected by extension.
+	// Nested keys are joined with "_" and upper-cased to match the env tags.
+	Files []string
+	// DotEnvFiles are files of KEY=VALUE lines.
+	DotEnvFiles []string
+	// IgnoreMissingFiles skips the files that do not exist.
+	IgnoreMissingFiles bool
+}
+
+// ConfigOptionsFromEnv returns the options defined by the
+// CONFIG_FILE and DOTENV_FILE comma separated environment variables.
+func ConfigOptionsFromEnv() ConfigOptions {
+	return ConfigOptions{
+		Files:       splitList(os.Getenv("CONFIG_FILE")),
+		DotEnvFiles: splitList(os.Getenv("DOTENV_FILE")),
+	}
+}
+
+type ConfigField struct {
+	Key    string
+	Source ConfigSource
+	Secret bool
+	Value  string
+	// File is the path of the secret file, for the SourceSecretFile fields
+	File string
+}
+
+// ConfigReport lists the configuration fields set, with their source.
+// Secret values are masked when the report is logged.
+type ConfigReport []ConfigField
+
+func (r ConfigReport) Source(key string) (ConfigSource, bool) {
+	for _, f := range r {
+		if f.Key == key {
+			return f.Source, true
+		}
+	}
+	return "", false
+}
+
+// SecretFiles returns the paths of the secret files the fields are loaded from.
+func (r ConfigReport) SecretFiles() []string {
+	var res []string
+	for _, f := range r {
+		if f.File != "" {
+			res = append(res, f.File)
+		}
+	}
+	return res
+}
+
+func (r ConfigReport) LogValue() slog.Value {
+	attrs := make([]slog.Attr, len(r))
+	for i, f := range r {
+		value := f.Value
+		if f.Secret {
+			value = secretMask
+		}
+		attrs[i] = slog.Group(f.Key, "value", value, "source", string(f.Source))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LoadConfig loads the layered configuration into T and validates it.
+func LoadConfig[T any](opts ConfigOptions) (cfg T, report ConfigReport, err error) {
+	environment := make(map[string]string)
+	sources := make(map[string]ConfigSource)
+
+	set := func(values map[string]string, source ConfigSource) {
+		for k, v := range values {
+			environment[k] = v
+			sources[k] = source
+		}
+	}
+
+	for _, file := range opts.Files {
+		values, e := readConfigFile(file)
+		if e != nil {
+			if opts.IgnoreMissingFiles && os.IsNotExist(e) {
+				continue
+			}
+			err = fmt.Errorf("cannot load config file %s: %w", file, e)
+			return
+		}
+		set(values, SourceFile)
+	}
+
+	for _, file := range opts.DotEnvFiles {
+		values, e := readDotEnvFile(file)
+		if e != nil {
+			if opts.IgnoreMissingFiles && os.IsNotExist(e) {
+				continue
+			}
+			err = fmt.Errorf("cannot load dotenv file %s: %w", file, e)
+			return
+		}
+		set(values, SourceDotEnv)
+	}
+
+	osEnv := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			osEnv[k] = v
+		}
+	}
+	set(osEnv, SourceEnv)
+
+	// The env keys declared by T, true when secret
+	envKeys := make(map[string]bool)
+	collectEnvKeys(reflect.TypeOf(cfg), "", envKeys)
+
+	secrets := make(map[string]string)
+	secretFiles := make(map[string]string)
+	for k, v := range environment {
+		key, ok := strings.CutSuffix(k, SecretFileSuffix)
+		if !ok || key == "" {
+			continue
+		}
+		// Only the keys declared by T, unless the suffixed key is declared too
+		if _, declared := envKeys[key]; !declared {
+			continue
+		}
+		if _, declared := envKeys[k]; declared {
+			continue
+		}
+		b, e := os.ReadFile(filepath.Clean(v))
+		if e != nil {
+			err = fmt.Errorf("cannot load secret file for %s: %w", key, e)
+			return
+		}
+		secrets[key] = strings.TrimRight(string(b), "\r\n")
+		secretFiles[key] = v
+	}
+	set(secrets, SourceSecretFile)
+
+	err = env.ParseWithOptions(&cfg, env.Options{
+		Environment: environment,
+		OnSet: func(key string, value interface{}, isDefault bool) {
+			source, ok := sources[key]
+			if isDefault {
+				source = SourceDefault
+			} else if !ok {
+				return
+			}
+			report = append(report, ConfigField{
+				Key:    key,
+				Source: source,
+				Secret: envKeys[key] || source == SourceSecretFile,
+				Value:  fmt.Sprintf("%v", value),
+				File:   secretFiles[key],
+			})
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Key < report[j].Key
+	})
+
+	err = validator.New(validator.WithRequiredStructEnabled()).Struct(cfg)
+	return
+}
+
+var passwordType = reflect.TypeOf(envtype.Password{})
+
+// collectEnvKeys finds the env keys of the fields, set to true for the
+// fields with the `secret:"true"` tag or with the envtype.Password type.
+func collectEnvKeys(t reflect.Type, prefix string, keys map[string]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if key == "" && ft.Kind() == reflect.Struct {
+			collectEnvKeys(ft, prefix+field.Tag.Get("envPrefix"), keys)
+			continue
+		}
+		if key != "" {
+			keys[prefix+key] = field.Tag.Get("secret") == "true" || ft == passwordType
+		}
+	}
+}
+
+func readConfigFile(file string) (res map[string]string, err error) {
+	b, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return
+	}
+	var data map[string]interface{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &data)
+	case ".json":
+		err = json.Unmarshal(b, &data)
+	case ".toml":
+		err = toml.Unmarshal(b, &data)
+	default:
+		err = fmt.Errorf("unsupported config file type: %s", file)
+	}
+	if err != nil {
+		return
+	}
+	res = make(map[string]string)
+	flattenConfig(data, "", res)
+	return
+}
+
+func flattenConfig(data map[string]interface{}, prefix string, res map[string]string) {
+	for k, v := range data {
+		key := strings.ToUpper(prefix + k)
+		switch val := v.(type) {
+		case map[string]interface{}:
+			flattenConfig(val, key+"_", res)
+		case []interface{}:
+			parts := make([]string, len(val))
+			for i, p := range val {
+				parts[i] = fmt.Sprintf("%v", p)
+			}
+			res[key] = strings.Join(parts, ",")
+		case nil:
+			res[key] = ""
+		default:
+			res[key] = fmt.Sprintf("%v", val)
+		}
+	}
+}
+
+func readDotEnvFile(file string) (res map[string]string, err error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	res = make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			err = fmt.Errorf("invalid line %d", n)
+			return
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if l := len(value); l >= 2 && (value[0] == '"' || value[0] == '\'') && value[l-1] == value[0] {
+			value = value[1 : l-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		res[key] = value
+	}
+	err = scanner.Err()
+	return
+}
+
+func splitList(value string) []string {
+	res := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,151 @@
+package svc
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandrolain/gomsvc/pkg/svc/envtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TestLayeredConfig struct {
+	Host     string            `env:"LAYER_HOST" envDefault:"localhost"`
+	Port     int               `env:"LAYER_PORT" envDefault:"8080"`
+	Name     string            `env:"LAYER_NAME" validate:"required"`
+	Token    string            `env:"LAYER_TOKEN" secret:"true"`
+	Password envtype.Password  `env:"LAYER_PASSWORD"`
+	Database TestLayeredNested `envPrefix:"LAYER_DB_"`
+}
+
+type TestLayeredNested struct {
+	User string `env:"USER"`
+	Pass string `env:"PASS,file"`
+}
+
+func writeTestFile(t *testing.T, dir string, name string, content string) string {
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(p, []byte(content), 0600))
+	return p
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlFile := writeTestFile(t, dir, "config.yaml", `
+layer:
+  name: from-yaml
+  port: 9000
+  db:
+    user: yaml-user
+`)
+	dotEnvFile := writeTestFile(t, dir, ".env", `
+# comment
+export LAYER_NAME="from-dotenv"
+LAYER_TOKEN=plain-token # inline comment
+`)
+	secretFile := writeTestFile(t, dir, "token", "secret-token\n")
+	passFile := writeTestFile(t, dir, "pass", "db-pass")
+
+	t.Setenv("LAYER_PORT", "9090")
+	t.Setenv("LAYER_TOKEN_FILE", secretFile)
+	t.Setenv("LAYER_DB_PASS", passFile)
+	t.Setenv("LAYER_PASSWORD", "c2VjcmV0")
+
+	cfg, report, err := LoadConfig[TestLayeredConfig](ConfigOptions{
+		Files:       []string{yamlFile},
+		DotEnvFiles: []string{dotEnvFile},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "localhost", cfg.Host)
+	assert.Equal(t, 9090, cfg.Port)
+	assert.Equal(t, "from-dotenv", cfg.Name)
+	assert.Equal(t, "secret-token", cfg.Token)
+	assert.Equal(t, "secret", string(cfg.Password))
+	assert.Equal(t, "yaml-user", cfg.Database.User)
+	assert.Equal(t, "db-pass", cfg.Database.Pass)
+
+	sources := map[string]ConfigSource{
+		"LAYER_HOST":    SourceDefault,
+		"LAYER_PORT":    SourceEnv,
+		"LAYER_NAME":    SourceDotEnv,
+		"LAYER_TOKEN":   SourceSecretFile,
+		"LAYER_DB_USER": SourceFile,
+	}
+	for key, expected := range sources {
+		source, ok := report.Source(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, expected, source, key)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("config", "config", report)
+	out := buf.String()
+	assert.Contains(t, out, "from-dotenv")
+	assert.NotContains(t, out, "secret-token")
+	assert.NotContains(t, out, "c2VjcmV0")
+	assert.Contains(t, out, secretMask)
+}
+
+func TestLoadConfigSecretFileUndeclared(t *testing.T) {
+	t.Setenv("LAYER_NAME", "name")
+	t.Setenv("LOG_FILE", "/nonexistent/app.log")
+	t.Setenv("LAYER_UNKNOWN_FILE", "/nonexistent/secret")
+
+	_, report, err := LoadConfig[TestLayeredConfig](ConfigOptions{})
+	require.NoError(t, err)
+	_, ok := report.Source("LOG")
+	assert.False(t, ok)
+
+	_, _, err = LoadConfig[DefaultEnv](ConfigOptions{})
+	require.NoError(t, err)
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{"layer": {"name": "from-json"}}`,
+		"config.toml": "[layer]\nname = \"from-toml\"\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			file := writeTestFile(t, dir, name, content)
+			cfg, _, err := LoadConfig[TestLayeredConfig](ConfigOptions{Files: []string{file}})
+			require.NoError(t, err)
+			assert.Equal(t, "from-"+filepath.Ext(name)[1:], cfg.Name)
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("missing file", func(t *testing.T) {
+		_, _, err := LoadConfig[TestLayeredConfig](ConfigOptions{Files: []string{filepath.Join(dir, "missing.yaml")}})
+		assert.Error(t, err)
+	})
+
+	t.Run("ignored missing file", func(t *testing.T) {
+		t.Setenv("LAYER_NAME", "from-env")
+		_, _, err := LoadConfig[TestLayeredConfig](ConfigOptions{
+			Files:              []string{filepath.Join(dir, "missing.yaml")},
+			IgnoreMissingFiles: true,
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported file", func(t *testing.T) {
+		file := writeTestFile(t, dir, "config.ini", "name=test")
+		_, _, err := LoadConfig[TestLayeredConfig](ConfigOptions{Files: []string{file}})
+		assert.Error(t, err)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, _, err := LoadConfig[TestLayeredConfig](ConfigOptions{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,262 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	CrashPolicyRestart   = "restart"
+	CrashPolicyTerminate = "terminate"
+)
+
+// crashRestartDelay is the pause before a crashed goroutine is run again,
+// so that a persistent failure does not turn into a busy loop.
+const crashRestartDelay = 100 * time.Millisecond
+
+const crashWebhookTimeout = 5 * time.Second
+
+type CrashAction int
+
+const (
+	CrashRestart CrashAction = iota
+	CrashTerminate
+)
+
+// CrashReport describes a panic recovered in a goroutine guarded by the crash handler.
+type CrashReport struct {
+	Name      string    `json:"name"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+	Time      time.Time `json:"time"`
+	Crashes   int       `json:"crashes"`
+	Service   string    `json:"service,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	ServiceID string    `json:"serviceId,omitempty"`
+	Hostname  string    `json:"hostname,omitempty"`
+	GoVersion string    `json:"goVersion"`
+}
+
+// CrashReporter receives the crash reports, e.g. to store or forward them.
+type CrashReporter func(report CrashReport) error
+
+// CrashPolicy decides whether a crashed goroutine is restarted or the service terminated.
+type CrashPolicy func(report CrashReport) CrashAction
+
+var (
+	crashMu        sync.Mutex
+	crashPolicy    = RestartPolicy(0)
+	crashReporters = []CrashReporter{LogCrashReporter()}
+	crashCounts    = make(map[string]int)
+)
+
+var crashesTotal = RegisterMetric(prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "svc_crashes_total",
+	Help: "Number of panics recovered by the crash handler.",
+}, []string{"name"}))
+
+// RestartPolicy restarts the crashed goroutines until the same name crashed
+// more than maxCrashes times, then terminates the service.
+// With maxCrashes 0 the goroutines are always restarted.
+func RestartPolicy(maxCrashes int) CrashPolicy {
+	return func(report CrashReport) CrashAction {
+		if maxCrashes > 0 && report.Crashes > maxCrashes {
+			return CrashTerminate
+		}
+		return CrashRestart
+	}
+}
+
+// TerminatePolicy terminates the service at the first crash.
+func TerminatePolicy() CrashPolicy {
+	return func(report CrashReport) CrashAction {
+		return CrashTerminate
+	}
+}
+
+func SetCrashPolicy(policy CrashPolicy) {
+	crashMu.Lock()
+	crashPolicy = policy
+	crashMu.Unlock()
+}
+
+// AddCrashReporter adds a reporter to the crash handler.
+// The crashes are always logged, in addition to the added reporters.
+func AddCrashReporter(reporter CrashReporter) {
+	crashMu.Lock()
+	crashReporters = append(crashReporters, reporter)
+	crashMu.Unlock()
+}
+
+// Go runs fn in a new goroutine guarded by the crash handler.
+func Go(name string, fn func()) {
+	go Run(name, fn)
+}
+
+// Run runs fn guarded by the crash handler. When fn panics the crash is
+// reported and, as decided by the crash policy, fn is run again or the
+// service terminated. Run returns when fn returns without panicking.
+func Run(name string, fn func()) {
+	for !runGuarded(name, fn) {
+		time.Sleep(crashRestartDelay)
+	}
+}
+
+func runGuarded(name string, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			handleCrash(name, r)
+		}
+	}()
+	fn()
+	return true
+}
+
+// Recover reports the panic of the calling goroutine to the crash handler.
+// It must be deferred directly, for tasks that are not to be restarted:
+// the panic is only reported, unless the crash policy terminates the service.
+//
+//	defer svc.Recover("task")
+func Recover(name string) {
+	if r := recover(); r != nil {
+		handleCrash(name, r)
+	}
+}
+
+// ReportCrash reports the recovered panic r to the crash handler, for the
+// tasks that recover their panics themselves, as decided by the crash policy.
+func ReportCrash(name string, r any) {
+	handleCrash(name, r)
+}
+
+func handleCrash(name string, r any) {
+	report := newCrashReport(name, r, debug.Stack())
+
+	crashMu.Lock()
+	crashCounts[name]++
+	report.Crashes = crashCounts[name]
+	policy := crashPolicy
+	reporters := make([]CrashReporter, len(crashReporters))
+	copy(reporters, crashReporters)
+	crashMu.Unlock()
+
+	crashesTotal.WithLabelValues(name).Inc()
+
+	for _, reporter := range reporters {
+		if err := reporter(report); err != nil {
+			_ = Error("Cannot report crash", err, "name", name)
+		}
+	}
+
+	if policy(report) == CrashTerminate {
+		Logger().Error("Terminating service after crash", "name", name, "crashes", report.Crashes)
+		Exit(1)
+	}
+}
+
+func newCrashReport(name string, r any, stack []byte) CrashReport {
+	report := CrashReport{
+		Name:      name,
+		Panic:     fmt.Sprint(r),
+		Stack:     string(stack),
+		Time:      time.Now(),
+		ServiceID: ServiceID(),
+		GoVersion: runtime.Version(),
+	}
+	report.Hostname, _ = os.Hostname()
+
+	optionsMu.RLock()
+	if options != nil {
+		report.Service = options.Name
+		report.Version = options.Version
+	}
+	optionsMu.RUnlock()
+	return report
+}
+
+// LogCrashReporter logs the crash reports with the service logger.
+func LogCrashReporter() CrashReporter {
+	return func(report CrashReport) error {
+		Logger().Error("Panic recovered",
+			"name", report.Name,
+			"panic", report.Panic,
+			"crashes", report.Crashes,
+			"stack", report.Stack,
+		)
+		return nil
+	}
+}
+
+// FileCrashReporter writes each crash report as a JSON file in dir.
+func FileCrashReporter(dir string) CrashReporter {
+	return func(report CrashReport) error {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create crash dump directory: %w", err)
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode crash report: %w", err)
+		}
+		file := fmt.Sprintf("crash-%s-%s.json", report.Time.Format("20060102T150405.000000000"), crashFileName(report.Name))
+		return os.WriteFile(filepath.Join(dir, file), data, 0o644)
+	}
+}
+
+func crashFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// WebhookCrashReporter posts each crash report as JSON to url.
+func WebhookCrashReporter(url string) CrashReporter {
+	client := &http.Client{Timeout: crashWebhookTimeout}
+	return func(report CrashReport) error {
+		data, err := json.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("failed to encode crash report: %w", err)
+		}
+		res, err := client.Post(url, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to send crash report: %w", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("crash webhook responded with status %d", res.StatusCode)
+		}
+		return nil
+	}
+}
+
+// initCrashHandler configures the crash handler from the service environment.
+func initCrashHandler(env DefaultEnv) error {
+	switch strings.ToLower(env.CrashPolicy) {
+	case "", CrashPolicyRestart:
+		SetCrashPolicy(RestartPolicy(env.CrashMaxRestarts))
+	case CrashPolicyTerminate:
+		SetCrashPolicy(TerminatePolicy())
+	default:
+		return fmt.Errorf("unknown crash policy %q", env.CrashPolicy)
+	}
+	if env.CrashDumpDir != "" {
+		AddCrashReporter(FileCrashReporter(env.CrashDumpDir))
+	}
+	if env.CrashWebhookURL != "" {
+		AddCrashReporter(WebhookCrashReporter(env.CrashWebhookURL))
+	}
+	return nil
+}
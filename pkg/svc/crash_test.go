@@ -0,0 +1,119 @@
+package svc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCrashTest(t *testing.T) *[]CrashReport {
+	crashMu.Lock()
+	prevPolicy, prevReporters := crashPolicy, crashReporters
+	crashCounts = make(map[string]int)
+	crashMu.Unlock()
+
+	reports := []CrashReport{}
+	crashMu.Lock()
+	crashReporters = []CrashReporter{func(report CrashReport) error {
+		reports = append(reports, report)
+		return nil
+	}}
+	crashMu.Unlock()
+
+	t.Cleanup(func() {
+		crashMu.Lock()
+		crashPolicy, crashReporters = prevPolicy, prevReporters
+		crashMu.Unlock()
+	})
+	return &reports
+}
+
+func TestRunRestartsAfterPanic(t *testing.T) {
+	reports := setupCrashTest(t)
+	SetCrashPolicy(RestartPolicy(0))
+
+	calls := 0
+	Run("test.restart", func() {
+		calls++
+		if calls < 3 {
+			panic("boom")
+		}
+	})
+
+	assert.Equal(t, 3, calls)
+	require.Len(t, *reports, 2)
+	assert.Equal(t, "test.restart", (*reports)[1].Name)
+	assert.Equal(t, "boom", (*reports)[1].Panic)
+	assert.Equal(t, 2, (*reports)[1].Crashes)
+	assert.Contains(t, (*reports)[1].Stack, "TestRunRestartsAfterPanic")
+}
+
+func TestRecoverTerminatesByPolicy(t *testing.T) {
+	reports := setupCrashTest(t)
+	SetCrashPolicy(RestartPolicy(1))
+
+	originalOsExit := osExit
+	defer func() { osExit = originalOsExit }()
+	exitCode := -1
+	osExit = func(code int) { exitCode = code }
+
+	task := func() {
+		defer Recover("test.recover")
+		panic("boom")
+	}
+
+	task()
+	assert.Equal(t, -1, exitCode)
+
+	task()
+	assert.Equal(t, 1, exitCode)
+	assert.Len(t, *reports, 2)
+}
+
+func TestFileCrashReporter(t *testing.T) {
+	dir := t.TempDir()
+	report := newCrashReport("test/file", "boom", []byte("stack"))
+	require.NoError(t, FileCrashReporter(dir)(report))
+
+	files, err := filepath.Glob(filepath.Join(dir, "crash-*-test_file.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	var res CrashReport
+	require.NoError(t, json.Unmarshal(data, &res))
+	assert.Equal(t, "boom", res.Panic)
+	assert.Equal(t, "stack", res.Stack)
+}
+
+func TestWebhookCrashReporter(t *testing.T) {
+	var received CrashReport
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	require.NoError(t, WebhookCrashReporter(server.URL)(newCrashReport("test.webhook", "boom", nil)))
+	assert.Equal(t, "test.webhook", received.Name)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	assert.Error(t, WebhookCrashReporter(failing.URL)(newCrashReport("test.webhook", "boom", nil)))
+}
+
+func TestInitCrashHandler(t *testing.T) {
+	setupCrashTest(t)
+	assert.NoError(t, initCrashHandler(DefaultEnv{CrashPolicy: "terminate"}))
+	assert.Equal(t, CrashTerminate, crashPolicy(CrashReport{Crashes: 1}))
+	assert.Error(t, initCrashHandler(DefaultEnv{CrashPolicy: "ignore"}))
+}
@@ -0,0 +1,299 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrProviderExists  = errors.New("provider already registered")
+	ErrNoProvider      = errors.New("no provider registered")
+	ErrNoRequestScope  = errors.New("request scoped dependency resolved outside of a request scope")
+	ErrDependencyCycle = errors.New("dependency cycle")
+	ErrContainerClosed = errors.New("container closed")
+)
+
+// Scope is the lifetime of the instances of a provider.
+type Scope int
+
+const (
+	// Singleton instances are created once, at the first resolution, and
+	// closed with the container.
+	Singleton Scope = iota
+	// Transient instances are created at each resolution and never closed
+	// by the container.
+	Transient
+	// Request instances are created once per request scope and closed with it.
+	Request
+)
+
+func (s Scope) String() string {
+	switch s {
+	case Singleton:
+		return "singleton"
+	case Transient:
+		return "transient"
+	case Request:
+		return "request"
+	}
+	return fmt.Sprintf("Scope(%d)", int(s))
+}
+
+// Provider creates the instances of T.
+type Provider[T any] struct {
+	Scope Scope
+	// New creates an instance, resolving its dependencies from c with ctx.
+	New func(ctx context.Context, c *Container) (T, error)
+	// Close releases an instance. When nil, the instances implementing
+	// io.Closer, or with a Close() or Close(context.Context) error method,
+	// are closed with it.
+	Close func(ctx context.Context, v T) error
+}
+
+// Container resolves the dependencies of the service by type. The
+// singletons are closed in reverse construction order on Close, called by
+// Exit after the components are stopped.
+type Container struct {
+	mu      sync.Mutex
+	entries map[reflect.Type]*diEntry
+	closers []diCloser
+	closed  bool
+	exit    sync.Once
+}
+
+type diEntry struct {
+	scope Scope
+	new   func(ctx context.Context, c *Container) (any, error)
+	close func(ctx context.Context, v any) error
+
+	mu    sync.Mutex
+	ok    bool
+	value any
+}
+
+type diCloser struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// NewContainer creates an empty container.
+func NewContainer() *Container {
+	return &Container{entries: make(map[reflect.Type]*diEntry)}
+}
+
+// Provide registers the provider of T, returning ErrProviderExists if T
+// already has one.
+func Provide[T any](c *Container, p Provider[T]) error {
+	t := reflect.TypeFor[T]()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[t]; ok {
+		return fmt.Errorf("%w: %s", ErrProviderExists, t)
+	}
+	e := &diEntry{
+		scope: p.Scope,
+		new: func(ctx context.Context, c *Container) (any, error) {
+			return p.New(ctx, c)
+		},
+	}
+	if p.Close != nil {
+		e.close = func(ctx context.Context, v any) error {
+			return p.Close(ctx, v.(T))
+		}
+	}
+	c.entries[t] = e
+	return nil
+}
+
+// Override replaces the provider of T with the given value, as a fake in
+// the tests. The value is not closed by the container.
+func Override[T any](c *Container, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[reflect.TypeFor[T]()] = &diEntry{scope: Singleton, ok: true, value: value}
+}
+
+// Resolve returns the instance of T, creating it and its dependencies when needed.
+func Resolve[T any](ctx context.Context, c *Container) (T, error) {
+	var zero T
+	v, err := c.resolve(ctx, reflect.TypeFor[T]())
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// MustResolve is as Resolve, panicking on errors.
+func MustResolve[T any](ctx context.Context, c *Container) T {
+	return PanicWithError(Resolve[T](ctx, c))
+}
+
+type diChainKey struct{}
+
+type diScopeKey struct{}
+
+func (c *Container) resolve(ctx context.Context, t reflect.Type) (any, error) {
+	c.mu.Lock()
+	e, ok := c.entries[t]
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrContainerClosed
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoProvider, t)
+	}
+
+	chain, _ := ctx.Value(diChainKey{}).([]reflect.Type)
+	if slices.Contains(chain, t) {
+		return nil, fmt.Errorf("%w: %v", ErrDependencyCycle, append(chain, t))
+	}
+	ctx = context.WithValue(ctx, diChainKey{}, append(slices.Clip(chain), t))
+
+	switch e.scope {
+	case Transient:
+		return e.new(ctx, c)
+	case Request:
+		s, _ := ctx.Value(diScopeKey{}).(*diScope)
+		if s == nil || s.c != c {
+			return nil, fmt.Errorf("%w: %s", ErrNoRequestScope, t)
+		}
+		return s.resolve(ctx, t, e)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ok {
+		return e.value, nil
+	}
+	// The singletons must not capture the instances of the current request
+	v, err := e.new(context.WithValue(ctx, diScopeKey{}, (*diScope)(nil)), c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s: %w", t, err)
+	}
+	e.value, e.ok = v, true
+
+	if cl := closerOf(e, v); cl != nil {
+		c.mu.Lock()
+		c.closers = append(c.closers, diCloser{name: t.String(), close: cl})
+		c.mu.Unlock()
+		c.exit.Do(func() {
+			OnExit(func() {
+				ctx, cancel := context.WithTimeout(context.Background(), getShutdownTimeout())
+				defer cancel()
+				_ = c.Close(ctx)
+			})
+		})
+	}
+	return v, nil
+}
+
+// Close closes the singletons in reverse construction order, returning
+// the joined errors. The container cannot be used afterwards.
+func (c *Container) Close(ctx context.Context) error {
+	c.mu.Lock()
+	closers := c.closers
+	c.closers = nil
+	c.closed = true
+	c.mu.Unlock()
+	return closeAll(ctx, closers)
+}
+
+// BeginScope returns the context carrying a new request scope, and the
+// function closing its instances in reverse construction order.
+func (c *Container) BeginScope(ctx context.Context) (context.Context, func()) {
+	s := &diScope{c: c, instances: make(map[reflect.Type]*diInstance)}
+	return context.WithValue(ctx, diScopeKey{}, s), func() {
+		s.mu.Lock()
+		closers := s.closers
+		s.closers = nil
+		s.mu.Unlock()
+		_ = closeAll(context.WithoutCancel(ctx), closers)
+	}
+}
+
+// ScopeHandler wraps next to serve each request within a request scope of c.
+func (c *Container) ScopeHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, end := c.BeginScope(r.Context())
+		defer end()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type diScope struct {
+	c         *Container
+	mu        sync.Mutex
+	instances map[reflect.Type]*diInstance
+	closers   []diCloser
+}
+
+type diInstance struct {
+	mu    sync.Mutex
+	ok    bool
+	value any
+}
+
+func (s *diScope) resolve(ctx context.Context, t reflect.Type, e *diEntry) (any, error) {
+	s.mu.Lock()
+	inst, ok := s.instances[t]
+	if !ok {
+		inst = &diInstance{}
+		s.instances[t] = inst
+	}
+	s.mu.Unlock()
+
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	if inst.ok {
+		return inst.value, nil
+	}
+	v, err := e.new(ctx, s.c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s: %w", t, err)
+	}
+	inst.value, inst.ok = v, true
+
+	if cl := closerOf(e, v); cl != nil {
+		s.mu.Lock()
+		s.closers = append(s.closers, diCloser{name: t.String(), close: cl})
+		s.mu.Unlock()
+	}
+	return v, nil
+}
+
+// closerOf returns the function closing the instance v of e, nil if not closable.
+func closerOf(e *diEntry, v any) func(ctx context.Context) error {
+	if e.close != nil {
+		return func(ctx context.Context) error { return e.close(ctx, v) }
+	}
+	switch c := v.(type) {
+	case io.Closer:
+		return func(ctx context.Context) error { return c.Close() }
+	case interface{ Close(context.Context) error }:
+		return c.Close
+	case interface{ Close() }:
+		return func(ctx context.Context) error {
+			c.Close()
+			return nil
+		}
+	}
+	return nil
+}
+
+func closeAll(ctx context.Context, closers []diCloser) error {
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].close(ctx); err != nil {
+			Logger().ErrorContext(ctx, "Cannot close dependency", "type", closers[i].name, "error", err)
+			errs = append(errs, fmt.Errorf("cannot close %s: %w", closers[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
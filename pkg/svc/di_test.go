@@ -0,0 +1,221 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type diLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *diLog) add(s string) {
+	l.mu.Lock()
+	l.calls = append(l.calls, s)
+	l.mu.Unlock()
+}
+
+type diConfig struct{ dsn string }
+
+type diDB struct {
+	cfg *diConfig
+	log *diLog
+}
+
+func (d *diDB) Close() error {
+	d.log.add("close db")
+	return nil
+}
+
+type diRepo struct {
+	db  *diDB
+	log *diLog
+}
+
+func (r *diRepo) Close(ctx context.Context) error {
+	r.log.add("close repo")
+	return nil
+}
+
+type diTx struct {
+	repo *diRepo
+	log  *diLog
+}
+
+func (t *diTx) Close() {
+	t.log.add("close tx")
+}
+
+func newDIContainer(t *testing.T, log *diLog) *Container {
+	c := NewContainer()
+	assert.NoError(t, Provide(c, Provider[*diConfig]{
+		New: func(ctx context.Context, c *Container) (*diConfig, error) {
+			log.add("new config")
+			return &diConfig{dsn: "postgres://"}, nil
+		},
+	}))
+	assert.NoError(t, Provide(c, Provider[*diDB]{
+		New: func(ctx context.Context, c *Container) (*diDB, error) {
+			cfg, err := Resolve[*diConfig](ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			log.add("new db")
+			return &diDB{cfg: cfg, log: log}, nil
+		},
+	}))
+	assert.NoError(t, Provide(c, Provider[*diRepo]{
+		Scope: Transient,
+		New: func(ctx context.Context, c *Container) (*diRepo, error) {
+			db, err := Resolve[*diDB](ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			return &diRepo{db: db, log: log}, nil
+		},
+	}))
+	assert.NoError(t, Provide(c, Provider[*diTx]{
+		Scope: Request,
+		New: func(ctx context.Context, c *Container) (*diTx, error) {
+			repo, err := Resolve[*diRepo](ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			log.add("new tx")
+			return &diTx{repo: repo, log: log}, nil
+		},
+	}))
+	return c
+}
+
+func TestContainerScopes(t *testing.T) {
+	log := &diLog{}
+	c := newDIContainer(t, log)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, Provide(c, Provider[*diDB]{}), ErrProviderExists)
+
+	r1, err := Resolve[*diRepo](ctx, c)
+	assert.NoError(t, err)
+	r2 := MustResolve[*diRepo](ctx, c)
+	assert.NotSame(t, r1, r2, "transient instances are created at each resolution")
+	assert.Same(t, r1.db, r2.db, "singletons are shared")
+	assert.Equal(t, "postgres://", r1.db.cfg.dsn)
+
+	_, err = Resolve[*diTx](ctx, c)
+	assert.ErrorIs(t, err, ErrNoRequestScope)
+
+	scoped, end := c.BeginScope(ctx)
+	tx1 := MustResolve[*diTx](scoped, c)
+	tx2 := MustResolve[*diTx](scoped, c)
+	assert.Same(t, tx1, tx2, "request instances are shared within the scope")
+	end()
+
+	other, endOther := c.BeginScope(ctx)
+	assert.NotSame(t, tx1, MustResolve[*diTx](other, c))
+	endOther()
+
+	_, err = Resolve[*diLog](ctx, c)
+	assert.ErrorIs(t, err, ErrNoProvider)
+
+	assert.NoError(t, c.Close(ctx))
+	assert.Equal(t, []string{"new config", "new db", "new tx", "close tx", "new tx", "close tx", "close db"}, log.calls)
+
+	_, err = Resolve[*diDB](ctx, c)
+	assert.ErrorIs(t, err, ErrContainerClosed)
+}
+
+func TestContainerCloseOrder(t *testing.T) {
+	log := &diLog{}
+	c := NewContainer()
+	assert.NoError(t, Provide(c, Provider[*diDB]{
+		New: func(ctx context.Context, c *Container) (*diDB, error) {
+			return &diDB{log: log}, nil
+		},
+	}))
+	assert.NoError(t, Provide(c, Provider[*diRepo]{
+		New: func(ctx context.Context, c *Container) (*diRepo, error) {
+			db, err := Resolve[*diDB](ctx, c)
+			return &diRepo{db: db, log: log}, err
+		},
+	}))
+	assert.NoError(t, Provide(c, Provider[*diConfig]{
+		New: func(ctx context.Context, c *Container) (*diConfig, error) {
+			return &diConfig{}, nil
+		},
+		Close: func(ctx context.Context, v *diConfig) error {
+			return errors.New("config busy")
+		},
+	}))
+
+	MustResolve[*diConfig](context.Background(), c)
+	MustResolve[*diRepo](context.Background(), c)
+	err := c.Close(context.Background())
+	assert.ErrorContains(t, err, "config busy")
+	assert.Equal(t, []string{"close repo", "close db"}, log.calls, "dependents are closed before their dependencies")
+}
+
+func TestContainerOverride(t *testing.T) {
+	log := &diLog{}
+	c := newDIContainer(t, log)
+	fake := &diDB{cfg: &diConfig{dsn: "fake"}, log: log}
+	Override(c, fake)
+
+	repo := MustResolve[*diRepo](context.Background(), c)
+	assert.Same(t, fake, repo.db)
+	assert.NoError(t, c.Close(context.Background()))
+	assert.Empty(t, log.calls, "overrides are not created nor closed by the container")
+}
+
+func TestContainerErrors(t *testing.T) {
+	type a struct{}
+	type b struct{}
+	c := NewContainer()
+	assert.NoError(t, Provide(c, Provider[*a]{
+		New: func(ctx context.Context, c *Container) (*a, error) {
+			_, err := Resolve[*b](ctx, c)
+			return &a{}, err
+		},
+	}))
+	assert.NoError(t, Provide(c, Provider[*b]{
+		New: func(ctx context.Context, c *Container) (*b, error) {
+			_, err := Resolve[*a](ctx, c)
+			return &b{}, err
+		},
+	}))
+	_, err := Resolve[*a](context.Background(), c)
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+
+	log := &diLog{}
+	c = newDIContainer(t, log)
+	assert.NoError(t, Provide(c, Provider[*diLog]{
+		New: func(ctx context.Context, c *Container) (*diLog, error) {
+			_, err := Resolve[*diTx](ctx, c)
+			return log, err
+		},
+	}))
+	scoped, end := c.BeginScope(context.Background())
+	defer end()
+	_, err = Resolve[*diLog](scoped, c)
+	assert.ErrorIs(t, err, ErrNoRequestScope, "singletons cannot depend on request instances")
+}
+
+func TestContainerScopeHandler(t *testing.T) {
+	log := &diLog{}
+	c := newDIContainer(t, log)
+	handler := c.ScopeHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tx := MustResolve[*diTx](r.Context(), c)
+		assert.Same(t, tx, MustResolve[*diTx](r.Context(), c))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"new config", "new db", "new tx", "close tx", "new tx", "close tx"}, log.calls)
+}
@@ -1,6 +1,11 @@
 package envtype
 
-import "encoding/base64"
+import (
+	"encoding/base64"
+	"log/slog"
+)
+
+const passwordMask = "******"
 
 type Password []byte
 
@@ -11,3 +16,13 @@ func (p *Password) UnmarshalText(text []byte) (err error) {
 	}
 	return
 }
+
+// String masks the password, so that it is never printed.
+func (p Password) String() string {
+	return passwordMask
+}
+
+// LogValue masks the password in the log records.
+func (p Password) LogValue() slog.Value {
+	return slog.StringValue(passwordMask)
+}
@@ -0,0 +1,187 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+type HealthStatus string
+
+const (
+	HealthStatusUp   HealthStatus = "UP"
+	HealthStatusDown HealthStatus = "DOWN"
+)
+
+type HealthCheckKind int
+
+const (
+	// Readiness checks tell whether the service can receive traffic,
+	// typically by verifying its dependencies.
+	Readiness HealthCheckKind = 1 << iota
+	// Liveness checks tell whether the service process must be restarted.
+	Liveness
+)
+
+// HealthCheckFunc checks a dependency or an internal state,
+// returning an error when it is not healthy.
+type HealthCheckFunc func(ctx context.Context) error
+
+type healthCheck struct {
+	name  string
+	kind  HealthCheckKind
+	check HealthCheckFunc
+}
+
+type HealthCheckResult struct {
+	Status   HealthStatus  `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+type HealthReport struct {
+	Status HealthStatus                 `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+func (r HealthReport) IsUp() bool {
+	return r.Status == HealthStatusUp
+}
+
+var ErrServiceNotReady = errors.New("service not ready")
+
+var (
+	healthChecksMu sync.RWMutex
+	healthChecks   = make([]healthCheck, 0)
+	notReady       atomic.Bool
+)
+
+// AddHealthCheck registers a health check, replacing the one with the same name.
+// When no kind is specified the check is used for readiness.
+func AddHealthCheck(name string, fn HealthCheckFunc, kinds ...HealthCheckKind) {
+	var kind HealthCheckKind
+	for _, k := range kinds {
+		kind |= k
+	}
+	if kind == 0 {
+		kind = Readiness
+	}
+	check := healthCheck{
+		name:  name,
+		kind:  kind,
+		check: fn,
+	}
+	healthChecksMu.Lock()
+	defer healthChecksMu.Unlock()
+	for i, c := range healthChecks {
+		if c.name == name {
+			healthChecks[i] = check
+			return
+		}
+	}
+	healthChecks = append(healthChecks, check)
+}
+
+// SetReady allows to manually flip the service readiness.
+// The service is flagged as not ready while starting and during shutdown.
+func SetReady(ready bool) {
+	notReady.Store(!ready)
+}
+
+func IsReady() bool {
+	return !notReady.Load()
+}
+
+// CheckHealth runs all the registered health checks.
+func CheckHealth(ctx context.Context) HealthReport {
+	return runHealthChecks(ctx, Readiness|Liveness)
+}
+
+// CheckReadiness runs the readiness health checks.
+// The report is down whenever the service is flagged as not ready.
+func CheckReadiness(ctx context.Context) HealthReport {
+	report := runHealthChecks(ctx, Readiness)
+	if !IsReady() {
+		report.Status = HealthStatusDown
+		report.Checks["service"] = HealthCheckResult{
+			Status: HealthStatusDown,
+			Error:  ErrServiceNotReady.Error(),
+		}
+	}
+	return report
+}
+
+// CheckLiveness runs the liveness health checks.
+func CheckLiveness(ctx context.Context) HealthReport {
+	return runHealthChecks(ctx, Liveness)
+}
+
+func runHealthChecks(ctx context.Context, kind HealthCheckKind) HealthReport {
+	healthChecksMu.RLock()
+	checks := make([]healthCheck, 0, len(healthChecks))
+	for _, c := range healthChecks {
+		if c.kind&kind != 0 {
+			checks = append(checks, c)
+		}
+	}
+	healthChecksMu.RUnlock()
+
+	report := HealthReport{
+		Status: HealthStatusUp,
+		Checks: make(map[string]HealthCheckResult, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c healthCheck) {
+			defer wg.Done()
+			res := runHealthCheck(ctx, c)
+			mu.Lock()
+			report.Checks[c.name] = res
+			if res.Status != HealthStatusUp {
+				report.Status = HealthStatusDown
+			}
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func runHealthCheck(ctx context.Context, c healthCheck) (res HealthCheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultHealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.New("health check panic")
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res.Duration = time.Since(start)
+	res.Status = HealthStatusUp
+	if err != nil {
+		res.Status = HealthStatusDown
+		res.Error = err.Error()
+	}
+	return
+}
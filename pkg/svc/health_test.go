@@ -0,0 +1,66 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetHealthChecks() {
+	healthChecksMu.Lock()
+	healthChecks = make([]healthCheck, 0)
+	healthChecksMu.Unlock()
+	SetReady(true)
+}
+
+func TestHealthChecks(t *testing.T) {
+	resetHealthChecks()
+	defer resetHealthChecks()
+
+	AddHealthCheck("db", func(ctx context.Context) error { return nil })
+	AddHealthCheck("process", func(ctx context.Context) error { return nil }, Liveness)
+
+	report := CheckHealth(context.Background())
+	assert.True(t, report.IsUp())
+	assert.Len(t, report.Checks, 2)
+
+	report = CheckLiveness(context.Background())
+	assert.True(t, report.IsUp())
+	assert.Contains(t, report.Checks, "process")
+	assert.NotContains(t, report.Checks, "db")
+
+	AddHealthCheck("db", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	report = CheckReadiness(context.Background())
+	assert.False(t, report.IsUp())
+	assert.Equal(t, HealthStatusDown, report.Checks["db"].Status)
+	assert.Equal(t, "connection refused", report.Checks["db"].Error)
+
+	assert.True(t, CheckLiveness(context.Background()).IsUp())
+}
+
+func TestReadinessDuringShutdown(t *testing.T) {
+	resetHealthChecks()
+	defer resetHealthChecks()
+
+	assert.True(t, CheckReadiness(context.Background()).IsUp())
+
+	SetReady(false)
+	report := CheckReadiness(context.Background())
+	assert.False(t, report.IsUp())
+	assert.Equal(t, ErrServiceNotReady.Error(), report.Checks["service"].Error)
+	assert.True(t, CheckLiveness(context.Background()).IsUp())
+}
+
+func TestHealthCheckPanic(t *testing.T) {
+	resetHealthChecks()
+	defer resetHealthChecks()
+
+	AddHealthCheck("panic", func(ctx context.Context) error { panic("boom") })
+
+	report := CheckHealth(context.Background())
+	assert.False(t, report.IsUp())
+	assert.Equal(t, HealthStatusDown, report.Checks["panic"].Status)
+}
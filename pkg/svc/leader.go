@@ -0,0 +1,192 @@
+package svc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultLeaderInterval = 5 * time.Second
+
+// LeaderLock is a lock that can be held by a single replica at a time.
+type LeaderLock interface {
+	// Acquire tries to take the lock, reporting whether it is now held.
+	Acquire(ctx context.Context) (bool, error)
+	// Renew extends the lock, reporting whether it is still held.
+	Renew(ctx context.Context) (bool, error)
+	// Release gives up the lock.
+	Release(ctx context.Context) error
+}
+
+type LeaderOptions struct {
+	// Interval between the attempts to acquire the lock and its renewals.
+	Interval time.Duration
+	// TTL is the validity of the lock after its acquisition or renewal.
+	// The leadership is kept through the failed renewals while the TTL
+	// does not expire before the next renewal. With zero TTL the leadership
+	// is lost at the first failed renewal.
+	TTL time.Duration
+	// OnElected is run in a new goroutine when the leadership is gained.
+	// Its context is cancelled when the leadership is lost.
+	OnElected func(ctx context.Context)
+	// OnRevoked is called when the leadership is lost or released.
+	OnRevoked func()
+}
+
+// Leader elects one replica among the ones sharing the same lock.
+// The election runs while its component is started, and the leadership
+// is released when the component stops, also on Exit.
+type Leader struct {
+	name    string
+	lock    LeaderLock
+	opts    LeaderOptions
+	mu      sync.RWMutex
+	leading bool
+	revoke  context.CancelFunc
+	cancel  context.CancelFunc
+	done    chan struct{}
+	// renewed is the start of the last successful acquisition or renewal
+	renewed time.Time
+}
+
+var leaderStatus = RegisterMetric(NewGaugeSet(
+	"svc_leader",
+	"Whether the replica holds the leadership (1) or not (0).",
+	"name",
+))
+
+func NewLeader(name string, lock LeaderLock, opts LeaderOptions) *Leader {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultLeaderInterval
+	}
+	return &Leader{name: name, lock: lock, opts: opts}
+}
+
+func (l *Leader) IsLeader() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.leading
+}
+
+// Component returns the svc lifecycle component running the election.
+func (l *Leader) Component(dependsOn ...string) Component {
+	return Component{
+		Name:      l.name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			l.start()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return l.stop(ctx)
+		},
+	}
+}
+
+func (l *Leader) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	leaderStatus.Set(l.name, func() float64 {
+		if l.IsLeader() {
+			return 1
+		}
+		return 0
+	})
+	// run is restarted on panic, done is closed once it returns
+	go func() {
+		defer close(l.done)
+		Run("leader."+l.name, func() { l.run(ctx) })
+	}()
+}
+
+func (l *Leader) stop(ctx context.Context) error {
+	if l.cancel == nil {
+		return nil
+	}
+	l.cancel()
+	<-l.done
+	leaderStatus.Delete(l.name)
+	if !l.IsLeader() {
+		return nil
+	}
+	l.revoked()
+	return l.lock.Release(ctx)
+}
+
+func (l *Leader) run(ctx context.Context) {
+	ticker := time.NewTicker(l.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		l.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *Leader) check(ctx context.Context) {
+	start := time.Now()
+	if l.IsLeader() {
+		held, err := l.lock.Renew(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			_ = Error("Cannot renew leadership", err, "name", l.name)
+			// The lock is still held until its TTL expires
+			if time.Since(l.renewed)+l.opts.Interval < l.opts.TTL {
+				return
+			}
+		} else if held {
+			l.renewed = start
+			return
+		}
+		Logger().Warn("Leadership lost", "name", l.name)
+		l.revoked()
+		return
+	}
+
+	held, err := l.lock.Acquire(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			_ = Error("Cannot acquire leadership", err, "name", l.name)
+		}
+		return
+	}
+	if held {
+		l.renewed = start
+		l.elected()
+	}
+}
+
+func (l *Leader) elected() {
+	ctx, revoke := context.WithCancel(context.Background())
+	l.mu.Lock()
+	l.leading = true
+	l.revoke = revoke
+	l.mu.Unlock()
+
+	Logger().Info("Leadership gained", "name", l.name)
+	if l.opts.OnElected != nil {
+		Go("leader."+l.name, func() { l.opts.OnElected(ctx) })
+	}
+}
+
+func (l *Leader) revoked() {
+	l.mu.Lock()
+	l.leading = false
+	revoke := l.revoke
+	l.revoke = nil
+	l.mu.Unlock()
+
+	if revoke != nil {
+		revoke()
+	}
+	if l.opts.OnRevoked != nil {
+		l.opts.OnRevoked()
+	}
+}
@@ -0,0 +1,166 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryLock is a LeaderLock shared by the leaders of the same test.
+type memoryLock struct {
+	mu     *sync.Mutex
+	holder *string
+	id     string
+}
+
+func (l memoryLock) Acquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if *l.holder == "" {
+		*l.holder = l.id
+	}
+	return *l.holder == l.id, nil
+}
+
+func (l memoryLock) Renew(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return *l.holder == l.id, nil
+}
+
+func (l memoryLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if *l.holder == l.id {
+		*l.holder = ""
+	}
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	mu := &sync.Mutex{}
+	holder := ""
+	interval := 10 * time.Millisecond
+
+	elected := make(chan string, 2)
+	revoked := make(chan string, 2)
+	newLeader := func(id string) *Leader {
+		return NewLeader("test-"+id, memoryLock{mu: mu, holder: &holder, id: id}, LeaderOptions{
+			Interval: interval,
+			OnElected: func(ctx context.Context) {
+				elected <- id
+				<-ctx.Done()
+			},
+			OnRevoked: func() { revoked <- id },
+		})
+	}
+
+	a, b := newLeader("a"), newLeader("b")
+	ca, cb := a.Component(), b.Component()
+	require.NoError(t, ca.Start(context.Background()))
+	assert.Equal(t, "a", <-elected)
+	require.NoError(t, cb.Start(context.Background()))
+
+	time.Sleep(5 * interval)
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+
+	require.NoError(t, ca.Stop(context.Background()))
+	assert.Equal(t, "a", <-revoked)
+	assert.False(t, a.IsLeader())
+
+	assert.Equal(t, "b", <-elected)
+	assert.True(t, b.IsLeader())
+
+	mu.Lock()
+	holder = "other"
+	mu.Unlock()
+	assert.Equal(t, "b", <-revoked)
+	assert.False(t, b.IsLeader())
+	require.NoError(t, cb.Stop(context.Background()))
+}
+
+// panicLock panics on the first acquisition.
+type panicLock struct {
+	memoryLock
+	panicked *bool
+}
+
+func (l panicLock) Acquire(ctx context.Context) (bool, error) {
+	if !*l.panicked {
+		*l.panicked = true
+		panic("acquire failure")
+	}
+	return l.memoryLock.Acquire(ctx)
+}
+
+func TestLeaderRestartAfterPanic(t *testing.T) {
+	holder := ""
+	panicked := false
+	elected := make(chan struct{}, 1)
+	l := NewLeader("test-panic", panicLock{memoryLock{mu: &sync.Mutex{}, holder: &holder, id: "a"}, &panicked}, LeaderOptions{
+		Interval: 10 * time.Millisecond,
+		OnElected: func(ctx context.Context) {
+			elected <- struct{}{}
+			<-ctx.Done()
+		},
+	})
+	c := l.Component()
+	require.NoError(t, c.Start(context.Background()))
+	select {
+	case <-elected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("leader not elected after the restart")
+	}
+	require.NoError(t, c.Stop(context.Background()))
+	assert.False(t, l.IsLeader())
+}
+
+// failingLock fails the renewals while failing is set.
+type failingLock struct {
+	memoryLock
+	failing *atomic.Bool
+}
+
+func (l failingLock) Renew(ctx context.Context) (bool, error) {
+	if l.failing.Load() {
+		return false, errors.New("renew failure")
+	}
+	return l.memoryLock.Renew(ctx)
+}
+
+func TestLeaderKeptUntilTTL(t *testing.T) {
+	interval := 10 * time.Millisecond
+	failing := &atomic.Bool{}
+	elected := make(chan struct{}, 1)
+	revoked := make(chan time.Time, 1)
+	holder := ""
+	l := NewLeader("test-ttl", failingLock{memoryLock{mu: &sync.Mutex{}, holder: &holder, id: "a"}, failing}, LeaderOptions{
+		Interval:  interval,
+		TTL:       20 * interval,
+		OnElected: func(ctx context.Context) { elected <- struct{}{} },
+		OnRevoked: func() { revoked <- time.Now() },
+	})
+	c := l.Component()
+	require.NoError(t, c.Start(context.Background()))
+	defer func() {
+		_ = c.Stop(context.Background())
+	}()
+	<-elected
+
+	failing.Store(true)
+	start := time.Now()
+	select {
+	case at := <-revoked:
+		assert.GreaterOrEqual(t, at.Sub(start), 15*interval)
+		assert.False(t, l.IsLeader())
+	case <-time.After(2 * time.Second):
+		t.Fatal("leadership kept after the TTL")
+	}
+}
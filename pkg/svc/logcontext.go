@@ -0,0 +1,106 @@
+package svc
+
+import (
+	"context"
+	"log/slog"
+)
+
+const (
+	LogKeyRequestID = "request_id"
+	LogKeyTenant    = "tenant"
+	LogKeyUser      = "user"
+)
+
+type logAttrsKey struct{}
+
+// WithLogAttrs returns a copy of ctx carrying the attributes, which are added
+// to every record logged with the context by the service logger.
+// The arguments are converted to attributes as in slog.Logger.Log.
+func WithLogAttrs(ctx context.Context, args ...any) context.Context {
+	current := LogAttrs(ctx)
+	attrs := make([]slog.Attr, len(current), len(current)+len(args))
+	copy(attrs, current)
+	r := slog.Record{}
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = setAttr(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, logAttrsKey{}, attrs)
+}
+
+// setAttr replaces the attribute with the same key, or appends it.
+func setAttr(attrs []slog.Attr, attr slog.Attr) []slog.Attr {
+	for i, a := range attrs {
+		if a.Key == attr.Key {
+			attrs[i] = attr
+			return attrs
+		}
+	}
+	return append(attrs, attr)
+}
+
+// LogAttrs returns the log attributes carried by ctx.
+func LogAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+func logAttrValue(ctx context.Context, key string) string {
+	for _, a := range LogAttrs(ctx) {
+		if a.Key == key {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return WithLogAttrs(ctx, LogKeyRequestID, id)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string.
+func RequestID(ctx context.Context) string {
+	return logAttrValue(ctx, LogKeyRequestID)
+}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return WithLogAttrs(ctx, LogKeyTenant, tenant)
+}
+
+// Tenant returns the tenant carried by ctx, or an empty string.
+func Tenant(ctx context.Context) string {
+	return logAttrValue(ctx, LogKeyTenant)
+}
+
+func WithUser(ctx context.Context, user string) context.Context {
+	return WithLogAttrs(ctx, LogKeyUser, user)
+}
+
+// User returns the user carried by ctx, or an empty string.
+func User(ctx context.Context) string {
+	return logAttrValue(ctx, LogKeyUser)
+}
+
+// contextHandler adds the log attributes carried by the context to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := LogAttrs(ctx); len(attrs) > 0 {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,44 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLogAttrs(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithTenant(ctx, "acme")
+	child := WithUser(ctx, "john")
+	child = WithRequestID(child, "req-2")
+
+	assert.Equal(t, "req-1", RequestID(ctx))
+	assert.Equal(t, "", User(ctx))
+	assert.Equal(t, "req-2", RequestID(child))
+	assert.Equal(t, "acme", Tenant(child))
+	assert.Equal(t, "john", User(child))
+	assert.Len(t, LogAttrs(child), 3)
+	assert.Empty(t, LogAttrs(context.Background()))
+}
+
+func TestContextHandler(t *testing.T) {
+	setupTest()
+
+	var buf bytes.Buffer
+	logger = slog.New(contextHandler{slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: loggerLevel})})
+
+	ctx := WithLogAttrs(WithRequestID(context.Background(), "req-1"), "key", "value")
+	_ = ErrorContext(ctx, "error message", errors.New("test error"))
+
+	var logEntry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &logEntry))
+	assert.Equal(t, "req-1", logEntry[LogKeyRequestID])
+	assert.Equal(t, "value", logEntry["key"])
+	assert.Contains(t, logEntry["err"], "test error")
+}
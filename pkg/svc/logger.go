@@ -4,12 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"os"
 	"runtime"
 	"strings"
 	"time"
-
-	"github.com/lmittmann/tint"
 )
 
 var logger *slog.Logger
@@ -18,16 +15,25 @@ var loggerLevel *slog.LevelVar
 func initLogger(env DefaultEnv) {
 	loggerLevel = new(slog.LevelVar)
 	LogLevel(env.LogLevel)
-	var handler slog.Handler
-	if strings.ToUpper(env.LogFormat) == "JSON" {
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: loggerLevel, AddSource: true})
-	} else if env.LogColor == "true" {
-		handler = tint.NewHandler(os.Stdout, &tint.Options{Level: loggerLevel, AddSource: true})
-	} else {
-		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: loggerLevel, AddSource: true})
+
+	logSinksMu.Lock()
+	sinks, sinksErr := newLogSinks(env)
+	loggerSinks = newLogSinkSet(sinks)
+	logSinksMu.Unlock()
+
+	var handler slog.Handler = newMultiHandler(loggerSinks)
+	if env.LogSampleInitial > 0 {
+		handler = newSamplingHandler(handler, env.LogSampleInitial, env.LogSampleThereafter)
 	}
-	logger = slog.New(handler)
+	redactKeys := append([]string{}, DefaultLogRedactKeys...)
+	handler = newRedactHandler(handler, append(redactKeys, env.LogRedactKeys...))
+
+	logger = slog.New(contextHandler{traceHandler{handler}})
 	slog.SetDefault(logger)
+
+	if sinksErr != nil {
+		logger.Error("Cannot open log outputs", "err", sinksErr)
+	}
 }
 
 func Logger() *slog.Logger {
@@ -58,20 +64,38 @@ func LoggerNamespace(ns string, args ...any) *slog.Logger {
 }
 
 func Error(msg string, err error, args ...any) error {
+	return logError(context.Background(), msg, err, args)
+}
+
+// ErrorContext is like Error, logging with the attributes carried by ctx.
+func ErrorContext(ctx context.Context, msg string, err error, args ...any) error {
+	return logError(ctx, msg, err, args)
+}
+
+func logError(ctx context.Context, msg string, err error, args []any) error {
 	var pcs [1]uintptr
-	runtime.Callers(2, pcs[:]) // skip [Callers, Infof]
+	runtime.Callers(3, pcs[:]) // skip [Callers, logError, Error]
 	r := slog.NewRecord(time.Now(), slog.LevelError, msg, pcs[0])
 	r.Add("err", err)
 	r.Add(args...)
-	_ = logger.Handler().Handle(context.Background(), r)
+	_ = Logger().Handler().Handle(ctx, r)
 	return fmt.Errorf("%s: %w", msg, err)
 }
 
 func Fatal(msg string, args ...interface{}) {
+	logFatal(context.Background(), msg, args)
+}
+
+// FatalContext is like Fatal, logging with the attributes carried by ctx.
+func FatalContext(ctx context.Context, msg string, args ...interface{}) {
+	logFatal(ctx, msg, args)
+}
+
+func logFatal(ctx context.Context, msg string, args []interface{}) {
 	var pcs [1]uintptr
-	runtime.Callers(2, pcs[:]) // skip [Callers, Infof]
+	runtime.Callers(3, pcs[:]) // skip [Callers, logFatal, Fatal]
 	r := slog.NewRecord(time.Now(), slog.LevelError, msg, pcs[0])
 	r.Add(args...)
-	_ = logger.Handler().Handle(context.Background(), r)
+	_ = Logger().Handler().Handle(ctx, r)
 	Exit(1)
 }
@@ -0,0 +1,74 @@
+package svc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// DefaultLogRedactKeys are the attribute keys masked in the log records.
+// A key is masked when it contains one of them, ignoring case.
+var DefaultLogRedactKeys = []string{"password", "authorization", "secret"}
+
+// redactHandler masks the values of the attributes with a secret key.
+type redactHandler struct {
+	slog.Handler
+	keys []string
+}
+
+func newRedactHandler(h slog.Handler, keys []string) redactHandler {
+	lower := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			lower = append(lower, k)
+		}
+	}
+	return redactHandler{Handler: h, keys: lower}
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, nr)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return redactHandler{Handler: h.Handler.WithAttrs(redacted), keys: h.keys}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{Handler: h.Handler.WithGroup(name), keys: h.keys}
+}
+
+func (h redactHandler) redact(a slog.Attr) slog.Attr {
+	if h.isSecret(a.Key) {
+		return slog.String(a.Key, secretMask)
+	}
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		group := v.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+func (h redactHandler) isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range h.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
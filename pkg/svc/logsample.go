@@ -0,0 +1,80 @@
+package svc
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const logSampleBuckets = 4096
+
+// samplingHandler limits the debug and info records with the same level and
+// message: each second the first records are logged, then only one every
+// thereafter records. Warnings and errors are never sampled.
+type samplingHandler struct {
+	slog.Handler
+	sampler *logSampler
+}
+
+func newSamplingHandler(h slog.Handler, initial int, thereafter int) samplingHandler {
+	return samplingHandler{
+		Handler: h,
+		sampler: &logSampler{initial: uint64(initial), thereafter: uint64(thereafter)},
+	}
+}
+
+func (h samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.sampler.keep(r) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return samplingHandler{Handler: h.Handler.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h samplingHandler) WithGroup(name string) slog.Handler {
+	return samplingHandler{Handler: h.Handler.WithGroup(name), sampler: h.sampler}
+}
+
+type logSampler struct {
+	initial    uint64
+	thereafter uint64
+	counters   [logSampleBuckets]logSampleCounter
+}
+
+func (s *logSampler) keep(r slog.Record) bool {
+	if r.Level >= slog.LevelWarn {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(r.Level.String()))
+	_, _ = h.Write([]byte(r.Message))
+	n := s.counters[h.Sum32()%logSampleBuckets].incr(r.Time)
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+type logSampleCounter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+// incr counts a record in the current one second window.
+func (c *logSampleCounter) incr(t time.Time) uint64 {
+	now := t.UnixNano()
+	resetAt := c.resetAt.Load()
+	if resetAt > now {
+		return c.count.Add(1)
+	}
+	if !c.resetAt.CompareAndSwap(resetAt, now+int64(time.Second)) {
+		return c.count.Add(1)
+	}
+	c.count.Store(1)
+	return 1
+}
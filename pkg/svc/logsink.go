@@ -0,0 +1,190 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/lmittmann/tint"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	LogOutputStdout = "stdout"
+	LogOutputFile   = "file"
+	LogOutputSyslog = "syslog"
+)
+
+var (
+	logSinksMu sync.RWMutex
+	logSinks   []slog.Handler
+	// loggerSinks are the handlers of the current service logger
+	loggerSinks *logSinkSet
+)
+
+// AddLogSink adds a handler receiving the records of the service logger,
+// such as an OTLP log exporter bridge, including the loggers derived
+// before the call. The handler level is not bound to the LOG_LEVEL setting.
+func AddLogSink(h slog.Handler) {
+	logSinksMu.Lock()
+	defer logSinksMu.Unlock()
+	logSinks = append(logSinks, h)
+	if loggerSinks != nil {
+		loggerSinks.add(h)
+	}
+}
+
+// newLogSinks creates the handlers of the outputs listed in env,
+// followed by the ones added with AddLogSink.
+// The outputs that cannot be opened are skipped and reported in err.
+// It is called holding logSinksMu.
+func newLogSinks(env DefaultEnv) (sinks []slog.Handler, err error) {
+	outputs := env.LogOutputs
+	if len(outputs) == 0 {
+		outputs = []string{LogOutputStdout}
+	}
+	var errs []error
+	for _, output := range outputs {
+		h, e := newLogSink(strings.ToLower(strings.TrimSpace(output)), env)
+		if e != nil {
+			errs = append(errs, fmt.Errorf("cannot open %s log output: %w", output, e))
+			continue
+		}
+		sinks = append(sinks, h)
+	}
+	sinks = append(sinks, logSinks...)
+	err = errors.Join(errs...)
+	return
+}
+
+func newLogSink(output string, env DefaultEnv) (slog.Handler, error) {
+	switch output {
+	case LogOutputStdout:
+		if strings.ToUpper(env.LogFormat) != "JSON" && env.LogColor == "true" {
+			return tint.NewHandler(os.Stdout, &tint.Options{Level: loggerLevel, AddSource: true}), nil
+		}
+		return newLogFormatHandler(os.Stdout, env), nil
+	case LogOutputFile:
+		if env.LogFile == "" {
+			return nil, errors.New("LOG_FILE not set")
+		}
+		return newLogFormatHandler(&lumberjack.Logger{
+			Filename:   env.LogFile,
+			MaxSize:    env.LogFileMaxSize,
+			MaxBackups: env.LogFileMaxBackups,
+			MaxAge:     env.LogFileMaxAge,
+		}, env), nil
+	case LogOutputSyslog:
+		w, err := dialSyslog(env.LogSyslogNetwork, env.LogSyslogAddr)
+		if err != nil {
+			return nil, err
+		}
+		return newLogFormatHandler(w, env), nil
+	}
+	return nil, fmt.Errorf("unknown log output")
+}
+
+func newLogFormatHandler(w io.Writer, env DefaultEnv) slog.Handler {
+	opts := &slog.HandlerOptions{Level: loggerLevel, AddSource: true}
+	if strings.ToUpper(env.LogFormat) == "JSON" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// logSinkSet is the append-only set of handlers shared by a multiHandler
+// and the handlers derived from it.
+type logSinkSet struct {
+	mu       sync.RWMutex
+	handlers []slog.Handler
+}
+
+func newLogSinkSet(handlers []slog.Handler) *logSinkSet {
+	return &logSinkSet{handlers: handlers}
+}
+
+func (s *logSinkSet) add(h slog.Handler) {
+	s.mu.Lock()
+	s.handlers = append(s.handlers, h)
+	s.mu.Unlock()
+}
+
+func (s *logSinkSet) get() []slog.Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handlers
+}
+
+// multiHandler fans the records out to the handlers of the set, applying
+// the attributes and groups of the derived handlers to the ones added later.
+type multiHandler struct {
+	set *logSinkSet
+	// with derives the handlers, applying the attributes and groups in order
+	with []func(slog.Handler) slog.Handler
+
+	mu       sync.Mutex
+	handlers []slog.Handler
+}
+
+func newMultiHandler(set *logSinkSet) *multiHandler {
+	return &multiHandler{set: set}
+}
+
+// current returns the derived handlers, deriving the ones added to the set.
+func (m *multiHandler) current() []slog.Handler {
+	base := m.set.get()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.handlers); i < len(base); i++ {
+		h := base[i]
+		for _, fn := range m.with {
+			h = fn(h)
+		}
+		m.handlers = append(m.handlers, h)
+	}
+	return m.handlers
+}
+
+func (m *multiHandler) derive(fn func(slog.Handler) slog.Handler) slog.Handler {
+	with := make([]func(slog.Handler) slog.Handler, len(m.with), len(m.with)+1)
+	copy(with, m.with)
+	return &multiHandler{set: m.set, with: append(with, fn)}
+}
+
+func (m *multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m.current() {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range m.current() {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return m.derive(func(h slog.Handler) slog.Handler {
+		return h.WithAttrs(attrs)
+	})
+}
+
+func (m *multiHandler) WithGroup(name string) slog.Handler {
+	return m.derive(func(h slog.Handler) slog.Handler {
+		return h.WithGroup(name)
+	})
+}
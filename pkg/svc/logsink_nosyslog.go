@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package svc
+
+import (
+	"errors"
+	"io"
+)
+
+func dialSyslog(network string, addr string) (io.Writer, error) {
+	return nil, errors.New("syslog not supported on this platform")
+}
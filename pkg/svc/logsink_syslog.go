@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+
+package svc
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// dialSyslog connects to the syslog daemon, the local one when addr is empty.
+func dialSyslog(network string, addr string) (io.Writer, error) {
+	tag := ""
+	optionsMu.RLock()
+	if options != nil {
+		tag = options.Name
+	}
+	optionsMu.RUnlock()
+	return syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+}
@@ -0,0 +1,139 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandrolain/gomsvc/pkg/svc/envtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(newRedactHandler(slog.NewJSONHandler(&buf, nil), append(DefaultLogRedactKeys, "token")))
+
+	log.With("db_password", "secret1").Info("message",
+		"Authorization", "Bearer abc",
+		"user", "john",
+		slog.Group("auth", "api_token", "xyz", "name", "app"),
+		"pwd", envtype.Password("secret2"),
+	)
+
+	output := buf.String()
+	assert.NotContains(t, output, "secret1")
+	assert.NotContains(t, output, "secret2")
+	assert.NotContains(t, output, "abc")
+	assert.NotContains(t, output, "xyz")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, secretMask, entry["db_password"])
+	assert.Equal(t, secretMask, entry["Authorization"])
+	assert.Equal(t, "john", entry["user"])
+	assert.Equal(t, map[string]any{"api_token": secretMask, "name": "app"}, entry["auth"])
+}
+
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(newSamplingHandler(slog.NewTextHandler(&buf, nil), 2, 3))
+
+	for i := 0; i < 11; i++ {
+		log.Info("sampled")
+		log.Warn("not sampled")
+	}
+
+	output := buf.String()
+	// 2 initial records, then 1 every 3 of the remaining 9
+	assert.Equal(t, 5, strings.Count(output, "msg=sampled"))
+	assert.Equal(t, 11, strings.Count(output, "msg=\"not sampled\""))
+}
+
+func TestLogSampleCounterReset(t *testing.T) {
+	var c logSampleCounter
+	now := time.Now()
+	assert.Equal(t, uint64(1), c.incr(now))
+	assert.Equal(t, uint64(2), c.incr(now))
+	assert.Equal(t, uint64(1), c.incr(now.Add(time.Second)))
+}
+
+func TestLogSinks(t *testing.T) {
+	setupTest()
+	defer func() {
+		logSinks = nil
+	}()
+
+	file := filepath.Join(t.TempDir(), "service.log")
+	var extra bytes.Buffer
+	AddLogSink(slog.NewJSONHandler(&extra, nil))
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	initLogger(DefaultEnv{
+		LogFormat:  "JSON",
+		LogOutputs: []string{LogOutputStdout, LogOutputFile},
+		LogFile:    file,
+	})
+	Logger().InfoContext(context.Background(), "fan out", "password", "secret")
+
+	require.NoError(t, w.Close())
+	os.Stdout = old
+	var stdout bytes.Buffer
+	_, err := stdout.ReadFrom(r)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	for _, output := range []string{stdout.String(), string(content), extra.String()} {
+		assert.Contains(t, output, "fan out")
+		assert.NotContains(t, output, "secret")
+	}
+}
+
+func TestAddLogSinkDerived(t *testing.T) {
+	setupTest()
+	defer func() {
+		logSinksMu.Lock()
+		logSinks = nil
+		logSinksMu.Unlock()
+	}()
+
+	old := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() {
+		os.Stdout = old
+		_ = w.Close()
+	}()
+
+	initLogger(DefaultEnv{LogFormat: "JSON"})
+	derived := Logger().With("component", "test")
+	LogLevel("DEBUG")
+
+	var extra bytes.Buffer
+	AddLogSink(slog.NewJSONHandler(&extra, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	derived.Debug("after sink")
+
+	assert.Equal(t, "DEBUG", loggerLevel.Level().String())
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(extra.Bytes(), &entry))
+	assert.Equal(t, "after sink", entry["msg"])
+	assert.Equal(t, "test", entry["component"])
+}
+
+func TestLogSinksErrors(t *testing.T) {
+	setupTest()
+	_, err := newLogSinks(DefaultEnv{LogOutputs: []string{LogOutputFile, "invalid"}})
+	assert.ErrorContains(t, err, "LOG_FILE not set")
+	assert.ErrorContains(t, err, "unknown log output")
+}
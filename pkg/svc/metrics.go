@@ -0,0 +1,127 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const DefaultMetricsPath = "/metrics"
+
+var metricsRegistry = newMetricsRegistry()
+
+func newMetricsRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// MetricsRegistry returns the Prometheus registry of the service metrics.
+func MetricsRegistry() *prometheus.Registry {
+	return metricsRegistry
+}
+
+// RegisterMetric registers the collector in the service registry.
+// If an equal collector is already registered, the existing one is returned.
+func RegisterMetric[T prometheus.Collector](c T) T {
+	if err := metricsRegistry.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// UnregisterMetric removes the collector from the service registry.
+func UnregisterMetric(c prometheus.Collector) bool {
+	return metricsRegistry.Unregister(c)
+}
+
+// MetricsHandler returns the HTTP handler exposing the service metrics.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// metricsComponent serves the metrics on a dedicated listener.
+func metricsComponent(addr string, path string) Component {
+	if path == "" {
+		path = DefaultMetricsPath
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, MetricsHandler())
+	server := &http.Server{Handler: mux}
+
+	return Component{
+		Name: "metrics",
+		Start: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fmt.Errorf("failed to listen: %w", err)
+			}
+			go func() {
+				if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+					_ = Error("Metrics server stopped", err, "addr", addr)
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return server.Shutdown(ctx)
+		},
+	}
+}
+
+// GaugeSet is a collector of gauges distinguished by a single label,
+// whose values are read from functions on each collection.
+type GaugeSet struct {
+	desc  *prometheus.Desc
+	mu    sync.RWMutex
+	funcs map[string]func() float64
+}
+
+func NewGaugeSet(name string, help string, label string) *GaugeSet {
+	return &GaugeSet{
+		desc:  prometheus.NewDesc(name, help, []string{label}, nil),
+		funcs: make(map[string]func() float64),
+	}
+}
+
+// Set sets the function reading the gauge value for the label value.
+func (g *GaugeSet) Set(labelValue string, fn func() float64) {
+	g.mu.Lock()
+	g.funcs[labelValue] = fn
+	g.mu.Unlock()
+}
+
+// Delete removes the gauge for the label value.
+func (g *GaugeSet) Delete(labelValue string) {
+	g.mu.Lock()
+	delete(g.funcs, labelValue)
+	g.mu.Unlock()
+}
+
+func (g *GaugeSet) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *GaugeSet) Collect(ch chan<- prometheus.Metric) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	for labelValue, fn := range g.funcs {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, fn(), labelValue)
+	}
+}
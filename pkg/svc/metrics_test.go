@@ -0,0 +1,49 @@
+package svc
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterMetric(t *testing.T) {
+	opts := prometheus.CounterOpts{Name: "test_register_metric_total", Help: "Test counter."}
+	first := RegisterMetric(prometheus.NewCounter(opts))
+	defer UnregisterMetric(first)
+
+	second := RegisterMetric(prometheus.NewCounter(opts))
+	assert.Same(t, first, second)
+
+	assert.Panics(t, func() {
+		RegisterMetric(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_register_metric_total", Help: "Test gauge."}))
+	})
+}
+
+func TestGaugeSet(t *testing.T) {
+	g := RegisterMetric(NewGaugeSet("test_gauge_set", "Test gauge set.", "name"))
+	defer UnregisterMetric(g)
+
+	g.Set("a", func() float64 { return 1 })
+	g.Set("b", func() float64 { return 2 })
+	assert.Equal(t, 2, testutil.CollectAndCount(g))
+
+	g.Delete("a")
+	assert.Equal(t, 1, testutil.CollectAndCount(g))
+	assert.Equal(t, float64(2), testutil.ToFloat64(g))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	c := RegisterMetric(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_handler_total", Help: "Test counter."}))
+	defer UnregisterMetric(c)
+	c.Inc()
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", DefaultMetricsPath, nil))
+	require.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Body.String(), "test_handler_total 1")
+	assert.Contains(t, rec.Body.String(), "go_goroutines")
+}
@@ -0,0 +1,161 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ConfigChangeFunc receives the previous and the reloaded configuration.
+type ConfigChangeFunc[T any] func(old T, new T)
+
+var ErrConfigReloadUnavailable = errors.New("config reload not available outside of a service")
+
+var (
+	configReloadMu    sync.Mutex
+	configReloader    func() (old, new interface{}, err error)
+	configListenersMu sync.RWMutex
+	configListeners   = make([]func(old, new interface{}), 0)
+)
+
+// OnConfigChange registers a function called after every successful
+// configuration reload. Listeners of a type other than the service
+// configuration type are never called.
+func OnConfigChange[T any](fn ConfigChangeFunc[T]) {
+	configListenersMu.Lock()
+	configListeners = append(configListeners, func(old, new interface{}) {
+		o, ok := old.(T)
+		if !ok {
+			return
+		}
+		n, ok := new.(T)
+		if !ok {
+			return
+		}
+		fn(o, n)
+	})
+	configListenersMu.Unlock()
+}
+
+// ReloadConfig loads again the service configuration, and when valid
+// it replaces the one returned by Config and notifies the listeners.
+// If the new configuration is not valid the current one is kept.
+// The listeners are notified after the reload is completed, so they
+// can reload the configuration again.
+func ReloadConfig() error {
+	configReloadMu.Lock()
+	if configReloader == nil {
+		configReloadMu.Unlock()
+		return ErrConfigReloadUnavailable
+	}
+	old, config, err := configReloader()
+	configReloadMu.Unlock()
+	if err != nil {
+		return err
+	}
+	notifyConfigChange(old, config)
+	return nil
+}
+
+func setConfigReloader[C any](opts ConfigOptions) {
+	configReloadMu.Lock()
+	defer configReloadMu.Unlock()
+	configReloader = func() (old, new interface{}, err error) {
+		env, _, err := LoadConfig[DefaultEnv](opts)
+		if err != nil {
+			return nil, nil, Error("Cannot reload config, keeping the current one", err)
+		}
+		config, report, err := LoadConfig[C](opts)
+		if err != nil {
+			return nil, nil, Error("Cannot reload config, keeping the current one", err)
+		}
+
+		LogLevel(env.LogLevel)
+
+		globalConfigMu.Lock()
+		old = globalConfig
+		globalConfig = config
+		globalConfigReport = report
+		globalConfigMu.Unlock()
+
+		Logger().Info("Configuration reloaded")
+		Logger().Debug("Configuration loaded", "config", report)
+		return old, config, nil
+	}
+}
+
+func notifyConfigChange(old, new interface{}) {
+	configListenersMu.RLock()
+	listeners := make([]func(old, new interface{}), len(configListeners))
+	copy(listeners, configListeners)
+	configListenersMu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(old, new)
+	}
+}
+
+// configWatcherComponent polls the configuration files, and the secret
+// files of the current configuration, reloading the configuration when
+// any of them is modified, created or deleted.
+func configWatcherComponent(files []string, interval time.Duration) Component {
+	var cancel context.CancelFunc
+	return Component{
+		Name: "config-watcher",
+		Start: func(ctx context.Context) error {
+			var watchCtx context.Context
+			watchCtx, cancel = context.WithCancel(context.Background())
+			go watchConfigFiles(watchCtx, files, configFilesModTimes(watchedFiles(files)), interval)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			return nil
+		},
+	}
+}
+
+func watchConfigFiles(ctx context.Context, files []string, modTimes map[string]time.Time, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := configFilesModTimes(watchedFiles(files))
+			changed := len(current) != len(modTimes)
+			for file, t := range current {
+				if prev, ok := modTimes[file]; !ok || !t.Equal(prev) {
+					changed = true
+				}
+			}
+			modTimes = current
+			if changed {
+				_ = ReloadConfig()
+			}
+		}
+	}
+}
+
+// watchedFiles adds to the configuration files the secret files of the
+// current configuration, that can change on reload.
+func watchedFiles(files []string) []string {
+	globalConfigMu.RLock()
+	secretFiles := globalConfigReport.SecretFiles()
+	globalConfigMu.RUnlock()
+	return append(slices.Clone(files), secretFiles...)
+}
+
+func configFilesModTimes(files []string) map[string]time.Time {
+	res := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			res[file] = info.ModTime()
+		}
+	}
+	return res
+}
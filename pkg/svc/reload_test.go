@@ -0,0 +1,191 @@
+package svc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TestReloadableConfig struct {
+	Value string `env:"RELOAD_VALUE" validate:"required"`
+}
+
+func resetConfigReload() {
+	configReloadMu.Lock()
+	configReloader = nil
+	configReloadMu.Unlock()
+	configListenersMu.Lock()
+	configListeners = make([]func(old, new interface{}), 0)
+	configListenersMu.Unlock()
+}
+
+func TestReloadConfig(t *testing.T) {
+	setupTest()
+	resetConfigReload()
+	defer resetConfigReload()
+
+	assert.ErrorIs(t, ReloadConfig(), ErrConfigReloadUnavailable)
+
+	t.Setenv("RELOAD_VALUE", "first")
+	cfg, _, err := LoadConfig[TestReloadableConfig](ConfigOptions{})
+	require.NoError(t, err)
+	globalConfigMu.Lock()
+	globalConfig = cfg
+	globalConfigMu.Unlock()
+
+	setConfigReloader[TestReloadableConfig](ConfigOptions{})
+
+	var calls atomic.Int32
+	OnConfigChange(func(old TestReloadableConfig, new TestReloadableConfig) {
+		calls.Add(1)
+		assert.Equal(t, "first", old.Value)
+		assert.Equal(t, "second", new.Value)
+	})
+	OnConfigChange(func(old TestConfig, new TestConfig) {
+		t.Error("listener of another type should not be called")
+	})
+
+	t.Setenv("LOG_LEVEL", "DEBUG")
+	t.Setenv("RELOAD_VALUE", "second")
+	require.NoError(t, ReloadConfig())
+	assert.Equal(t, "second", Config[TestReloadableConfig]().Value)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, "DEBUG", loggerLevel.Level().String())
+
+	t.Setenv("RELOAD_VALUE", "")
+	assert.Error(t, ReloadConfig())
+	assert.Equal(t, "second", Config[TestReloadableConfig]().Value)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestReloadConfigFromListener(t *testing.T) {
+	setupTest()
+	resetConfigReload()
+	defer resetConfigReload()
+
+	t.Setenv("RELOAD_VALUE", "first")
+	setConfigReloader[TestReloadableConfig](ConfigOptions{})
+
+	var calls atomic.Int32
+	OnConfigChange(func(old TestReloadableConfig, new TestReloadableConfig) {
+		if calls.Add(1) == 1 {
+			assert.NoError(t, ReloadConfig())
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, ReloadConfig())
+	}()
+	select {
+	case <-done:
+		assert.Equal(t, int32(2), calls.Load())
+	case <-time.After(2 * time.Second):
+		t.Fatal("reload from listener deadlocked")
+	}
+}
+
+func TestConfigWatcher(t *testing.T) {
+	setupTest()
+	resetConfigReload()
+	defer resetConfigReload()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("reload_value: first\n"), 0600))
+
+	opts := ConfigOptions{Files: []string{file}}
+	cfg, _, err := LoadConfig[TestReloadableConfig](opts)
+	require.NoError(t, err)
+	globalConfigMu.Lock()
+	globalConfig = cfg
+	globalConfigMu.Unlock()
+	setConfigReloader[TestReloadableConfig](opts)
+
+	changed := make(chan string, 1)
+	OnConfigChange(func(old TestReloadableConfig, new TestReloadableConfig) {
+		changed <- new.Value
+	})
+
+	c := configWatcherComponent(opts.Files, 10*time.Millisecond)
+	require.NoError(t, c.Start(context.Background()))
+	defer func() {
+		_ = c.Stop(context.Background())
+	}()
+
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.WriteFile(file, []byte("reload_value: second\n"), 0600))
+	require.NoError(t, os.Chtimes(file, later, later))
+
+	select {
+	case v := <-changed:
+		assert.Equal(t, "second", v)
+	case <-time.After(2 * time.Second):
+		t.Fatal("config not reloaded")
+	}
+}
+
+func TestConfigWatcherSecretFiles(t *testing.T) {
+	setupTest()
+	resetConfigReload()
+	defer resetConfigReload()
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "value")
+	require.NoError(t, os.WriteFile(secret, []byte("first\n"), 0600))
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("reload_value: from-file\n"), 0600))
+	t.Setenv("RELOAD_VALUE_FILE", secret)
+
+	opts := ConfigOptions{Files: []string{file}, IgnoreMissingFiles: true}
+	cfg, report, err := LoadConfig[TestReloadableConfig](opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{secret}, report.SecretFiles())
+	globalConfigMu.Lock()
+	globalConfig = cfg
+	globalConfigReport = report
+	globalConfigMu.Unlock()
+	setConfigReloader[TestReloadableConfig](opts)
+
+	changed := make(chan string, 1)
+	OnConfigChange(func(old TestReloadableConfig, new TestReloadableConfig) {
+		select {
+		case changed <- new.Value:
+		default:
+		}
+	})
+
+	c := configWatcherComponent(opts.Files, 10*time.Millisecond)
+	require.NoError(t, c.Start(context.Background()))
+	defer func() {
+		_ = c.Stop(context.Background())
+	}()
+
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.WriteFile(secret, []byte("second\n"), 0600))
+	require.NoError(t, os.Chtimes(secret, later, later))
+	select {
+	case v := <-changed:
+		assert.Equal(t, "second", v)
+	case <-time.After(2 * time.Second):
+		t.Fatal("secret file change not reloaded")
+	}
+
+	// The deleted files are detected too
+	require.NoError(t, os.Unsetenv("RELOAD_VALUE_FILE"))
+	t.Setenv("RELOAD_VALUE", "from-env")
+	require.NoError(t, os.Remove(file))
+	select {
+	case v := <-changed:
+		assert.Equal(t, "from-env", v)
+	case <-time.After(2 * time.Second):
+		t.Fatal("deleted file not reloaded")
+	}
+}
@@ -1,12 +1,14 @@
 package svc
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"regexp"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"log/slog"
 
@@ -26,9 +28,33 @@ var (
 )
 
 type DefaultEnv struct {
-	LogLevel  string `env:"LOG_LEVEL"`
-	LogFormat string `env:"LOG_FORMAT"`
-	LogColor  string `env:"LOG_COLOR"`
+	LogLevel            string        `env:"LOG_LEVEL"`
+	LogFormat           string        `env:"LOG_FORMAT"`
+	LogColor            string        `env:"LOG_COLOR"`
+	LogOutputs          []string      `env:"LOG_OUTPUTS"`
+	LogFile             string        `env:"LOG_FILE"`
+	LogFileMaxSize      int           `env:"LOG_FILE_MAX_SIZE"`
+	LogFileMaxBackups   int           `env:"LOG_FILE_MAX_BACKUPS"`
+	LogFileMaxAge       int           `env:"LOG_FILE_MAX_AGE"`
+	LogSyslogNetwork    string        `env:"LOG_SYSLOG_NETWORK"`
+	LogSyslogAddr       string        `env:"LOG_SYSLOG_ADDR"`
+	LogRedactKeys       []string      `env:"LOG_REDACT_KEYS"`
+	LogSampleInitial    int           `env:"LOG_SAMPLE_INITIAL"`
+	LogSampleThereafter int           `env:"LOG_SAMPLE_THEREAFTER"`
+	AdminAddr           string        `env:"ADMIN_ADDR"`
+	AdminToken          string        `env:"ADMIN_TOKEN" secret:"true"`
+	AdminTLSCertFile    string        `env:"ADMIN_TLS_CERT_FILE"`
+	AdminTLSKeyFile     string        `env:"ADMIN_TLS_KEY_FILE"`
+	AdminTLSCAFile      string        `env:"ADMIN_TLS_CA_FILE"`
+	CrashPolicy         string        `env:"CRASH_POLICY"`
+	CrashMaxRestarts    int           `env:"CRASH_MAX_RESTARTS"`
+	CrashDumpDir        string        `env:"CRASH_DUMP_DIR"`
+	CrashWebhookURL     string        `env:"CRASH_WEBHOOK_URL" secret:"true"`
+	ShutdownTimeout     time.Duration `env:"SHUTDOWN_TIMEOUT"`
+	ConfigWatchInterval time.Duration `env:"CONFIG_WATCH_INTERVAL"`
+	TelemetryExporter   string        `env:"TELEMETRY_EXPORTER"`
+	MetricsAddr         string        `env:"METRICS_ADDR"`
+	MetricsPath         string        `env:"METRICS_PATH"`
 }
 
 type ServiceOptions struct {
@@ -43,8 +69,9 @@ var (
 )
 
 var (
-	globalConfigMu sync.RWMutex
-	globalConfig   interface{}
+	globalConfigMu     sync.RWMutex
+	globalConfig       interface{}
+	globalConfigReport ConfigReport
 )
 
 var osExit = os.Exit // allow to be mocked in tests
@@ -64,21 +91,56 @@ func Service[C any](opts ServiceOptions, fn ServiceFunc[C]) {
 	options = &opts
 	optionsMu.Unlock()
 
-	env := PanicWithError(GetEnv[DefaultEnv]())
+	configOpts := ConfigOptionsFromEnv()
+
+	env, _, err := LoadConfig[DefaultEnv](configOpts)
+	PanicIfError(err)
 
 	initLogger(env)
+	SetShutdownTimeout(env.ShutdownTimeout)
+	PanicIfError(initCrashHandler(env))
+
+	if env.AdminAddr != "" {
+		PanicIfError(AddComponent(adminComponent(env)))
+	}
+
+	shutdownTelemetry, err := InitTelemetry(context.Background(), TelemetryOptions{
+		Exporter:       env.TelemetryExporter,
+		ServiceName:    opts.Name,
+		ServiceVersion: opts.Version,
+	})
+	PanicIfError(err)
+	PanicIfError(AddComponent(telemetryComponent(shutdownTelemetry)))
+
+	if env.MetricsAddr != "" {
+		PanicIfError(AddComponent(metricsComponent(env.MetricsAddr, env.MetricsPath)))
+	}
 
-	config := PanicWithError(GetEnv[C]())
+	config, report, err := LoadConfig[C](configOpts)
+	PanicIfError(err)
+
+	setConfigReloader[C](configOpts)
+	if env.ConfigWatchInterval > 0 {
+		files := append(configOpts.Files, configOpts.DotEnvFiles...)
+		PanicIfError(AddComponent(configWatcherComponent(files, env.ConfigWatchInterval)))
+	}
 
 	exitCh := make(chan os.Signal, 1)
 	signal.Notify(exitCh,
 		syscall.SIGTERM, // terminate: stopped by `kill -9 PID`
 		syscall.SIGINT,  // interrupt: stopped by Ctrl + C
-		syscall.SIGHUP,
 		syscall.SIGQUIT,
 		os.Interrupt,
 	)
 
+	reloadCh := make(chan os.Signal, 1)
+	signal.Notify(reloadCh, syscall.SIGHUP) // hangup: reload the configuration
+	go func() {
+		for range reloadCh {
+			_ = ReloadConfig()
+		}
+	}()
+
 	serviceUuidMu.RLock()
 	svcUuid := serviceUuid
 	serviceUuidMu.RUnlock()
@@ -88,17 +150,36 @@ func Service[C any](opts ServiceOptions, fn ServiceFunc[C]) {
 	optionsMu.RUnlock()
 
 	slog.Info(`Starting service`, "name", svcOpts.Name, "version", opts.Version, "ID", svcUuid)
+	slog.Debug(`Configuration loaded`, "config", report)
 
 	globalConfigMu.Lock()
 	globalConfig = config
+	globalConfigReport = report
 	globalConfigMu.Unlock()
 
+	SetReady(false)
+	if err := StartComponents(context.Background()); err != nil {
+		Fatal("Cannot start components", "err", err)
+	}
+	// The ServiceFunc may block serving, as in httplib.Server.Listen, so
+	// the components it registers start on registration
+	setStartOnAdd(true)
+	SetReady(true)
 	go fn(config)
 	<-exitCh
 	Exit(0)
 }
 
+// Exit stops the components and runs the exit callbacks within the shutdown
+// timeout, then exits with the code.
 func Exit(code int) {
+	SetReady(false)
+	setStartOnAdd(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), getShutdownTimeout())
+	defer cancel()
+	stopStartedComponents(ctx)
+
 	var wg sync.WaitGroup
 
 	exitCallbacksMu.RLock()
@@ -113,8 +194,17 @@ func Exit(code int) {
 			callback()
 		}(fn)
 	}
-	wg.Wait()
-	logger.Info("Exit service", "code", code)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		Logger().Warn("Exit callbacks timed out")
+	}
+	Logger().Info("Exit service", "code", code)
 	osExit(code)
 }
 
@@ -1,6 +1,7 @@
 package svc
 
 import (
+	"context"
 	"os"
 	"sync/atomic"
 	"testing"
@@ -20,7 +21,17 @@ func TestService(t *testing.T) {
 	err = os.Setenv("LOG_LEVEL", "INFO")
 	assert.NoError(t, err)
 
+	defer setStartOnAdd(false)
+
 	done := make(chan bool)
+	started := make(chan struct{})
+	assert.NoError(t, AddComponent(Component{
+		Name: "test-started",
+		Start: func(ctx context.Context) error {
+			close(started)
+			return nil
+		},
+	}))
 
 	// Test service initialization
 	go Service(ServiceOptions{
@@ -35,8 +46,22 @@ func TestService(t *testing.T) {
 		// Test config retrieval
 		retrievedConfig := Config[TestConfig]()
 		assert.Equal(t, cfg, retrievedConfig)
+		assert.True(t, IsReady())
+
+		// The components registered by the ServiceFunc start on registration
+		var lateStarted atomic.Bool
+		assert.NoError(t, AddComponent(Component{
+			Name: "test-late",
+			Start: func(ctx context.Context) error {
+				lateStarted.Store(true)
+				return nil
+			},
+		}))
+		assert.True(t, lateStarted.Load())
 
 		done <- true
+		// Blocking as a server listening
+		select {}
 	})
 
 	select {
@@ -45,6 +70,11 @@ func TestService(t *testing.T) {
 	case <-time.After(5 * time.Second):
 		t.Fatal("Test timed out")
 	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Components not started while the ServiceFunc blocks")
+	}
 }
 
 func TestOnExit(t *testing.T) {
@@ -71,3 +101,34 @@ func TestOnExit(t *testing.T) {
 	assert.True(t, exitCalled.Load())
 	assert.Equal(t, int32(2), callCount.Load())
 }
+
+func TestExitSharedDeadline(t *testing.T) {
+	resetComponents()
+	defer resetComponents()
+	defer func() {
+		exitCallbacksMu.Lock()
+		exitCallbacks = nil
+		exitCallbacksMu.Unlock()
+	}()
+
+	originalOsExit := osExit
+	defer func() { osExit = originalOsExit }()
+	osExit = func(code int) {}
+
+	SetShutdownTimeout(200 * time.Millisecond)
+	assert.NoError(t, AddComponent(Component{
+		Name: "slow-stop",
+		Stop: func(ctx context.Context) error {
+			time.Sleep(150 * time.Millisecond)
+			return nil
+		},
+	}))
+	assert.NoError(t, StartComponents(context.Background()))
+	OnExit(func() {
+		time.Sleep(time.Second)
+	})
+
+	start := time.Now()
+	Exit(0)
+	assert.Less(t, time.Since(start), 400*time.Millisecond, "the timeout is shared by the stop and the exit callbacks")
+}
@@ -0,0 +1,176 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.32.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TelemetryExporterNone     = "none"
+	TelemetryExporterOTLP     = "otlp"
+	TelemetryExporterOTLPHTTP = "otlphttp"
+)
+
+// TelemetryOptions configures the OpenTelemetry bootstrap.
+// The OTLP exporters are configured by the standard OTEL_EXPORTER_OTLP_*
+// environment variables.
+type TelemetryOptions struct {
+	Exporter       string
+	ServiceName    string
+	ServiceVersion string
+}
+
+// TelemetryShutdownFunc flushes and stops the telemetry providers.
+type TelemetryShutdownFunc func(ctx context.Context) error
+
+// InitTelemetry sets the global OpenTelemetry tracer and meter providers
+// and the W3C trace context propagator.
+// With the "none" or empty exporter only the propagator is set.
+func InitTelemetry(ctx context.Context, opts TelemetryOptions) (shutdown TelemetryShutdownFunc, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	shutdown = func(ctx context.Context) error { return nil }
+
+	var traceExporter sdktrace.SpanExporter
+	var metricExporter sdkmetric.Exporter
+
+	switch strings.ToLower(opts.Exporter) {
+	case "", TelemetryExporterNone:
+		return
+	case TelemetryExporterOTLP:
+		if traceExporter, err = otlptracegrpc.New(ctx); err != nil {
+			return
+		}
+		metricExporter, err = otlpmetricgrpc.New(ctx)
+	case TelemetryExporterOTLPHTTP:
+		if traceExporter, err = otlptracehttp.New(ctx); err != nil {
+			return
+		}
+		metricExporter, err = otlpmetrichttp.New(ctx)
+	default:
+		err = fmt.Errorf("unknown telemetry exporter: %s", opts.Exporter)
+	}
+	if err != nil {
+		return
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName(opts.ServiceName),
+			semconv.ServiceVersion(opts.ServiceVersion),
+			semconv.ServiceInstanceID(ServiceID()),
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExporter),
+		sdktrace.WithResource(res),
+	)
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+
+	shutdown = func(ctx context.Context) error {
+		return errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx))
+	}
+	return
+}
+
+// TestTelemetry collects the spans and the metrics in memory.
+type TestTelemetry struct {
+	Spans   *tracetest.InMemoryExporter
+	Metrics *sdkmetric.ManualReader
+	// Shutdown restores the previous global providers.
+	Shutdown func()
+}
+
+// InitTestTelemetry sets global OpenTelemetry providers exporting to memory,
+// to be used in tests.
+func InitTestTelemetry() *TestTelemetry {
+	prevTP := otel.GetTracerProvider()
+	prevMP := otel.GetMeterProvider()
+	prevProp := otel.GetTextMapPropagator()
+
+	spans := tracetest.NewInMemoryExporter()
+	metrics := sdkmetric.NewManualReader()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(metrics))
+
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return &TestTelemetry{
+		Spans:   spans,
+		Metrics: metrics,
+		Shutdown: func() {
+			_ = tp.Shutdown(context.Background())
+			_ = mp.Shutdown(context.Background())
+			otel.SetTracerProvider(prevTP)
+			otel.SetMeterProvider(prevMP)
+			otel.SetTextMapPropagator(prevProp)
+		},
+	}
+}
+
+func telemetryComponent(shutdown TelemetryShutdownFunc) Component {
+	return Component{
+		Name: "telemetry",
+		Stop: func(ctx context.Context) error {
+			return shutdown(ctx)
+		},
+	}
+}
+
+// traceHandler adds the trace and span IDs of the context span to the log records.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
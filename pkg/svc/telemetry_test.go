@@ -0,0 +1,46 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestInitTelemetry(t *testing.T) {
+	shutdown, err := InitTelemetry(context.Background(), TelemetryOptions{Exporter: TelemetryExporterNone})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = InitTelemetry(context.Background(), TelemetryOptions{Exporter: "invalid"})
+	assert.Error(t, err)
+}
+
+func TestTraceHandler(t *testing.T) {
+	tt := InitTestTelemetry()
+	defer tt.Shutdown()
+
+	var buf bytes.Buffer
+	log := slog.New(traceHandler{slog.NewJSONHandler(&buf, nil)})
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "test")
+	log.InfoContext(ctx, "traced")
+	span.End()
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, span.SpanContext().TraceID().String(), entry["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), entry["span_id"])
+
+	buf.Reset()
+	log.Info("untraced")
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.NotContains(t, buf.String(), "trace_id")
+
+	assert.Len(t, tt.Spans.GetSpans(), 1)
+}